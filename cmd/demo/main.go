@@ -14,12 +14,12 @@ import (
 	"github.com/example/goseckill/internal/service"
 )
 
-// 简单 demo：初始化一个商品并把库存同步到 Redis，用于手工测试秒杀流程
+// 简单 demo：初始化一个商品和包含它的秒杀活动，并把库存同步到 Redis，用于手工测试秒杀流程
 func main() {
 	cfg := config.DefaultConfig()
 	db := mysql.Init(&cfg.MySQL)
 	redisClient := redis.Init(&cfg.Redis)
-	mqConn := mq.Init(&cfg.RabbitMQ)
+	broker := mq.Init(&cfg.MQ, &cfg.RabbitMQ)
 
 	productRepo := mysql.NewProductRepository(db)
 
@@ -38,13 +38,27 @@ func main() {
 		log.Fatalf("create product failed: %v", err)
 	}
 
-	// 同步秒杀库存到 Redis
-	seckillSvc := service.NewSeckillService(productRepo, redisClient, mqConn, &cfg.JWT)
-	if err := seckillSvc.InitProductStock(context.Background(), p); err != nil {
-		log.Fatalf("init redis stock failed: %v", err)
+	// 创建并启动一个包含该商品的秒杀活动，启动时会把秒杀库存同步到 Redis 中该活动的库存键
+	activityRepo := mysql.NewSeckillActivityRepository(db)
+	activitySvc := service.NewSeckillActivityService(activityRepo, productRepo, nil, nil)
+	seckillSvc := service.NewSeckillService(productRepo, activityRepo, redisClient, broker, nil, nil, nil)
+	activity, err := activitySvc.CreateActivity(context.Background(), &service.CreateActivityRequest{
+		Name:          "demo 秒杀活动",
+		StartTime:     p.StartTime,
+		EndTime:       p.EndTime,
+		Discount:      0.8,
+		LimitPerUser:  1,
+		ProductIDs:    []int64{p.ID},
+		ProductStocks: map[int64]int64{p.ID: p.SeckillStock},
+	})
+	if err != nil {
+		log.Fatalf("create activity failed: %v", err)
+	}
+	if err := activitySvc.StartActivity(context.Background(), activity.ID, seckillSvc); err != nil {
+		log.Fatalf("start activity failed: %v", err)
 	}
 
-	fmt.Printf("demo 初始化完成，商品 ID = %d，秒杀库存 = %d\n", p.ID, p.SeckillStock)
+	fmt.Printf("demo 初始化完成，商品 ID = %d，活动 ID = %d，秒杀库存 = %d\n", p.ID, activity.ID, p.SeckillStock)
 	fmt.Println("现在你可以：")
 	fmt.Println("1) 启动 web 服务：go run ./cmd/web")
 	fmt.Println("2) 启动 worker： go run ./cmd/seckill-worker")
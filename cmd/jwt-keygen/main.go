@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/example/goseckill/internal/config"
+)
+
+// JWT 签名密钥生成：输出一个可直接加入密钥文件（GOSECKILL_JWT_KEYS_FILE）keys 列表的 JSON 对象。
+// HS256 输出随机密钥；RS256 / EdDSA 输出 PKCS#8 私钥与 PKIX 公钥 PEM，只校验令牌的服务删除私钥、只保留公钥即可。
+func main() {
+	alg := flag.String("alg", "HS256", "签名算法：HS256 / RS256 / EdDSA")
+	kid := flag.String("kid", "", "密钥ID，默认按日期生成")
+	bits := flag.Int("bits", 2048, "RS256 密钥长度")
+	flag.Parse()
+
+	if *kid == "" {
+		*kid = time.Now().Format("20060102")
+	}
+
+	key := config.JWTKeyConfig{ID: *kid, Algorithm: *alg}
+	switch *alg {
+	case "HS256":
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			log.Fatalf("生成密钥失败: %v", err)
+		}
+		key.Secret = base64.RawURLEncoding.EncodeToString(b)
+	case "RS256":
+		priv, err := rsa.GenerateKey(rand.Reader, *bits)
+		if err != nil {
+			log.Fatalf("生成密钥失败: %v", err)
+		}
+		key.PrivateKeyPEM, key.PublicKeyPEM = encodePEM(priv, &priv.PublicKey)
+	case "EdDSA":
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatalf("生成密钥失败: %v", err)
+		}
+		key.PrivateKeyPEM, key.PublicKeyPEM = encodePEM(priv, pub)
+	default:
+		log.Fatalf("不支持的算法: %s", *alg)
+	}
+
+	out, err := json.MarshalIndent(key, "", "  ")
+	if err != nil {
+		log.Fatalf("编码密钥失败: %v", err)
+	}
+	fmt.Println(string(out))
+}
+
+func encodePEM(priv, pub interface{}) (string, string) {
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		log.Fatalf("编码私钥失败: %v", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		log.Fatalf("编码公钥失败: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/example/goseckill/internal/config"
+	"github.com/example/goseckill/internal/repository/mysql"
+	"github.com/example/goseckill/internal/service"
+)
+
+// 余额对账：按复式记账分录重新计算每个用户的可用余额与冻结金额，与账户表比较并报告差异。
+// 加 -fix 时以账户表为准写入调整分录（首次上线需执行一次，为已有余额补记期初分录）。
+// 存在差异或借贷不平衡的凭证时以非 0 状态退出，便于接入定时任务告警。
+func main() {
+	fix := flag.Bool("fix", false, "为不一致的账户写入调整分录")
+	flag.Parse()
+
+	cfg := config.DefaultConfig()
+	db := mysql.Init(&cfg.MySQL)
+	ledgerSvc := service.NewLedgerService(db, mysql.NewLedgerRepository(db))
+
+	report, err := ledgerSvc.Reconcile(context.Background(), *fix)
+	if err != nil {
+		log.Fatalf("对账失败: %v", err)
+	}
+
+	log.Printf("检查账户数: %d", report.Accounts)
+	log.Printf("分录借方合计: %d，贷方合计: %d", report.TotalDebit, report.TotalCredit)
+	for _, m := range report.Mismatches {
+		status := "未修正"
+		if m.Fixed {
+			status = "已写入调整分录"
+		}
+		log.Printf("⚠️  用户 %d %s: 账户表 %d，分录 %d，差额 %d（%s）",
+			m.UserID, m.Account, m.Stored, m.Ledger, m.Stored-m.Ledger, status)
+	}
+	for _, j := range report.UnbalancedJournals {
+		log.Printf("⚠️  凭证 %s 借贷不平衡", j)
+	}
+
+	unfixed := 0
+	for _, m := range report.Mismatches {
+		if !m.Fixed {
+			unfixed++
+		}
+	}
+	if unfixed > 0 || len(report.UnbalancedJournals) > 0 || report.TotalDebit != report.TotalCredit {
+		log.Printf("对账完成: %d 项差异未修正，%d 个不平衡凭证", unfixed, len(report.UnbalancedJournals))
+		os.Exit(1)
+	}
+	log.Printf("✅ 对账完成: 账户余额与分录一致（差异 %d 项，已全部修正）", len(report.Mismatches))
+}
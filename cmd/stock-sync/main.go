@@ -2,21 +2,22 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
+	"strconv"
 	"time"
 
 	radix "github.com/mediocregopher/radix/v3"
 
 	"github.com/example/goseckill/internal/config"
 	"github.com/example/goseckill/internal/datamodels/product"
+	"github.com/example/goseckill/internal/datamodels/seckill_activity"
 	"github.com/example/goseckill/internal/infra/redis"
 	"github.com/example/goseckill/internal/repository/mysql"
+	"github.com/example/goseckill/internal/service"
 )
 
 const (
-	redisSeckillStockKey = "seckill:stock:%d" // productID
-	checkInterval        = 5 * time.Minute    // 每5分钟检查一次
+	checkInterval = 5 * time.Minute // 每5分钟检查一次
 )
 
 func main() {
@@ -25,6 +26,7 @@ func main() {
 	db := mysql.Init(&cfg.MySQL)
 	redisClient := redis.Init(&cfg.Redis)
 	productRepo := mysql.NewProductRepository(db)
+	activityRepo := mysql.NewSeckillActivityRepository(db)
 
 	log.Println("库存一致性检查服务启动...")
 	log.Printf("检查间隔: %v", checkInterval)
@@ -33,66 +35,87 @@ func main() {
 	defer ticker.Stop()
 
 	// 立即执行一次
-	checkAndSync(context.Background(), productRepo, redisClient)
+	checkAndSync(context.Background(), productRepo, activityRepo, redisClient)
 
 	// 定时执行
 	for range ticker.C {
-		checkAndSync(context.Background(), productRepo, redisClient)
+		checkAndSync(context.Background(), productRepo, activityRepo, redisClient)
 	}
 }
 
-func checkAndSync(ctx context.Context, productRepo product.Repository, redisClient radix.Client) {
+// checkAndSync 检查进行中活动的每个商品：MySQL 秒杀库存与 Redis 中该活动的库存键是否一致
+func checkAndSync(ctx context.Context, productRepo product.Repository, activityRepo seckill_activity.Repository, redisClient radix.Client) {
 	log.Println("开始检查库存一致性...")
 
-	// 获取所有商品
-	products, err := productRepo.ListAll(ctx)
+	activities, err := activityRepo.ListAll(ctx)
 	if err != nil {
-		log.Printf("获取商品列表失败: %v", err)
+		log.Printf("获取活动列表失败: %v", err)
 		return
 	}
 
 	inconsistentCount := 0
 	syncedCount := 0
 
-	// 只检查秒杀中的商品（Status=2）
-	for _, p := range products {
-		if p.Status != 2 {
-			continue // 跳过非秒杀商品
-		}
-
-		if err := checkProductStock(ctx, p.ID, p.SeckillStock, redisClient); err != nil {
-			log.Printf("检查商品 %d 失败: %v", p.ID, err)
+	// 只检查进行中的活动；暂停的活动库存保留不动，结束/取消的活动已结算
+	for _, a := range activities {
+		if a.Status != seckill_activity.StatusRunning {
 			continue
 		}
-
-		stockKey := fmt.Sprintf(redisSeckillStockKey, p.ID)
-		var redisStock int
-		if err := redisClient.Do(radix.Cmd(&redisStock, "GET", stockKey)); err != nil {
-			// Redis中没有，需要同步
-			if err := syncStockToRedis(ctx, p.ID, p.SeckillStock, redisClient); err == nil {
-				syncedCount++
-			}
+		products, err := activityRepo.GetProductsByActivity(ctx, a.ID)
+		if err != nil {
+			log.Printf("获取活动 %d 的商品失败: %v", a.ID, err)
 			continue
 		}
-
-		if int64(redisStock) != p.SeckillStock {
+		for _, ap := range products {
+			p, err := productRepo.GetByID(ctx, ap.ProductID)
+			if err != nil {
+				log.Printf("获取商品 %d 失败: %v", ap.ProductID, err)
+				continue
+			}
+			consistent, err := checkProductStock(ctx, p, a.ID, redisClient)
+			if err != nil {
+				log.Printf("检查活动 %d 商品 %d 失败: %v", a.ID, p.ID, err)
+				continue
+			}
+			if consistent {
+				continue
+			}
 			inconsistentCount++
-			log.Printf("⚠️  商品 %d (%s): 库存不一致 - MySQL: %d, Redis: %d", p.ID, p.Name, p.SeckillStock, redisStock)
 			// 以MySQL为准，同步到Redis
-			if err := syncStockToRedis(ctx, p.ID, p.SeckillStock, redisClient); err == nil {
-				syncedCount++
-				log.Printf("✅ 商品 %d: 已修复库存不一致", p.ID)
+			if err := syncStockToRedis(ctx, p.ID, a.ID, p.SeckillStock, redisClient); err != nil {
+				log.Printf("同步活动 %d 商品 %d 库存失败: %v", a.ID, p.ID, err)
+				continue
 			}
+			syncedCount++
+			log.Printf("✅ 活动 %d 商品 %d: 已修复库存不一致", a.ID, p.ID)
 		}
 	}
 
 	log.Printf("库存一致性检查完成 - 发现不一致: %d 个, 已修复: %d 个", inconsistentCount, syncedCount)
 }
 
-func syncStockToRedis(ctx context.Context, productID int64, stock int64, redisClient radix.Client) error {
-	stockKey := fmt.Sprintf(redisSeckillStockKey, productID)
-	if err := redisClient.Do(radix.FlatCmd(nil, "SET", stockKey, stock)); err != nil {
-		return fmt.Errorf("同步库存到Redis失败: %v", err)
+// checkProductStock 比较商品的 MySQL 秒杀库存与 Redis 中该活动的库存，Redis 中没有记录也视为不一致
+func checkProductStock(ctx context.Context, p *product.Product, activityID int64, redisClient radix.Client) (bool, error) {
+	var stockStr string
+	mn := radix.MaybeNil{Rcv: &stockStr}
+	if err := redisClient.Do(radix.Cmd(&mn, "GET", service.SeckillStockKey(p.ID, activityID))); err != nil {
+		return false, err
+	}
+	if mn.Nil {
+		log.Printf("⚠️  活动 %d 商品 %d (%s): Redis 中没有秒杀库存", activityID, p.ID, p.Name)
+		return false, nil
+	}
+	redisStock, err := strconv.ParseInt(stockStr, 10, 64)
+	if err != nil {
+		return false, err
 	}
-	return nil
+	if redisStock != p.SeckillStock {
+		log.Printf("⚠️  活动 %d 商品 %d (%s): 库存不一致 - MySQL: %d, Redis: %d", activityID, p.ID, p.Name, p.SeckillStock, redisStock)
+		return false, nil
+	}
+	return true, nil
+}
+
+func syncStockToRedis(ctx context.Context, productID, activityID, stock int64, redisClient radix.Client) error {
+	return redisClient.Do(radix.FlatCmd(nil, "SET", service.SeckillStockKey(productID, activityID), stock))
 }
@@ -23,7 +23,7 @@ func main() {
 	db := mysql.Init(&cfg.MySQL)
 	activityRepo := mysql.NewSeckillActivityRepository(db)
 	productRepo := mysql.NewProductRepository(db)
-	activitySvc := service.NewSeckillActivityService(activityRepo, productRepo)
+	activitySvc := service.NewSeckillActivityService(activityRepo, productRepo, nil, nil)
 
 	ctx := context.Background()
 
@@ -54,7 +54,7 @@ func main() {
 
 	// 3. 执行过期检查
 	fmt.Println("\n3. 执行过期检查...")
-	if err := activitySvc.CheckAndUpdateExpiredActivities(ctx); err != nil {
+	if _, err := activitySvc.SyncActivityStates(ctx, nil); err != nil {
 		fmt.Printf("❌ 过期检查失败: %v\n", err)
 		return
 	}
@@ -18,7 +18,8 @@ func main() {
 
 	// 构建一致性哈希环与缓存
 	ring := auth.NewConsistentHashRing(cfg.Auth.Nodes, cfg.Auth.HashReplicas)
-	cache := auth.NewTokenCache(rdb, ring, time.Duration(cfg.Auth.TokenCacheTTLSeconds)*time.Second)
+	revocations := auth.NewRevocationList(rdb, time.Duration(cfg.JWT.AccessTTLSeconds)*time.Second)
+	cache := auth.NewTokenCache(rdb, ring, time.Duration(cfg.Auth.TokenCacheTTLSeconds)*time.Second, revocations)
 
 	token, err := auth.GenerateToken(&cfg.JWT, 12345, "demo-user")
 	if err != nil {
@@ -44,4 +45,14 @@ func main() {
 		log.Fatalf("cache get failed: %v", err)
 	}
 	fmt.Printf("二次命中缓存=%v, user_id=%d, username=%s\n", ok, cached.UserID, cached.Username)
+
+	// 吊销后即使缓存仍在也不再命中
+	if err := revocations.Revoke(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		log.Fatalf("revoke token failed: %v", err)
+	}
+	_, ok, err = cache.Get(ctx, token)
+	if err != nil {
+		log.Fatalf("cache get failed: %v", err)
+	}
+	fmt.Printf("吊销后命中缓存=%v\n", ok)
 }
@@ -99,17 +99,17 @@ func main() {
 
 	// 6. 验证结果
 	fmt.Println("\n=== 测试结果 ===")
-	if resp1.Code == 0 && resp2.Code == 400 && resp2.Msg == "duplicate seckill" {
+	if resp1.Code == 0 && resp2.Code == 400 && resp2.Msg == "请勿重复抢购" {
 		fmt.Println("✅ 幂等性测试通过！")
 		fmt.Println("   - 第一次秒杀成功")
-		fmt.Println("   - 第二次秒杀被正确拒绝（duplicate seckill）")
+		fmt.Println("   - 第二次秒杀被正确拒绝（请勿重复抢购）")
 	} else {
 		fmt.Println("❌ 幂等性测试失败！")
 		fmt.Printf("   第一次响应: code=%d, msg=%s\n", resp1.Code, resp1.Msg)
 		fmt.Printf("   第二次响应: code=%d, msg=%s\n", resp2.Code, resp2.Msg)
 		fmt.Println("\n   预期结果:")
 		fmt.Println("   - 第一次: code=0 (成功)")
-		fmt.Println("   - 第二次: code=400, msg='duplicate seckill' (被拒绝)")
+		fmt.Println("   - 第二次: code=400, msg='请勿重复抢购' (被拒绝)")
 	}
 }
 
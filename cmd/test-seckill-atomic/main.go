@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	radix "github.com/mediocregopher/radix/v3"
+
+	"github.com/example/goseckill/internal/config"
+	redisInfra "github.com/example/goseckill/internal/infra/redis"
+	"github.com/example/goseckill/internal/service"
+)
+
+// 并发准入测试：直接对 Redis 并发调用 SeckillService.Admit，
+// 校验 Lua 脚本下库存、限购计数不会出现漂移。
+//
+// 场景：库存 50，40 个用户，每人限购 2；每个用户进行 3 轮，
+// 每轮生成一个新 path 并用同一个 path 并发提交 5 次。
+// 期望：
+//   - 恰好 50 次准入成功，库存归零且不为负
+//   - 每个用户的限购计数 <= 2，所有计数之和等于准入成功次数
+//   - 同一 path 最多成功一次，其余返回 duplicate
+const (
+	testProductID  = int64(900001)
+	testActivityID = int64(900001)
+	baseUserID     = int64(900000)
+
+	initStock   = 50
+	userCount   = 40
+	limit       = 2
+	rounds      = 3
+	concurrency = 5
+)
+
+func main() {
+	cfg := config.DefaultConfig()
+	rdb := redisInfra.Init(&cfg.Redis)
+	ctx := context.Background()
+
+	seckillSvc := service.NewSeckillService(nil, nil, rdb, nil, nil, nil, nil)
+
+	stockKey := service.SeckillStockKey(testProductID, testActivityID)
+	cleanup(rdb, stockKey)
+
+	if err := rdb.Do(radix.FlatCmd(nil, "SET", stockKey, initStock)); err != nil {
+		log.Fatalf("init stock failed: %v", err)
+	}
+
+	var (
+		admitted   int64
+		duplicates int64
+		limited    int64
+		soldOut    int64
+		others     int64
+		reused     int64 // 同一 path 准入多于一次的轮数
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < userCount; i++ {
+		userID := baseUserID + int64(i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				path, err := seckillSvc.GeneratePath(ctx, userID, testProductID)
+				if err != nil {
+					atomic.AddInt64(&others, 1)
+					continue
+				}
+				var roundWG sync.WaitGroup
+				var roundAdmitted int64
+				for c := 0; c < concurrency; c++ {
+					roundWG.Add(1)
+					go func() {
+						defer roundWG.Done()
+						err := seckillSvc.Admit(ctx, userID, testProductID, testActivityID, limit, path)
+						switch {
+						case err == nil:
+							atomic.AddInt64(&admitted, 1)
+							atomic.AddInt64(&roundAdmitted, 1)
+						case errors.Is(err, service.ErrSeckillDuplicate):
+							atomic.AddInt64(&duplicates, 1)
+						case errors.Is(err, service.ErrSeckillLimitExceeded):
+							atomic.AddInt64(&limited, 1)
+						case errors.Is(err, service.ErrSeckillSoldOut):
+							atomic.AddInt64(&soldOut, 1)
+						default:
+							atomic.AddInt64(&others, 1)
+						}
+					}()
+				}
+				roundWG.Wait()
+				if roundAdmitted > 1 {
+					atomic.AddInt64(&reused, 1)
+					fmt.Printf("❌ 用户 %d 同一 path 准入 %d 次\n", userID, roundAdmitted)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	fmt.Println("==========================================")
+	fmt.Println("    秒杀准入原子性测试")
+	fmt.Println("==========================================")
+	fmt.Printf("准入成功: %d, 重复提交: %d, 超出限购: %d, 库存不足: %d, 其他错误: %d\n",
+		admitted, duplicates, limited, soldOut, others)
+
+	passed := true
+	check := func(ok bool, msg string) {
+		if ok {
+			fmt.Println("✅ " + msg)
+		} else {
+			fmt.Println("❌ " + msg)
+			passed = false
+		}
+	}
+
+	var leftStr string
+	_ = rdb.Do(radix.Cmd(&leftStr, "GET", stockKey))
+	left, _ := strconv.ParseInt(leftStr, 10, 64)
+
+	var sumLimit int64
+	maxPerUser := int64(0)
+	for i := 0; i < userCount; i++ {
+		var usedStr string
+		key := fmt.Sprintf("seckill:limit:%d:%d:%d", baseUserID+int64(i), testProductID, testActivityID)
+		_ = rdb.Do(radix.Cmd(&usedStr, "GET", key))
+		used, _ := strconv.ParseInt(usedStr, 10, 64)
+		sumLimit += used
+		if used > maxPerUser {
+			maxPerUser = used
+		}
+	}
+
+	check(others == 0, "没有意外错误")
+	check(admitted == initStock, fmt.Sprintf("准入次数等于初始库存（%d）", initStock))
+	check(left == initStock-admitted, fmt.Sprintf("剩余库存 %d = 初始库存 - 准入次数", left))
+	check(left >= 0, "库存未出现负数")
+	check(sumLimit == admitted, fmt.Sprintf("限购计数之和 %d = 准入次数", sumLimit))
+	check(maxPerUser <= limit, fmt.Sprintf("单用户最大准入 %d <= 限购 %d", maxPerUser, limit))
+	check(reused == 0, "同一 path 最多准入一次")
+
+	// os.Exit 不会执行 defer，退出前显式清理
+	cleanup(rdb, stockKey)
+	if !passed {
+		fmt.Println("\n❌ 测试失败")
+		os.Exit(1)
+	}
+	fmt.Println("\n✅ 测试通过：并发下计数无漂移")
+}
+
+func cleanup(rdb radix.Client, stockKey string) {
+	keys := []string{stockKey}
+	for i := 0; i < userCount; i++ {
+		userID := baseUserID + int64(i)
+		keys = append(keys,
+			fmt.Sprintf("seckill:path:%d:%d", userID, testProductID),
+			fmt.Sprintf("seckill:limit:%d:%d:%d", userID, testProductID, testActivityID),
+		)
+	}
+	_ = rdb.Do(radix.Cmd(nil, "DEL", keys...))
+
+	// 请求去重键带 path 后缀，需要按前缀清理
+	for i := 0; i < userCount; i++ {
+		var found []string
+		pattern := fmt.Sprintf("seckill:req:%d:%d:*", baseUserID+int64(i), testProductID)
+		if err := rdb.Do(radix.Cmd(&found, "KEYS", pattern)); err == nil && len(found) > 0 {
+			_ = rdb.Do(radix.Cmd(nil, "DEL", found...))
+		}
+	}
+}
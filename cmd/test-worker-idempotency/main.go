@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	radix "github.com/mediocregopher/radix/v3"
+
+	"github.com/example/goseckill/internal/auth"
+	"github.com/example/goseckill/internal/config"
+	"github.com/example/goseckill/internal/datamodels/product"
+	"github.com/example/goseckill/internal/datamodels/seckill_ticket"
+	"github.com/example/goseckill/internal/infra/mq"
+	"github.com/example/goseckill/internal/infra/redis"
+	"github.com/example/goseckill/internal/repository/mysql"
+	"github.com/example/goseckill/internal/service"
+)
+
+// Worker 幂等性测试：使用进程内队列，把同一条秒杀消息重复投递多次，
+// 校验只生成一个订单、只扣一次款、只扣一次库存。
+// 依赖本地 MySQL 与 Redis（不需要 RabbitMQ）。
+const (
+	replayTimes  = 5
+	productPrice = 1000
+	initBalance  = 100000
+	initStock    = 10
+
+	// 等待 Worker 消费的最长时间与轮询间隔
+	waitTimeout  = 30 * time.Second
+	pollInterval = 100 * time.Millisecond
+)
+
+func main() {
+	cfg := config.DefaultConfig()
+	ctx := context.Background()
+
+	db := mysql.Init(&cfg.MySQL)
+	redisClient := redis.Init(&cfg.Redis)
+	broker := mq.NewMemoryBroker(100)
+	defer broker.Close()
+
+	productRepo := mysql.NewProductRepository(db)
+	orderRepo := mysql.NewOrderRepository(db)
+	userRepo := mysql.NewUserRepository(db)
+	accountSvc := service.NewAccountService(db, productRepo, orderRepo, userRepo, redisClient, nil, &cfg.Order)
+	userSvc := service.NewUserService(userRepo, auth.NewBcryptHasher(cfg.Password.BcryptCost), nil)
+	ticketSvc := service.NewSeckillTicketService(mysql.NewSeckillTicketRepository(db), nil)
+	deadLetterSvc := service.NewDeadLetterService(mysql.NewDeadLetterRepository(db), nil, ticketSvc, nil, accountSvc, redisClient, broker)
+	worker := service.NewSeckillWorker(productRepo, nil, accountSvc, nil, deadLetterSvc, ticketSvc, redisClient, broker, &cfg.Worker)
+
+	fmt.Println("==========================================")
+	fmt.Println("    Worker 幂等性测试")
+	fmt.Println("==========================================")
+
+	// 1. 准备用户、余额与商品
+	suffix := time.Now().UnixNano()
+	u, err := userSvc.Register(ctx, fmt.Sprintf("idem_%d", suffix), "testpass")
+	if err != nil {
+		log.Fatalf("register user failed: %v", err)
+	}
+	if _, err := accountSvc.Recharge(ctx, u.ID, initBalance); err != nil {
+		log.Fatalf("recharge failed: %v", err)
+	}
+	p := &product.Product{
+		Name:         fmt.Sprintf("幂等测试商品-%d", suffix),
+		Price:        productPrice,
+		Stock:        0,
+		SeckillStock: initStock,
+		StartTime:    time.Now().Add(-time.Hour),
+		EndTime:      time.Now().Add(time.Hour),
+		Status:       0, // 下线，避免出现在前台
+	}
+	if err := productRepo.Create(ctx, p); err != nil {
+		log.Fatalf("create product failed: %v", err)
+	}
+	defer productRepo.Delete(ctx, p.ID)
+	fmt.Printf("准备完成：user=%d product=%d\n", u.ID, p.ID)
+
+	requestID := fmt.Sprintf("idem-test-%d", suffix)
+	msg := &service.SeckillMessage{
+		RequestID: requestID,
+		UserID:    u.ID,
+		ProductID: p.ID,
+	}
+	body, _ := json.Marshal(msg)
+	// 与 web 端一致：准入成功后先写入排队中的凭证
+	if err := ticketSvc.Create(ctx, msg); err != nil {
+		log.Fatalf("create ticket failed: %v", err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := worker.Run(runCtx, broker); err != nil {
+			log.Printf("worker stopped: %v", err)
+		}
+	}()
+
+	// 2. 同一条消息投递多次：一次下单，其余按重复消息确认
+	baseDuplicates := workerDuplicates()
+	fmt.Printf("\n投递同一条消息 %d 次...\n", replayTimes)
+	replay(ctx, broker, body)
+	if err := waitIdle(ctx, ticketSvc, u.ID, requestID, baseDuplicates+replayTimes-1); err != nil {
+		log.Fatalf("wait worker failed: %v", err)
+	}
+
+	// 3. 清除 Redis 已处理标记后再次重放，验证订单表唯一约束兜底：每一条都按重复消息确认
+	fmt.Println("清除 Redis 已处理标记后再次投递...")
+	_ = redisClient.Do(radix.Cmd(nil, "DEL", "seckill:processed:"+requestID))
+	replay(ctx, broker, body)
+	if err := waitIdle(ctx, ticketSvc, u.ID, requestID, baseDuplicates+2*replayTimes-1); err != nil {
+		log.Fatalf("wait worker failed: %v", err)
+	}
+
+	cancel()
+	<-done
+
+	// 4. 校验结果
+	passed := true
+	check := func(ok bool, msg string) {
+		if ok {
+			fmt.Println("✅ " + msg)
+		} else {
+			fmt.Println("❌ " + msg)
+			passed = false
+		}
+	}
+
+	orders, err := orderRepo.ListByUser(ctx, u.ID)
+	if err != nil {
+		log.Fatalf("list orders failed: %v", err)
+	}
+	check(len(orders) == 1, fmt.Sprintf("只生成 1 个订单（实际 %d）", len(orders)))
+	if o, err := orderRepo.GetByRequestID(ctx, requestID); err == nil {
+		check(o.Price == productPrice, fmt.Sprintf("订单金额 %d", o.Price))
+		if t, err := ticketSvc.Get(ctx, u.ID, requestID); err == nil {
+			check(service.SeckillTicketStatusName(t.Status) == "succeeded" && t.OrderID == o.ID,
+				fmt.Sprintf("凭证状态 %s，订单 %d", service.SeckillTicketStatusName(t.Status), t.OrderID))
+		} else {
+			check(false, "按凭证查询结果: "+err.Error())
+		}
+	} else {
+		check(false, "按 request_id 查询订单: "+err.Error())
+	}
+
+	acc, err := accountSvc.GetSummary(ctx, u.ID)
+	if err != nil {
+		log.Fatalf("get account failed: %v", err)
+	}
+	check(acc.Balance == initBalance-productPrice, fmt.Sprintf("只扣款一次（余额 %d）", acc.Balance))
+
+	latest, err := productRepo.GetByID(ctx, p.ID)
+	if err != nil {
+		log.Fatalf("get product failed: %v", err)
+	}
+	check(latest.SeckillStock == initStock-1, fmt.Sprintf("只扣减一次库存（剩余 %d）", latest.SeckillStock))
+
+	stats := service.GetMonitor().GetStats()["performance"].(map[string]interface{})
+	fmt.Printf("Worker 统计：processed=%v duplicates=%v\n", stats["worker_processed"], stats["worker_duplicates"])
+
+	if !passed {
+		fmt.Println("\n❌ 测试失败")
+		// os.Exit 不会执行 defer，退出前显式清理测试商品
+		_ = productRepo.Delete(ctx, p.ID)
+		os.Exit(1)
+	}
+	fmt.Println("\n✅ 测试通过：重复投递不会产生副作用")
+}
+
+func replay(ctx context.Context, broker mq.Publisher, body []byte) {
+	for i := 0; i < replayTimes; i++ {
+		if err := broker.Publish(ctx, service.SeckillQueue, &mq.Message{Body: body}); err != nil {
+			log.Fatalf("publish failed: %v", err)
+		}
+	}
+}
+
+// waitIdle 轮询直到凭证进入最终状态、且 Worker 确认的重复消息数达到 wantDuplicates，超时返回错误
+func waitIdle(ctx context.Context, ticketSvc *service.SeckillTicketService, userID int64, requestID string, wantDuplicates int64) error {
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		t, err := ticketSvc.Get(ctx, userID, requestID)
+		if err == nil && t.Status != seckill_ticket.StatusQueued && workerDuplicates() >= wantDuplicates {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			status := "unknown"
+			if err == nil {
+				status = service.SeckillTicketStatusName(t.Status)
+			}
+			return fmt.Errorf("timeout after %s: ticket=%s duplicates=%d/%d", waitTimeout, status, workerDuplicates(), wantDuplicates)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// workerDuplicates Worker 已确认的重复消息数
+func workerDuplicates() int64 {
+	stats := service.GetMonitor().GetStats()["performance"].(map[string]interface{})
+	return stats["worker_duplicates"].(int64)
+}
@@ -137,6 +137,8 @@ After=network.target mysql.service redis-server.service rabbitmq-server.service
 Type=simple
 User=$SERVICE_USER
 WorkingDirectory=$PROJECT_DIR
+# 访问令牌密钥（GOSECKILL_JWT_KEYS_FILE 或 GOSECKILL_JWT_SECRET），缺少时服务拒绝启动
+EnvironmentFile=/etc/goseckill/web.env
 ExecStart=$PROJECT_DIR/bin/web
 Restart=always
 RestartSec=5
@@ -157,6 +159,8 @@ After=network.target mysql.service redis-server.service rabbitmq-server.service
 Type=simple
 User=$SERVICE_USER
 WorkingDirectory=$PROJECT_DIR
+# 后台令牌密钥与初始管理员密码（GOSECKILL_ADMIN_JWT_SECRET / GOSECKILL_ADMIN_BOOTSTRAP_PASSWORD），缺少时服务拒绝启动
+EnvironmentFile=/etc/goseckill/admin.env
 ExecStart=$PROJECT_DIR/bin/admin
 Restart=always
 RestartSec=5
@@ -7,6 +7,7 @@ require (
 	github.com/kataras/iris/v12 v12.2.0
 	github.com/mediocregopher/radix/v3 v3.8.1
 	github.com/rabbitmq/amqp091-go v1.10.0
+	golang.org/x/crypto v0.7.0
 	gorm.io/driver/mysql v1.5.7
 	gorm.io/gorm v1.25.10
 )
@@ -59,7 +60,6 @@ require (
 	github.com/vmihailenco/msgpack/v5 v5.3.5 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	github.com/yosssi/ace v0.0.5 // indirect
-	golang.org/x/crypto v0.7.0 // indirect
 	golang.org/x/net v0.8.0 // indirect
 	golang.org/x/sys v0.6.0 // indirect
 	golang.org/x/text v0.8.0 // indirect
@@ -1,6 +1,9 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -8,32 +11,70 @@ import (
 	"github.com/example/goseckill/internal/config"
 )
 
+// 签发时间等时间字段按毫秒编码（RFC 7519 允许小数），
+// 退出所有设备后在同一秒内重新登录签发的令牌不会被吊销时间点误伤
+func init() {
+	jwt.TimePrecision = time.Millisecond
+}
+
 type Claims struct {
-	UserID   int64  `json:"user_id"`
-	Username string `json:"username"`
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	SessionID string `json:"sid,omitempty"` // 所属登录会话（刷新令牌族），退出登录时整族吊销
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成 JWT
+// GenerateToken 生成不属于任何登录会话的访问令牌
 func GenerateToken(cfg *config.JWTConfig, userID int64, username string) (string, error) {
+	token, _, err := IssueAccessToken(cfg, userID, username, "")
+	return token, err
+}
+
+// IssueAccessToken 签发访问令牌，带唯一的 jti 供吊销使用，有效期为 cfg.AccessTTLSeconds（未配置时 15 分钟）。
+// 令牌由密钥集合中的当前签名密钥签名，头部带 kid。
+func IssueAccessToken(cfg *config.JWTConfig, userID int64, username, sessionID string) (string, *Claims, error) {
+	keys, err := LoadKeySet(cfg)
+	if err != nil {
+		return "", nil, err
+	}
+	ttl := time.Duration(cfg.AccessTTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
 	now := time.Now()
-	claims := Claims{
-		UserID:   userID,
-		Username: username,
+	claims := &Claims{
+		UserID:    userID,
+		Username:  username,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        NewTokenID(),
 			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(2 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(cfg.Secret))
+	signed, err := keys.Sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
+}
+
+// NewTokenID 生成随机令牌ID（jti、会话ID）
+func NewTokenID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
 }
 
-// ParseToken 解析 JWT
+// ParseToken 解析 JWT，按 kid 选择校验密钥，只接受密钥集合中配置的算法
 func ParseToken(cfg *config.JWTConfig, tokenStr string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(cfg.Secret), nil
-	})
+	keys, err := LoadKeySet(cfg)
+	if err != nil {
+		return nil, err
+	}
+	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, keys.Keyfunc, jwt.WithValidMethods(keys.Methods()))
 	if err != nil {
 		return nil, err
 	}
@@ -43,4 +84,48 @@ func ParseToken(cfg *config.JWTConfig, tokenStr string) (*Claims, error) {
 	return nil, jwt.ErrTokenInvalidClaims
 }
 
+// adminAudience 管理员令牌的 aud，防止前台令牌在后台通过校验
+const adminAudience = "goseckill-admin"
 
+// AdminClaims 后台管理员令牌
+type AdminClaims struct {
+	AdminID  int64  `json:"admin_id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// GenerateAdminToken 生成管理员 JWT
+func GenerateAdminToken(cfg *config.AdminAuthConfig, adminID int64, username, role string) (string, error) {
+	now := time.Now()
+	ttl := time.Duration(cfg.TokenTTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = 8 * time.Hour
+	}
+	claims := AdminClaims{
+		AdminID:  adminID,
+		Username: username,
+		Role:     role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{adminAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(cfg.JWTSecret))
+}
+
+// ParseAdminToken 解析管理员 JWT，只接受 HS256 与管理员 aud
+func ParseAdminToken(cfg *config.AdminAuthConfig, tokenStr string) (*AdminClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &AdminClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte(cfg.JWTSecret), nil
+	}, jwt.WithAudience(adminAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if claims, ok := token.Claims.(*AdminClaims); ok && token.Valid {
+		return claims, nil
+	}
+	return nil, jwt.ErrTokenInvalidClaims
+}
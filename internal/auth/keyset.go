@@ -0,0 +1,247 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/example/goseckill/internal/config"
+)
+
+// DefaultKeyID 旧版单一密钥 JWTConfig.Secret 对应的 kid，没有 kid 头部的旧令牌用它校验
+const DefaultKeyID = "default"
+
+// minHMACSecretLen HS256 密钥的最小长度（字节）
+const minHMACSecretLen = 32
+
+var (
+	// ErrUnknownKeyID 令牌头部的 kid 不在密钥集合中（密钥已下线或令牌伪造）
+	ErrUnknownKeyID = errors.New("unknown jwt key id")
+	// ErrNoSigningKey 签名密钥不存在或只有公钥
+	ErrNoSigningKey = errors.New("jwt signing key not available")
+)
+
+// signingKey 密钥集合中的一个密钥，sign 为空表示只能校验
+type signingKey struct {
+	id     string
+	method jwt.SigningMethod
+	sign   interface{}
+	verify interface{}
+}
+
+// KeySet JWT 密钥集合：一个签名密钥（写入 kid 头部）与若干校验密钥。
+// 轮换时先加入新密钥、再切换签名密钥，旧密钥保留到它签发的令牌全部过期后再移除。
+type KeySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+// keySets 按配置缓存解析好的密钥集合，避免每次签发/校验都解析 PEM
+var keySets sync.Map // *config.JWTConfig -> *KeySet
+
+// LoadKeySet 解析配置中的密钥集合，结果按配置缓存；服务启动时调用可以尽早发现配置错误
+func LoadKeySet(cfg *config.JWTConfig) (*KeySet, error) {
+	if ks, ok := keySets.Load(cfg); ok {
+		return ks.(*KeySet), nil
+	}
+	ks, err := NewKeySet(cfg)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := keySets.LoadOrStore(cfg, ks)
+	return actual.(*KeySet), nil
+}
+
+// NewKeySet 根据配置构建密钥集合，配置了密钥文件时合并文件中的密钥；一个密钥都没有配置时返回错误
+func NewKeySet(cfg *config.JWTConfig) (*KeySet, error) {
+	keyConfigs, activeID := cfg.Keys, cfg.ActiveKeyID
+	if cfg.KeysFile != "" {
+		f, err := config.LoadJWTKeysFile(cfg.KeysFile)
+		if err != nil {
+			return nil, err
+		}
+		keyConfigs = append(append([]config.JWTKeyConfig(nil), keyConfigs...), f.Keys...)
+		if activeID == "" {
+			activeID = f.ActiveKeyID
+		}
+	}
+	if len(keyConfigs) == 0 && cfg.Secret == "" {
+		return nil, fmt.Errorf("jwt signing key not configured: set %s or %s", config.EnvJWTSecret, config.EnvJWTKeysFile)
+	}
+
+	ks := &KeySet{keys: make(map[string]*signingKey, len(keyConfigs)+1)}
+	for _, kc := range keyConfigs {
+		if kc.ID == "" {
+			return nil, errors.New("jwt key id is required")
+		}
+		if _, dup := ks.keys[kc.ID]; dup {
+			return nil, fmt.Errorf("duplicate jwt key id %q", kc.ID)
+		}
+		k, err := parseKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", kc.ID, err)
+		}
+		ks.keys[kc.ID] = k
+	}
+	if _, ok := ks.keys[DefaultKeyID]; !ok && cfg.Secret != "" {
+		if len(cfg.Secret) < minHMACSecretLen {
+			return nil, fmt.Errorf("%s must be at least %d bytes", config.EnvJWTSecret, minHMACSecretLen)
+		}
+		ks.keys[DefaultKeyID] = &signingKey{
+			id:     DefaultKeyID,
+			method: jwt.SigningMethodHS256,
+			sign:   []byte(cfg.Secret),
+			verify: []byte(cfg.Secret),
+		}
+	}
+
+	if activeID == "" {
+		activeID = DefaultKeyID
+		if len(keyConfigs) > 0 {
+			activeID = keyConfigs[0].ID
+		}
+	}
+	active, ok := ks.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active jwt key %q not configured", activeID)
+	}
+	if active.sign == nil {
+		return nil, fmt.Errorf("active jwt key %q: %w", activeID, ErrNoSigningKey)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+// parseKey 按算法解析密钥，非对称算法只配置私钥时由私钥推导公钥
+func parseKey(kc config.JWTKeyConfig) (*signingKey, error) {
+	k := &signingKey{id: kc.ID}
+	switch kc.Algorithm {
+	case "", jwt.SigningMethodHS256.Alg():
+		if len(kc.Secret) < minHMACSecretLen {
+			return nil, fmt.Errorf("HS256 key requires a secret of at least %d bytes", minHMACSecretLen)
+		}
+		k.method = jwt.SigningMethodHS256
+		k.sign, k.verify = []byte(kc.Secret), []byte(kc.Secret)
+	case jwt.SigningMethodRS256.Alg():
+		k.method = jwt.SigningMethodRS256
+		if kc.PrivateKeyPEM != "" {
+			priv, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(kc.PrivateKeyPEM))
+			if err != nil {
+				return nil, err
+			}
+			k.sign, k.verify = priv, &priv.PublicKey
+		}
+		if kc.PublicKeyPEM != "" {
+			pub, err := jwt.ParseRSAPublicKeyFromPEM([]byte(kc.PublicKeyPEM))
+			if err != nil {
+				return nil, err
+			}
+			k.verify = pub
+		}
+	case jwt.SigningMethodEdDSA.Alg():
+		k.method = jwt.SigningMethodEdDSA
+		if kc.PrivateKeyPEM != "" {
+			priv, err := jwt.ParseEdPrivateKeyFromPEM([]byte(kc.PrivateKeyPEM))
+			if err != nil {
+				return nil, err
+			}
+			k.sign, k.verify = priv, priv.(ed25519.PrivateKey).Public()
+		}
+		if kc.PublicKeyPEM != "" {
+			pub, err := jwt.ParseEdPublicKeyFromPEM([]byte(kc.PublicKeyPEM))
+			if err != nil {
+				return nil, err
+			}
+			k.verify = pub
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", kc.Algorithm)
+	}
+	if k.verify == nil {
+		return nil, errors.New("private or public key PEM is required")
+	}
+	return k, nil
+}
+
+// ActiveKeyID 当前签名密钥的 kid
+func (ks *KeySet) ActiveKeyID() string {
+	return ks.active.id
+}
+
+// Sign 用当前签名密钥签名，并在头部写入 kid
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	token.Header["kid"] = ks.active.id
+	return token.SignedString(ks.active.sign)
+}
+
+// Keyfunc 按令牌头部的 kid 选择校验密钥，没有 kid 的旧令牌使用 DefaultKeyID。
+// 令牌的算法必须与密钥的算法一致，防止用公钥充当 HMAC 密钥之类的算法混淆攻击。
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = DefaultKeyID
+	}
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	return k.verify, nil
+}
+
+// Methods 密钥集合中出现的全部算法，用于限制可接受的令牌算法
+func (ks *KeySet) Methods() []string {
+	seen := make(map[string]bool, len(ks.keys))
+	methods := make([]string, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		if alg := k.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// JWK 公钥的 JSON Web Key 表示（RFC 7517），供其他服务只用公钥校验令牌
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`   // RSA 模数
+	E   string `json:"e,omitempty"`   // RSA 公钥指数
+	Crv string `json:"crv,omitempty"` // Ed25519
+	X   string `json:"x,omitempty"`   // Ed25519 公钥
+}
+
+// PublicJWKs 导出全部非对称密钥的公钥；HMAC 密钥不能公开，不包含在内
+func (ks *KeySet) PublicJWKs() []JWK {
+	list := make([]JWK, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		switch pub := k.verify.(type) {
+		case *rsa.PublicKey:
+			list = append(list, JWK{
+				Kty: "RSA", Kid: k.id, Alg: k.method.Alg(), Use: "sig",
+				N: base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			list = append(list, JWK{
+				Kty: "OKP", Kid: k.id, Alg: k.method.Alg(), Use: "sig",
+				Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Kid < list[j].Kid })
+	return list
+}
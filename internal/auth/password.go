@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher 密码哈希算法。生成的哈希自带随机盐与参数，Salt 字段不再需要。
+type PasswordHasher interface {
+	// Hash 计算密码哈希
+	Hash(password string) (string, error)
+	// Verify 校验密码是否与哈希匹配
+	Verify(hash, password string) bool
+	// NeedsRehash 哈希是否由本算法以当前参数生成，否则应在下次登录成功时重新计算
+	NeedsRehash(hash string) bool
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher 创建 bcrypt 密码哈希，cost 不合法时使用 bcrypt.DefaultCost
+func NewBcryptHasher(cost int) PasswordHasher {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+	return &bcryptHasher{cost: cost}
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (h *bcryptHasher) Verify(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func (h *bcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost
+}
+
+// isLegacyHash 是否为旧版 hex(SHA-256(password + salt)) 哈希
+func isLegacyHash(hash string) bool {
+	if len(hash) != sha256.Size*2 || strings.HasPrefix(hash, "$") {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// VerifyPassword 校验密码并判断是否需要迁移：旧版 SHA-256 哈希按 salt 校验，通过时 rehash 为 true；
+// 其他哈希交给 h 校验，参数（如 bcrypt cost）变化时 rehash 为 true。rehash 只在 ok 为 true 时有意义。
+func VerifyPassword(h PasswordHasher, hash, salt, password string) (ok, rehash bool) {
+	if isLegacyHash(hash) {
+		sum := sha256.Sum256([]byte(password + salt))
+		legacy := hex.EncodeToString(sum[:])
+		ok = subtle.ConstantTimeCompare([]byte(legacy), []byte(hash)) == 1
+		return ok, ok
+	}
+	if !h.Verify(hash, password) {
+		return false, false
+	}
+	return true, h.NeedsRehash(hash)
+}
@@ -0,0 +1,63 @@
+package auth
+
+// 管理员角色
+const (
+	RoleAdmin    = "admin"    // 超级管理员：全部权限，含管理员账号管理
+	RoleOperator = "operator" // 运营：商品、秒杀活动、优惠券、死信与客服
+	RoleFinance  = "finance"  // 财务：充值与退款
+	RoleReadOnly = "readonly" // 只读：只能查看
+)
+
+// Permission 后台接口权限
+type Permission string
+
+// 后台接口权限，每个路由组要求其中一项
+const (
+	PermRead            Permission = "read"             // 查看商品、订单、用户、活动、优惠券、死信
+	PermProductWrite    Permission = "product:write"    // 新增/修改商品
+	PermActivityWrite   Permission = "activity:write"   // 创建/修改/发布/删除秒杀活动
+	PermCouponWrite     Permission = "coupon:write"     // 创建优惠券模板、发放优惠券
+	PermDeadLetterWrite Permission = "deadletter:write" // 重新投递/丢弃死信
+	PermChat            Permission = "chat"             // 客服聊天
+	PermAccountRecharge Permission = "account:recharge" // 给用户充值
+	PermOrderRefund     Permission = "order:refund"     // 订单退款
+	PermAdminManage     Permission = "admin:manage"     // 管理员账号管理
+	PermAuditRead       Permission = "audit:read"       // 查看审计日志
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleOperator: {PermRead, PermProductWrite, PermActivityWrite, PermCouponWrite, PermDeadLetterWrite, PermChat},
+	RoleFinance:  {PermRead, PermAccountRecharge, PermOrderRefund},
+	RoleReadOnly: {PermRead},
+}
+
+// ValidRole 是否为已定义的角色
+func ValidRole(role string) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission 角色是否拥有权限，超级管理员拥有全部权限，未知角色没有任何权限
+func HasPermission(role string, p Permission) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	for _, granted := range rolePermissions[role] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions 角色拥有的权限列表，供前端按权限显示菜单
+func Permissions(role string) []Permission {
+	if role == RoleAdmin {
+		return []Permission{PermRead, PermProductWrite, PermActivityWrite, PermCouponWrite, PermDeadLetterWrite,
+			PermChat, PermAccountRecharge, PermOrderRefund, PermAdminManage, PermAuditRead}
+	}
+	return append([]Permission(nil), rolePermissions[role]...)
+}
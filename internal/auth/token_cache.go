@@ -11,15 +11,17 @@ import (
 	radix "github.com/mediocregopher/radix/v3"
 )
 
-// TokenCache 基于一致性哈希的 JWT 解析结果缓存，加速分布式鉴权
+// TokenCache 基于一致性哈希的 JWT 解析结果缓存，加速分布式鉴权。
+// 命中缓存时仍会检查吊销列表，已吊销的令牌不会因为缓存而继续有效。
 type TokenCache struct {
-	redis radix.Client
-	ring  *ConsistentHashRing
-	ttl   time.Duration
+	redis       radix.Client
+	ring        *ConsistentHashRing
+	ttl         time.Duration
+	revocations *RevocationList
 }
 
-// NewTokenCache 构建缓存器
-func NewTokenCache(redis radix.Client, ring *ConsistentHashRing, ttl time.Duration) *TokenCache {
+// NewTokenCache 构建缓存器，revocations 可为 nil（不检查吊销）
+func NewTokenCache(redis radix.Client, ring *ConsistentHashRing, ttl time.Duration, revocations *RevocationList) *TokenCache {
 	if ring == nil {
 		ring = NewConsistentHashRing(nil, 0)
 	}
@@ -27,9 +29,10 @@ func NewTokenCache(redis radix.Client, ring *ConsistentHashRing, ttl time.Durati
 		ttl = 10 * time.Minute
 	}
 	return &TokenCache{
-		redis: redis,
-		ring:  ring,
-		ttl:   ttl,
+		redis:       redis,
+		ring:        ring,
+		ttl:         ttl,
+		revocations: revocations,
 	}
 }
 
@@ -39,7 +42,7 @@ func (c *TokenCache) cacheKey(token string) string {
 	return fmt.Sprintf("auth:jwt:%s:%s", node, hex.EncodeToString(sum[:]))
 }
 
-// Get 尝试命中缓存的 claims
+// Get 尝试命中缓存的 claims，令牌已吊销或吊销状态无法确认时视为未命中
 func (c *TokenCache) Get(ctx context.Context, token string) (*Claims, bool, error) {
 	if c.redis == nil {
 		return nil, false, nil
@@ -58,18 +61,41 @@ func (c *TokenCache) Get(ctx context.Context, token string) (*Claims, bool, erro
 		_ = c.redis.Do(radix.Cmd(nil, "DEL", key))
 		return nil, false, nil
 	}
+	if revoked, err := c.revocations.IsRevoked(ctx, &claims); err != nil || revoked {
+		if revoked {
+			_ = c.redis.Do(radix.Cmd(nil, "DEL", key))
+		}
+		return nil, false, err
+	}
 	return &claims, true, nil
 }
 
-// Set 缓存解析结果
+// Set 缓存解析结果，缓存时间不超过令牌的剩余有效期
 func (c *TokenCache) Set(ctx context.Context, token string, claims *Claims) error {
 	if c.redis == nil || claims == nil {
 		return nil
 	}
+	ttl := c.ttl
+	if claims.ExpiresAt != nil {
+		if remaining := time.Until(claims.ExpiresAt.Time); remaining < ttl {
+			ttl = remaining
+		}
+	}
+	if ttl < time.Second {
+		return nil
+	}
 	key := c.cacheKey(token)
 	body, _ := json.Marshal(claims)
-	if err := c.redis.Do(radix.FlatCmd(nil, "SETEX", key, int64(c.ttl/time.Second), body)); err != nil {
+	if err := c.redis.Do(radix.FlatCmd(nil, "SETEX", key, int64(ttl/time.Second), body)); err != nil {
 		return err
 	}
 	return nil
 }
+
+// Delete 删除令牌的缓存（退出登录时调用）
+func (c *TokenCache) Delete(ctx context.Context, token string) error {
+	if c.redis == nil {
+		return nil
+	}
+	return c.redis.Do(radix.Cmd(nil, "DEL", c.cacheKey(token)))
+}
@@ -7,10 +7,10 @@ import (
 
 // Account 用户账户余额
 type Account struct {
-	ID        int64     `gorm:"primaryKey"`
-	UserID    int64     `gorm:"uniqueIndex;not null"`
-	Balance   int64     `gorm:"not null"` // 可用余额，单位：分
-	Frozen    int64     `gorm:"not null"` // 冻结金额，单位：分
+	ID        int64 `gorm:"primaryKey"`
+	UserID    int64 `gorm:"uniqueIndex;not null"`
+	Balance   int64 `gorm:"not null"` // 可用余额，单位：分
+	Frozen    int64 `gorm:"not null"` // 冻结金额，单位：分
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -19,13 +19,37 @@ type Account struct {
 type Transaction struct {
 	ID        int64     `gorm:"primaryKey"`
 	UserID    int64     `gorm:"index;not null"`
-	Amount    int64     `gorm:"not null"`       // 正数入账，负数出账，单位分
-	Type      string    `gorm:"size:32;index"`  // purchase / refund / recharge 等
-	Status    string    `gorm:"size:32;index"`  // success / failed / pending
-	Note      string    `gorm:"size:255"`       // 备注
+	Amount    int64     `gorm:"not null"`           // 可用余额变化：正数入账，负数出账，单位分
+	Frozen    int64     `gorm:"not null;default:0"` // 冻结金额变化：正数冻结，负数解冻或转为支付，单位分
+	Type      string    `gorm:"size:32;index"`      // purchase / seckill / refund / recharge / freeze / unfreeze 等
+	Status    string    `gorm:"size:32;index"`      // success / failed / pending
+	Note      string    `gorm:"size:255"`           // 备注
+	CouponID  int64     `gorm:"not null;default:0"` // 本次支付使用的优惠券
 	CreatedAt time.Time `gorm:"index"`
 }
 
+// 冻结状态
+const (
+	FreezeStatusFrozen   = 0 // 已冻结，等待下单结算
+	FreezeStatusSettled  = 1 // 已转为订单支付
+	FreezeStatusReleased = 2 // 已解冻，退回可用余额
+)
+
+// Freeze 秒杀准入时从可用余额冻结的资金，每个秒杀请求一条。
+// 下单支付后转为 settled，下单失败或待支付订单取消后转为 released。
+type Freeze struct {
+	ID        int64  `gorm:"primaryKey"`
+	UserID    int64  `gorm:"index;not null"`
+	RequestID string `gorm:"size:64;uniqueIndex;not null"` // 秒杀请求ID
+	Amount    int64  `gorm:"not null"`                     // 冻结金额（已扣除优惠），单位分
+	Status    int    `gorm:"index;not null"`
+	OrderID   int64  `gorm:"index;not null;default:0"` // 已创建的订单，0 表示尚未下单
+	CouponID  int64  `gorm:"not null;default:0"`       // 占用的优惠券，0 表示未使用
+	Discount  int64  `gorm:"not null;default:0"`       // 优惠金额，单位分
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
 // Repository 账户仓储接口
 type Repository interface {
 	GetByUserID(ctx context.Context, userID int64) (*Account, error)
@@ -0,0 +1,28 @@
+package admin_user
+
+import (
+	"context"
+	"time"
+)
+
+// AdminUser 后台管理员账号，与前台用户（user.User）分开存储
+type AdminUser struct {
+	ID        int64  `gorm:"primaryKey"`
+	Username  string `gorm:"uniqueIndex;size:64;not null"`
+	Password  string `gorm:"size:255;not null" json:"-"` // 已加密密码
+	Salt      string `gorm:"size:64" json:"-"`
+	Role      string `gorm:"size:32;not null"`       // admin / operator / finance / readonly
+	Disabled  bool   `gorm:"not null;default:false"` // 停用后已签发的令牌立即失效
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Repository 管理员仓储接口
+type Repository interface {
+	GetByID(ctx context.Context, id int64) (*AdminUser, error)
+	GetByUsername(ctx context.Context, username string) (*AdminUser, error)
+	Create(ctx context.Context, u *AdminUser) error
+	Update(ctx context.Context, u *AdminUser) error
+	List(ctx context.Context) ([]*AdminUser, error)
+	Count(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,39 @@
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+// AuditLog 后台写操作的审计记录：谁在什么时候从哪个 IP 对哪个对象做了什么，以及修改前后的内容
+type AuditLog struct {
+	ID         int64     `gorm:"primaryKey" json:"id"`
+	ActorID    int64     `gorm:"index" json:"actor_id"`                                      // 管理员ID，0 表示系统（如活动调度器）
+	ActorName  string    `gorm:"size:64" json:"actor_name"`                                  // 管理员用户名，系统操作为 system
+	Action     string    `gorm:"size:64;index;not null" json:"action"`                       // 操作，如 product.update
+	EntityType string    `gorm:"size:32;index:idx_audit_entity;not null" json:"entity_type"` // 操作对象类型，如 product
+	EntityID   int64     `gorm:"index:idx_audit_entity" json:"entity_id"`                    // 操作对象ID
+	Before     string    `gorm:"type:text" json:"before,omitempty"`                          // 修改前的 JSON，新建时为空
+	After      string    `gorm:"type:text" json:"after,omitempty"`                           // 修改后的 JSON，删除时为空
+	IP         string    `gorm:"size:64" json:"ip"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// Filter 审计记录查询条件，零值表示不限
+type Filter struct {
+	ActorID    int64
+	Action     string
+	EntityType string
+	EntityID   int64
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
+
+// Repository 审计记录仓储接口
+type Repository interface {
+	Create(ctx context.Context, l *AuditLog) error
+	// List 按条件查询，按时间倒序，同时返回符合条件的总数
+	List(ctx context.Context, f Filter) ([]*AuditLog, int64, error)
+}
@@ -0,0 +1,30 @@
+package cart
+
+import (
+	"context"
+	"time"
+)
+
+// CartItem 购物车中的一种商品，每个用户每个商品一条
+type CartItem struct {
+	ID        int64 `gorm:"primaryKey"`
+	UserID    int64 `gorm:"uniqueIndex:idx_cart_user_product;not null"`
+	ProductID int64 `gorm:"uniqueIndex:idx_cart_user_product;not null"`
+	Quantity  int64 `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Repository 购物车仓储接口
+type Repository interface {
+	// List 查询用户购物车，按加入顺序
+	List(ctx context.Context, userID int64) ([]*CartItem, error)
+	// Get 查询购物车中的一种商品，不存在时返回 gorm.ErrRecordNotFound
+	Get(ctx context.Context, userID, productID int64) (*CartItem, error)
+	// Add 增加商品数量，不存在时新建；累加后的数量不超过 max
+	Add(ctx context.Context, userID, productID, qty, max int64) error
+	// Set 设置商品数量，不存在时新建
+	Set(ctx context.Context, userID, productID, qty int64) error
+	// Remove 从购物车移除商品
+	Remove(ctx context.Context, userID int64, productIDs ...int64) error
+}
@@ -0,0 +1,86 @@
+package coupon
+
+import (
+	"context"
+	"time"
+)
+
+// 优惠类型
+const (
+	TypeFixed   = "fixed"   // 满减：减免 Value 分
+	TypePercent = "percent" // 折扣：减免订单金额的 Value%
+)
+
+// 优惠券状态
+const (
+	StatusUnused = 0 // 未使用
+	StatusLocked = 1 // 已被秒杀请求占用，等待订单支付
+	StatusUsed   = 2 // 已使用
+)
+
+// CouponTemplate 优惠券模板，定义优惠方式、使用门槛、适用范围、有效期与发放数量
+type CouponTemplate struct {
+	ID           int64     `gorm:"primaryKey"`
+	Name         string    `gorm:"size:64;not null"`
+	Type         string    `gorm:"size:16;not null"`            // fixed / percent
+	Value        int64     `gorm:"not null"`                    // fixed 为减免金额（分），percent 为减免百分比 1-99
+	MinSpend     int64     `gorm:"not null;default:0"`          // 订单金额门槛（分），0 表示无门槛
+	Category     string    `gorm:"size:32;not null;default:''"` // 限定商品分类，空表示不限
+	ProductID    int64     `gorm:"not null;default:0"`          // 限定商品，0 表示不限
+	Seckill      bool      `gorm:"not null;default:false"`      // 是否可用于秒杀订单
+	Claimable    bool      `gorm:"not null;default:false"`      // 用户是否可以自行领取
+	ValidFrom    time.Time `gorm:"not null"`
+	ValidTo      time.Time `gorm:"not null"`
+	TotalLimit   int64     `gorm:"not null;default:0"` // 发放总量，0 表示不限
+	PerUserLimit int64     `gorm:"not null;default:1"` // 每人最多持有（含已使用）张数
+	Issued       int64     `gorm:"not null;default:0"` // 已发放数量
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Valid 优惠券模板在 now 是否处于有效期内
+func (t *CouponTemplate) Valid(now time.Time) bool {
+	return !now.Before(t.ValidFrom) && now.Before(t.ValidTo)
+}
+
+// Discount 订单金额为 amount 时的减免金额，订单至少需支付 1 分
+func (t *CouponTemplate) Discount(amount int64) int64 {
+	var d int64
+	switch t.Type {
+	case TypeFixed:
+		d = t.Value
+	case TypePercent:
+		d = amount * t.Value / 100
+	}
+	if d > amount-1 {
+		d = amount - 1
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+// Coupon 发放给用户的一张优惠券
+type Coupon struct {
+	ID         int64  `gorm:"primaryKey"`
+	TemplateID int64  `gorm:"index;not null"`
+	UserID     int64  `gorm:"index;not null"`
+	Status     int    `gorm:"index;not null;default:0"` // 0:未使用 1:秒杀占用 2:已使用
+	RequestID  string `gorm:"size:64;index"`            // 占用该券的秒杀请求ID
+	OrderID    int64  `gorm:"index;not null;default:0"` // 使用该券的订单
+	UsedAt     *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Repository 优惠券仓储接口
+type Repository interface {
+	CreateTemplate(ctx context.Context, t *CouponTemplate) error
+	GetTemplate(ctx context.Context, id int64) (*CouponTemplate, error)
+	ListTemplates(ctx context.Context) ([]*CouponTemplate, error)
+	// ListTemplatesByIDs 批量查询模板
+	ListTemplatesByIDs(ctx context.Context, ids []int64) ([]*CouponTemplate, error)
+	// ListByUser 查询用户持有的全部优惠券，新发放的在前
+	ListByUser(ctx context.Context, userID int64) ([]*Coupon, error)
+}
@@ -0,0 +1,44 @@
+package dead_letter
+
+import (
+	"context"
+	"time"
+)
+
+// 死信状态
+const (
+	StatusPending   = 0 // 待处理
+	StatusRedriven  = 1 // 已重新投递
+	StatusDiscarded = 2 // 已丢弃
+)
+
+// DeadLetter 多次重试仍失败、被移出业务队列的消息
+type DeadLetter struct {
+	ID         int64     `gorm:"primaryKey"`
+	Queue      string    `gorm:"size:64;index;not null"` // 来源队列
+	RequestID  *string   `gorm:"size:64;uniqueIndex"`    // 秒杀请求ID，同一请求只记录一条死信；消息无法解析时为空
+	UserID     int64     `gorm:"index"`
+	ProductID  int64     `gorm:"index"`
+	Body       string    `gorm:"type:text;not null"` // 原始消息体
+	Attempts   int       `gorm:"not null"`           // 已尝试次数
+	Reason     string    `gorm:"size:512"`           // 最后一次失败原因
+	Status     int       `gorm:"index;default:0"`    // 0:待处理 1:已重新投递 2:已丢弃
+	RolledBack bool      `gorm:"default:false"`      // 占用的库存与限购计数是否已归还，保证重复投递时只归还一次
+	CreatedAt  time.Time `gorm:"index"`
+	UpdatedAt  time.Time
+}
+
+// Repository 死信仓储接口
+type Repository interface {
+	Create(ctx context.Context, d *DeadLetter) error
+	GetByID(ctx context.Context, id int64) (*DeadLetter, error)
+	GetByRequestID(ctx context.Context, requestID string) (*DeadLetter, error)
+	// List 按状态查询，status 小于 0 时返回全部
+	List(ctx context.Context, status int, limit int) ([]*DeadLetter, error)
+	// UpdateStatus 仅当当前状态为 from 时更新为 to，返回是否更新成功
+	UpdateStatus(ctx context.Context, id int64, from, to int) (bool, error)
+	// Reopen 重新投递后再次失败的消息回到待处理，并更新尝试次数与失败原因；仅当状态为已重新投递时更新
+	Reopen(ctx context.Context, id int64, attempts int, reason string) (bool, error)
+	// UpdateRolledBack 仅当库存归还标记为 from 时更新为 to，返回是否更新成功
+	UpdateRolledBack(ctx context.Context, id int64, from, to bool) (bool, error)
+}
@@ -0,0 +1,56 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 系统账户
+const (
+	AccountRecharge   = "system:recharge"   // 充值资金来源
+	AccountRevenue    = "system:revenue"    // 销售收入
+	AccountRefund     = "system:refund"     // 退款支出
+	AccountGift       = "system:gift"       // 赠送金
+	AccountAdjustment = "system:adjustment" // 对账调整
+)
+
+// 借贷方向
+const (
+	SideDebit  = "debit"
+	SideCredit = "credit"
+)
+
+// UserAvailable 用户可用余额账户，余额 = 贷方合计 - 借方合计，对应 Account.Balance
+func UserAvailable(userID int64) string {
+	return fmt.Sprintf("user:%d:available", userID)
+}
+
+// UserFrozen 用户冻结资金账户，余额 = 贷方合计 - 借方合计，对应 Account.Frozen
+func UserFrozen(userID int64) string {
+	return fmt.Sprintf("user:%d:frozen", userID)
+}
+
+// LedgerEntry 复式记账分录。每次资金移动写入同一 JournalID 下金额相等的一借一贷两条分录，
+// 所有分录的借方合计始终等于贷方合计。
+type LedgerEntry struct {
+	ID            int64     `gorm:"primaryKey"`
+	JournalID     string    `gorm:"size:64;index;not null"`   // 同一笔记账的借贷分录共用
+	TransactionID int64     `gorm:"index;not null;default:0"` // 对应的账户流水，对账调整为 0
+	Account       string    `gorm:"size:64;index;not null"`
+	Side          string    `gorm:"size:8;not null"` // debit / credit
+	Amount        int64     `gorm:"not null"`        // 正数，单位分
+	Type          string    `gorm:"size:32;index"`   // 与账户流水类型一致，对账调整为 adjustment
+	Note          string    `gorm:"size:255"`
+	CreatedAt     time.Time `gorm:"index"`
+}
+
+// Repository 分录查询接口（写入与账户变更在同一事务内由服务层完成）
+type Repository interface {
+	// SumAccount 账户的借方合计与贷方合计
+	SumAccount(ctx context.Context, account string) (debit, credit int64, err error)
+	// Totals 全部分录的借方合计与贷方合计
+	Totals(ctx context.Context) (debit, credit int64, err error)
+	// UnbalancedJournals 借贷不相等的记账编号，最多返回 limit 个
+	UnbalancedJournals(ctx context.Context, limit int) ([]string, error)
+}
@@ -5,23 +5,61 @@ import (
 	"time"
 )
 
+// 订单状态
+const (
+	StatusCreated   = 0 // 已创建，待支付
+	StatusPaid      = 1 // 已支付
+	StatusCancelled = 2 // 已取消
+)
+
 // Order 订单模型
 type Order struct {
-	ID        int64     `gorm:"primaryKey"`
-	UserID    int64     `gorm:"index;not null"`
-	ProductID int64     `gorm:"index;not null"`
-	Price     int64     `gorm:"not null"`
-	Status    int       `gorm:"index;not null"` // 0:已创建 1:已支付 2:已取消
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID          int64      `gorm:"primaryKey"`
+	UserID      int64      `gorm:"index;not null"`
+	ProductID   int64      `gorm:"index;not null"`           // 单商品订单的商品，多商品订单为 0（明细见 OrderItem）
+	ActivityID  int64      `gorm:"index;not null;default:0"` // 秒杀订单所属活动，普通购买为 0
+	Quantity    int64      `gorm:"not null;default:1"`       // 购买总数量，单商品订单取消/退款时按此归还库存
+	Price       int64      `gorm:"not null"`                 // 订单实付金额（已扣除优惠），单位分
+	CouponID    int64      `gorm:"not null;default:0"`       // 使用的优惠券，0 表示未使用
+	Discount    int64      `gorm:"not null;default:0"`       // 优惠券减免金额，单位分
+	Status      int        `gorm:"index;not null"`           // 0:已创建 1:已支付 2:已取消
+	RequestID   *string    `gorm:"size:64;uniqueIndex"`      // 秒杀请求ID，保证同一请求只生成一个订单；普通购买为空
+	PayDeadline *time.Time `gorm:"index"`                    // 待支付订单的支付截止时间，超时自动取消
+	PaidAt      *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Items       []*OrderItem `gorm:"foreignKey:OrderID"` // 订单明细，仓储查询时一并加载；本功能上线前的订单没有明细
+}
+
+// 订单来源
+const (
+	SourceNormal  = "normal"  // 普通购买（含购物车结算）
+	SourceSeckill = "seckill" // 秒杀
+)
+
+// OrderItem 订单明细：下单时的商品快照，商品改名或调价后仍能看到当时买的是什么、按什么价格
+type OrderItem struct {
+	ID          int64  `gorm:"primaryKey"`
+	OrderID     int64  `gorm:"index;not null"`
+	ProductID   int64  `gorm:"index;not null"`
+	ProductName string `gorm:"size:128;not null;default:''"`      // 下单时商品名称
+	ActivityID  int64  `gorm:"index;not null;default:0"`          // 秒杀活动，普通购买为 0
+	Source      string `gorm:"size:16;not null;default:'normal'"` // normal / seckill
+	Quantity    int64  `gorm:"not null"`
+	UnitPrice   int64  `gorm:"not null"`           // 下单时商品原价，单位分
+	Discount    int64  `gorm:"not null;default:0"` // 本明细的优惠合计（秒杀折扣与分摊的优惠券减免），单位分
+	Amount      int64  `gorm:"not null"`           // 实付小计 = 单价 × 数量 - 优惠，各明细之和等于订单金额
+	CreatedAt   time.Time
 }
 
 // Repository 订单仓储接口
 type Repository interface {
 	Create(ctx context.Context, o *Order) error
+	// GetByID、GetByRequestID 与各 List 方法返回的订单都带有订单明细
 	GetByID(ctx context.Context, id int64) (*Order, error)
+	GetByRequestID(ctx context.Context, requestID string) (*Order, error)
 	ListByUser(ctx context.Context, userID int64) ([]*Order, error)
 	ListRecent(ctx context.Context, limit int) ([]*Order, error)
+	// ListExpiredUnpaid 查询支付截止时间早于 before 的待支付订单
+	ListExpiredUnpaid(ctx context.Context, before time.Time, limit int) ([]*Order, error)
 }
-
-
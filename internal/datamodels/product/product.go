@@ -2,9 +2,13 @@ package product
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrSeckillStockNotEnough 条件扣减秒杀库存时库存不足
+var ErrSeckillStockNotEnough = errors.New("秒杀库存不足")
+
 // Product 商品模型
 type Product struct {
 	ID           int64     `gorm:"primaryKey"`
@@ -29,6 +33,8 @@ type Repository interface {
 	ListByCategory(ctx context.Context, category string) ([]*Product, error) // 按分类查询
 	Create(ctx context.Context, p *Product) error
 	Update(ctx context.Context, p *Product) error
+	// DecrSeckillStock 原子扣减秒杀库存（WHERE seckill_stock >= n），库存不足返回 ErrSeckillStockNotEnough
+	DecrSeckillStock(ctx context.Context, id, n int64) error
 	Delete(ctx context.Context, id int64) error
 }
 
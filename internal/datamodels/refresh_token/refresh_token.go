@@ -0,0 +1,30 @@
+package refresh_token
+
+import (
+	"context"
+	"time"
+)
+
+// RefreshToken 服务端保存的刷新令牌。只保存令牌的 SHA-256，明文只在签发时返回给客户端。
+// 同一次登录之后轮换出的令牌属于同一个会话（FamilyID），访问令牌中的 sid 即为 FamilyID。
+type RefreshToken struct {
+	ID        int64      `gorm:"primaryKey"`
+	UserID    int64      `gorm:"index;not null"`
+	FamilyID  string     `gorm:"size:64;index;not null"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	RevokedAt *time.Time // 已轮换或已吊销的时间，为空表示仍可使用
+	CreatedAt time.Time
+}
+
+// Repository 刷新令牌仓储接口
+type Repository interface {
+	Create(ctx context.Context, t *RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*RefreshToken, error)
+	// Revoke 仅当令牌尚未吊销时吊销，返回是否吊销成功；并发刷新时只有一个请求成功
+	Revoke(ctx context.Context, id int64) (bool, error)
+	// RevokeFamily 吊销会话中全部未吊销的令牌
+	RevokeFamily(ctx context.Context, familyID string) error
+	// RevokeByUser 吊销用户全部未吊销的令牌
+	RevokeByUser(ctx context.Context, userID int64) error
+}
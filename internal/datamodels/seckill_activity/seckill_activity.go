@@ -5,6 +5,16 @@ import (
 	"time"
 )
 
+// 活动状态。0-3 沿用原有取值，草稿与暂停为新增状态
+const (
+	StatusScheduled = 0 // 未开始：已发布，到开始时间由调度器自动启动
+	StatusRunning   = 1 // 进行中
+	StatusEnded     = 2 // 已结束（终态）
+	StatusCancelled = 3 // 已取消（终态）
+	StatusDraft     = 4 // 草稿：不会被自动启动
+	StatusPaused    = 5 // 已暂停：拒绝新的秒杀请求，已准入的请求照常完成
+)
+
 // SeckillActivity 秒杀活动模型
 type SeckillActivity struct {
 	ID          int64     `gorm:"primaryKey"`
@@ -14,7 +24,7 @@ type SeckillActivity struct {
 	EndTime     time.Time `gorm:"index"`                   // 结束时间
 	Discount    float64   `gorm:"type:decimal(5,2);not null"` // 折扣（0.1-1.0，如0.8表示8折）
 	LimitPerUser int64   `gorm:"default:1"`                // 每人限购数量，默认1
-	Status      int       `gorm:"index;default:0"`         // 状态：0-未开始 1-进行中 2-已结束 3-已取消
+	Status      int       `gorm:"index;default:0"`         // 状态：0-未开始 1-进行中 2-已结束 3-已取消 4-草稿 5-已暂停
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
@@ -25,9 +35,25 @@ type SeckillActivityProduct struct {
 	ActivityID int64 `gorm:"index;not null"` // 活动ID
 	ProductID  int64 `gorm:"index;not null"` // 商品ID
 	SeckillStock int64 `gorm:"not null"`     // 该商品在此活动中的秒杀库存
+	SeckillPrice int64 `gorm:"default:0"`    // 固定秒杀价（分），0 表示按活动折扣计算
+	LimitPerUser int64 `gorm:"default:0"`    // 该商品每人限购数量，0 表示使用活动的限购
 	CreatedAt  time.Time
 }
 
+// SeckillSettlement 活动结束时每个商品的库存结算记录，同一活动同一商品只结算一次
+type SeckillSettlement struct {
+	ID             int64 `gorm:"primaryKey"`
+	ActivityID     int64 `gorm:"uniqueIndex:idx_settlement_activity_product;not null"` // 活动ID
+	ProductID      int64 `gorm:"uniqueIndex:idx_settlement_activity_product;not null"` // 商品ID
+	Allocated      int64 // 活动划拨的秒杀库存
+	Sold           int64 // 已成交数量（下单事务中扣减的 MySQL 秒杀库存）
+	RedisRemaining int64 // 结算时 Redis 中剩余的秒杀库存，-1 表示 Redis 中无记录
+	Returned       int64 // 归还到普通库存的数量
+	Pending        int64 // 已准入但尚未下单的数量，保留在秒杀库存中供在途消息完成
+	Unstarted      bool  // 活动未启动即被关闭：划拨量直接归还普通库存，不涉及商品的秒杀库存与状态
+	CreatedAt      time.Time
+}
+
 // Repository 秒杀活动仓储接口
 type Repository interface {
 	// 活动CRUD
@@ -35,11 +61,31 @@ type Repository interface {
 	GetByID(ctx context.Context, id int64) (*SeckillActivity, error)
 	ListAll(ctx context.Context) ([]*SeckillActivity, error)
 	Update(ctx context.Context, activity *SeckillActivity) error
-	Delete(ctx context.Context, id int64) error
+	// UpdateStatus 仅当当前状态为 from 时更新为 to，返回是否更新成功
+	UpdateStatus(ctx context.Context, id int64, from, to int) (bool, error)
+	// Delete 在同一事务中删除活动及其商品关联，仅当活动状态仍为 status 时删除，返回是否删除成功；
+	// returnStock 为 true 时把各商品的划拨量归还普通库存（活动从未启动，划拨量尚未结算）
+	Delete(ctx context.Context, id int64, status int, returnStock bool) (bool, error)
 	
 	// 活动商品关联
-	AddProduct(ctx context.Context, activityID, productID, seckillStock int64) error
+	// AddProduct 关联商品，已关联时更新秒杀库存、秒杀价与限购
+	AddProduct(ctx context.Context, ap *SeckillActivityProduct) error
+	GetActivityProduct(ctx context.Context, activityID, productID int64) (*SeckillActivityProduct, error)
 	RemoveProduct(ctx context.Context, activityID, productID int64) error
 	GetProductsByActivity(ctx context.Context, activityID int64) ([]*SeckillActivityProduct, error)
 	GetActivitiesByProduct(ctx context.Context, productID int64) ([]*SeckillActivity, error)
+
+	// 库存结算
+	// Settle 在同一事务中写入结算记录并把 Returned 归还到商品普通库存、
+	// 从秒杀库存中扣除，商品若仍处于秒杀状态则恢复为正常；已结算过时返回 false
+	Settle(ctx context.Context, st *SeckillSettlement) (bool, error)
+	// CloseUnstarted 在同一事务中把从未启动的活动迁移到终态（仅当状态仍为 from）并写入全部商品的结算记录，
+	// 划拨量归还普通库存；返回是否迁移成功
+	CloseUnstarted(ctx context.Context, id int64, from, to int, settlements []*SeckillSettlement) (bool, error)
+	ListSettlements(ctx context.Context, activityID int64) ([]*SeckillSettlement, error)
+	// ListUnsettledProducts 查询在 before 之前进入终态、但仍没有结算记录的活动商品，供补偿结算
+	ListUnsettledProducts(ctx context.Context, before time.Time) ([]*SeckillActivityProduct, error)
+	// ReturnPending 把结算时保留给在途消息的秒杀库存归还普通库存（在途消息最终失败时调用），
+	// 结算记录不存在或 Pending 不足时返回 false
+	ReturnPending(ctx context.Context, activityID, productID, qty int64) (bool, error)
 }
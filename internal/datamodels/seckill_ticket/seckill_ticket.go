@@ -0,0 +1,35 @@
+package seckill_ticket
+
+import (
+	"context"
+	"time"
+)
+
+// 秒杀请求状态
+const (
+	StatusQueued    = 0 // 已进入队列，等待 Worker 处理
+	StatusSucceeded = 1 // 下单成功
+	StatusFailed    = 2 // 下单失败
+)
+
+// SeckillTicket 一次秒杀请求的凭证，ID 与秒杀消息的 RequestID 相同。
+// 准入成功后由 web 端写入（排队中），Worker 处理完成后写入最终结果。
+type SeckillTicket struct {
+	ID         string    `gorm:"primaryKey;size:64"`
+	UserID     int64     `gorm:"index;not null"`
+	ProductID  int64     `gorm:"index;not null"`
+	ActivityID int64     `gorm:"index"`
+	Status     int       `gorm:"index;default:0"` // 0:排队中 1:成功 2:失败
+	OrderID    int64     // 成功时的订单ID
+	Reason     string    `gorm:"size:512"` // 失败原因
+	CreatedAt  time.Time `gorm:"index"`
+	UpdatedAt  time.Time
+}
+
+// Repository 秒杀凭证仓储接口
+type Repository interface {
+	Create(ctx context.Context, t *SeckillTicket) error
+	GetByID(ctx context.Context, id string) (*SeckillTicket, error)
+	// UpdateResult 仅当当前状态为 from 时写入新的状态、订单ID与原因，返回是否更新成功
+	UpdateResult(ctx context.Context, id string, from, to int, orderID int64, reason string) (bool, error)
+}
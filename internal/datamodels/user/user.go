@@ -7,10 +7,10 @@ import (
 
 // User 用户模型
 type User struct {
-	ID        int64     `gorm:"primaryKey"`
-	Username  string    `gorm:"uniqueIndex;size:64;not null"`
-	Password  string    `gorm:"size:255;not null"` // 已加密密码
-	Salt      string    `gorm:"size:64"`
+	ID        int64  `gorm:"primaryKey"`
+	Username  string `gorm:"uniqueIndex;size:64;not null"`
+	Password  string `gorm:"size:255;not null"` // 密码哈希（bcrypt，旧用户为 SHA-256，登录后自动迁移）
+	Salt      string `gorm:"size:64"`           // 仅旧版 SHA-256 哈希使用，bcrypt 哈希自带随机盐
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
@@ -20,7 +20,7 @@ type Repository interface {
 	GetByID(ctx context.Context, id int64) (*User, error)
 	GetByUsername(ctx context.Context, username string) (*User, error)
 	Create(ctx context.Context, u *User) error
+	// UpdatePassword 更新密码哈希与盐
+	UpdatePassword(ctx context.Context, id int64, password, salt string) error
 	ListAll(ctx context.Context) ([]*User, error)
 }
-
-
@@ -0,0 +1,161 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBrokerClosed Broker 已关闭
+var ErrBrokerClosed = errors.New("mq broker closed")
+
+// memoryBroker 进程内队列实现，基于带缓冲的 Go channel。
+// 同一队列的多个消费者竞争消费；Nack(requeue=true) 的消息会重新放回队尾。
+// 仅适合本地开发、单进程部署与测试，进程退出后未消费的消息会丢失。
+type memoryBroker struct {
+	size int
+
+	mu     sync.Mutex
+	queues map[string]chan *memoryMessage
+	closed chan struct{}
+	once   sync.Once
+}
+
+type memoryMessage struct {
+	msg         Message
+	redelivered bool
+}
+
+// NewMemoryBroker 创建进程内 Broker，size 为每个队列的缓冲容量
+func NewMemoryBroker(size int) Broker {
+	if size <= 0 {
+		size = 10000
+	}
+	return &memoryBroker{
+		size:   size,
+		queues: make(map[string]chan *memoryMessage),
+		closed: make(chan struct{}),
+	}
+}
+
+func (b *memoryBroker) queue(name string) chan *memoryMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q, ok := b.queues[name]
+	if !ok {
+		q = make(chan *memoryMessage, b.size)
+		b.queues[name] = q
+	}
+	return q
+}
+
+func (b *memoryBroker) Publish(ctx context.Context, queue string, msg *Message) error {
+	m := &memoryMessage{msg: copyMessage(msg)}
+	if msg.Delay > 0 {
+		select {
+		case <-b.closed:
+			return ErrBrokerClosed
+		default:
+		}
+		time.AfterFunc(msg.Delay, func() {
+			_ = b.push(context.Background(), queue, m)
+		})
+		return nil
+	}
+	return b.push(ctx, queue, m)
+}
+
+func (b *memoryBroker) push(ctx context.Context, queue string, m *memoryMessage) error {
+	select {
+	case <-b.closed:
+		return ErrBrokerClosed
+	default:
+	}
+	select {
+	case b.queue(queue) <- m:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-b.closed:
+		return ErrBrokerClosed
+	}
+}
+
+// Consume 进程内实现中消息在被读取前不会离开队列，prefetch 无需额外处理
+func (b *memoryBroker) Consume(ctx context.Context, queue string, prefetch int) (<-chan *Delivery, error) {
+	select {
+	case <-b.closed:
+		return nil, ErrBrokerClosed
+	default:
+	}
+	q := b.queue(queue)
+	out := make(chan *Delivery)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-b.closed:
+				return
+			case m := <-q:
+				select {
+				case out <- b.newDelivery(queue, m):
+				case <-ctx.Done():
+					// 消费者已停止，消息放回队列
+					_ = b.push(context.Background(), queue, m)
+					return
+				case <-b.closed:
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (b *memoryBroker) newDelivery(queue string, m *memoryMessage) *Delivery {
+	var once sync.Once
+	settled := func(fn func() error) error {
+		err := errors.New("delivery already acknowledged")
+		once.Do(func() { err = fn() })
+		return err
+	}
+	return &Delivery{
+		Body:        m.msg.Body,
+		Headers:     m.msg.Headers,
+		Redelivered: m.redelivered,
+		ack: func() error {
+			return settled(func() error { return nil })
+		},
+		nack: func(requeue bool) error {
+			return settled(func() error {
+				if !requeue {
+					return nil
+				}
+				// 异步放回，避免消费者在队列已满时阻塞在自己的 Nack 上
+				go func() {
+					_ = b.push(context.Background(), queue, &memoryMessage{msg: m.msg, redelivered: true})
+				}()
+				return nil
+			})
+		},
+	}
+}
+
+func (b *memoryBroker) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
+
+func copyMessage(msg *Message) Message {
+	out := Message{Body: append([]byte(nil), msg.Body...)}
+	if msg.Headers != nil {
+		out.Headers = make(map[string]interface{}, len(msg.Headers))
+		for k, v := range msg.Headers {
+			out.Headers[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package mq
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/example/goseckill/internal/config"
+)
+
+const (
+	BackendRabbitMQ = "rabbitmq"
+	BackendMemory   = "memory"
+)
+
+// Message 待发布的消息
+type Message struct {
+	Body    []byte
+	Headers map[string]interface{}
+	// Delay 大于 0 时延迟投递，用于失败重试的退避
+	Delay time.Duration
+}
+
+// Delivery 消费端收到的一条消息，处理完成后必须调用 Ack 或 Nack
+type Delivery struct {
+	Body        []byte
+	Headers     map[string]interface{}
+	Redelivered bool // 是否为重新投递（之前被 Nack 重新入队或未确认）
+
+	ack  func() error
+	nack func(requeue bool) error
+}
+
+// Ack 确认消息已处理
+func (d *Delivery) Ack() error {
+	return d.ack()
+}
+
+// Nack 拒绝消息，requeue 为 true 时重新入队
+func (d *Delivery) Nack(requeue bool) error {
+	return d.nack(requeue)
+}
+
+// Publisher 消息发布接口
+type Publisher interface {
+	Publish(ctx context.Context, queue string, msg *Message) error
+}
+
+// Consumer 消息消费接口
+// prefetch 为未确认消息的最大数量（<=0 表示不限制）。
+// ctx 取消后停止接收新消息，返回的 channel 会被关闭；已收到的消息仍可正常 Ack/Nack。
+type Consumer interface {
+	Consume(ctx context.Context, queue string, prefetch int) (<-chan *Delivery, error)
+}
+
+// Broker 同时具备发布与消费能力的队列实现
+type Broker interface {
+	Publisher
+	Consumer
+	Close() error
+}
+
+var (
+	broker Broker
+	once   sync.Once
+)
+
+// Init 按配置初始化全局队列实现
+func Init(cfg *config.MQConfig, rabbitCfg *config.RabbitMQConfig) Broker {
+	once.Do(func() {
+		switch cfg.Backend {
+		case BackendMemory:
+			broker = NewMemoryBroker(cfg.MemoryQueueSize)
+		case BackendRabbitMQ, "":
+			conn, err := amqp.Dial(rabbitCfg.URL)
+			if err != nil {
+				log.Fatalf("failed to connect rabbitmq: %v", err)
+			}
+			broker = NewRabbitMQBroker(conn, cfg.ChannelPoolSize)
+		default:
+			log.Fatalf("unknown mq backend: %s", cfg.Backend)
+		}
+	})
+	return broker
+}
+
+// Client 获取全局队列实现
+func Client() Broker {
+	return broker
+}
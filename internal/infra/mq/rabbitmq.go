@@ -1,34 +1,217 @@
 package mq
 
 import (
-	"log"
+	"context"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
-
-	"github.com/example/goseckill/internal/config"
 )
 
-var (
-	conn *amqp.Connection
-	once sync.Once
-)
+// rabbitMQBroker 基于 RabbitMQ 的队列实现
+// 发布端复用一个 channel 池，队列只在首次使用时声明一次；
+// 每个消费者独占一个 channel，便于单独取消。
+type rabbitMQBroker struct {
+	conn     *amqp.Connection
+	pool     chan *amqp.Channel
+	declared sync.Map // queue -> struct{}
+
+	mu          sync.Mutex
+	consumers   []*amqp.Channel
+	consumerSeq int64
+}
+
+// NewRabbitMQBroker 基于已建立的连接创建 Broker，poolSize 为发布端 channel 池容量
+func NewRabbitMQBroker(conn *amqp.Connection, poolSize int) Broker {
+	if poolSize <= 0 {
+		poolSize = 16
+	}
+	return &rabbitMQBroker{
+		conn: conn,
+		pool: make(chan *amqp.Channel, poolSize),
+	}
+}
+
+// getChannel 从池中取一个可用 channel，池空时新建
+func (b *rabbitMQBroker) getChannel() (*amqp.Channel, error) {
+	for {
+		select {
+		case ch := <-b.pool:
+			if ch.IsClosed() {
+				continue
+			}
+			return ch, nil
+		default:
+			return b.conn.Channel()
+		}
+	}
+}
+
+// putChannel 归还 channel，池满或 channel 已关闭时直接丢弃
+func (b *rabbitMQBroker) putChannel(ch *amqp.Channel) {
+	if ch.IsClosed() {
+		return
+	}
+	select {
+	case b.pool <- ch:
+	default:
+		_ = ch.Close()
+	}
+}
+
+func (b *rabbitMQBroker) declare(ch *amqp.Channel, queue string) error {
+	if _, ok := b.declared.Load(queue); ok {
+		return nil
+	}
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		return err
+	}
+	b.declared.Store(queue, struct{}{})
+	return nil
+}
+
+// declareDelay 声明延迟队列：消息在其中等待 TTL 到期后经默认交换机死信回目标队列
+func (b *rabbitMQBroker) declareDelay(ch *amqp.Channel, queue string, delay time.Duration) (string, error) {
+	ms := delay.Milliseconds()
+	name := fmt.Sprintf("%s.delay.%d", queue, ms)
+	if _, ok := b.declared.Load(name); ok {
+		return name, nil
+	}
+	if _, err := ch.QueueDeclare(name, true, false, false, false, amqp.Table{
+		"x-message-ttl":             ms,
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": queue,
+	}); err != nil {
+		return "", err
+	}
+	b.declared.Store(name, struct{}{})
+	return name, nil
+}
+
+func (b *rabbitMQBroker) Publish(ctx context.Context, queue string, msg *Message) error {
+	ch, err := b.getChannel()
+	if err != nil {
+		return err
+	}
+	if err := b.declare(ch, queue); err != nil {
+		_ = ch.Close()
+		return err
+	}
+	routingKey := queue
+	if msg.Delay > 0 {
+		if routingKey, err = b.declareDelay(ch, queue, msg.Delay); err != nil {
+			_ = ch.Close()
+			return err
+		}
+	}
+	err = ch.PublishWithContext(
+		ctx,
+		"",
+		routingKey,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Headers:      amqp.Table(msg.Headers),
+			Body:         msg.Body,
+		},
+	)
+	if err != nil {
+		// 发布失败的 channel 状态不可信，不再放回池中
+		_ = ch.Close()
+		return err
+	}
+	b.putChannel(ch)
+	return nil
+}
 
-// Init 初始化 RabbitMQ 连接
-func Init(cfg *config.RabbitMQConfig) *amqp.Connection {
-	once.Do(func() {
-		c, err := amqp.Dial(cfg.URL)
-		if err != nil {
-			log.Fatalf("failed to connect rabbitmq: %v", err)
+func (b *rabbitMQBroker) Consume(ctx context.Context, queue string, prefetch int) (<-chan *Delivery, error) {
+	ch, err := b.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if prefetch > 0 {
+		if err := ch.Qos(prefetch, 0, false); err != nil {
+			_ = ch.Close()
+			return nil, err
 		}
-		conn = c
-	})
-	return conn
+	}
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		_ = ch.Close()
+		return nil, err
+	}
+	// 手动确认模式（auto-ack=false）
+	tag := fmt.Sprintf("goseckill-%s-%d", queue, atomic.AddInt64(&b.consumerSeq, 1))
+	msgs, err := ch.Consume(queue, tag, false, false, false, false, nil)
+	if err != nil {
+		_ = ch.Close()
+		return nil, err
+	}
+
+	b.mu.Lock()
+	b.consumers = append(b.consumers, ch)
+	b.mu.Unlock()
+
+	out := make(chan *Delivery)
+	go func() {
+		defer close(out)
+		// stop 只取消订阅，不关闭 channel，保证在途消息仍能 Ack/Nack；
+		// 取消前已推送到本地的消息重新入队交给其他消费者
+		stop := func() {
+			_ = ch.Cancel(tag, false)
+			for d := range msgs {
+				_ = d.Nack(false, true)
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				stop()
+				return
+			case d, ok := <-msgs:
+				if !ok {
+					return
+				}
+				select {
+				case out <- newRabbitDelivery(d):
+				case <-ctx.Done():
+					_ = d.Nack(false, true)
+					stop()
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
 }
 
-// Conn 获取 MQ 连接
-func Conn() *amqp.Connection {
-	return conn
+func newRabbitDelivery(d amqp.Delivery) *Delivery {
+	return &Delivery{
+		Body:        d.Body,
+		Headers:     d.Headers,
+		Redelivered: d.Redelivered,
+		ack:         func() error { return d.Ack(false) },
+		nack:        func(requeue bool) error { return d.Nack(false, requeue) },
+	}
 }
 
+func (b *rabbitMQBroker) Close() error {
+	b.mu.Lock()
+	for _, ch := range b.consumers {
+		_ = ch.Close()
+	}
+	b.consumers = nil
+	b.mu.Unlock()
 
+	for {
+		select {
+		case ch := <-b.pool:
+			_ = ch.Close()
+		default:
+			return b.conn.Close()
+		}
+	}
+}
@@ -31,4 +31,7 @@ func Client() radix.Client {
 	return client
 }
 
-
+// NewPubSub 创建独立的订阅连接，断线后自动重连并恢复订阅
+func NewPubSub(cfg *config.RedisConfig) radix.PubSubConn {
+	return radix.PersistentPubSub("tcp", cfg.Addr, nil)
+}
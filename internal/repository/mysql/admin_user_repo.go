@@ -0,0 +1,58 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/example/goseckill/internal/datamodels/admin_user"
+)
+
+type adminUserRepo struct {
+	db *gorm.DB
+}
+
+// NewAdminUserRepository 创建管理员仓储
+func NewAdminUserRepository(db *gorm.DB) admin_user.Repository {
+	return &adminUserRepo{db: db}
+}
+
+func (r *adminUserRepo) GetByID(ctx context.Context, id int64) (*admin_user.AdminUser, error) {
+	var u admin_user.AdminUser
+	if err := r.db.WithContext(ctx).First(&u, id).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *adminUserRepo) GetByUsername(ctx context.Context, username string) (*admin_user.AdminUser, error) {
+	var u admin_user.AdminUser
+	if err := r.db.WithContext(ctx).Where("username = ?", username).First(&u).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *adminUserRepo) Create(ctx context.Context, u *admin_user.AdminUser) error {
+	return r.db.WithContext(ctx).Create(u).Error
+}
+
+func (r *adminUserRepo) Update(ctx context.Context, u *admin_user.AdminUser) error {
+	return r.db.WithContext(ctx).Save(u).Error
+}
+
+func (r *adminUserRepo) List(ctx context.Context) ([]*admin_user.AdminUser, error) {
+	var list []*admin_user.AdminUser
+	if err := r.db.WithContext(ctx).Order("id ASC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *adminUserRepo) Count(ctx context.Context) (int64, error) {
+	var n int64
+	if err := r.db.WithContext(ctx).Model(&admin_user.AdminUser{}).Count(&n).Error; err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,56 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/example/goseckill/internal/datamodels/audit"
+)
+
+type auditRepo struct {
+	db *gorm.DB
+}
+
+// NewAuditRepository 创建审计记录仓储
+func NewAuditRepository(db *gorm.DB) audit.Repository {
+	return &auditRepo{db: db}
+}
+
+func (r *auditRepo) Create(ctx context.Context, l *audit.AuditLog) error {
+	return r.db.WithContext(ctx).Create(l).Error
+}
+
+func (r *auditRepo) List(ctx context.Context, f audit.Filter) ([]*audit.AuditLog, int64, error) {
+	q := r.db.WithContext(ctx).Model(&audit.AuditLog{})
+	if f.ActorID > 0 {
+		q = q.Where("actor_id = ?", f.ActorID)
+	}
+	if f.Action != "" {
+		q = q.Where("action = ?", f.Action)
+	}
+	if f.EntityType != "" {
+		q = q.Where("entity_type = ?", f.EntityType)
+	}
+	if f.EntityID > 0 {
+		q = q.Where("entity_id = ?", f.EntityID)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("created_at < ?", f.To)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	var list []*audit.AuditLog
+	if err := q.Order("id DESC").Limit(f.Limit).Offset(f.Offset).Find(&list).Error; err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
@@ -0,0 +1,61 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/goseckill/internal/datamodels/cart"
+)
+
+type cartRepo struct {
+	db *gorm.DB
+}
+
+// NewCartRepository 创建购物车仓储
+func NewCartRepository(db *gorm.DB) cart.Repository {
+	return &cartRepo{db: db}
+}
+
+func (r *cartRepo) List(ctx context.Context, userID int64) ([]*cart.CartItem, error) {
+	var list []*cart.CartItem
+	if err := conn(ctx, r.db).Where("user_id = ?", userID).Order("id ASC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *cartRepo) Get(ctx context.Context, userID, productID int64) (*cart.CartItem, error) {
+	var item cart.CartItem
+	if err := conn(ctx, r.db).Where("user_id = ? AND product_id = ?", userID, productID).First(&item).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *cartRepo) Add(ctx context.Context, userID, productID, qty, max int64) error {
+	// 并发加购时以数据库中的数量为准封顶
+	return r.upsert(ctx, userID, productID, qty, gorm.Expr("LEAST(quantity + ?, ?)", qty, max))
+}
+
+func (r *cartRepo) Set(ctx context.Context, userID, productID, qty int64) error {
+	return r.upsert(ctx, userID, productID, qty, qty)
+}
+
+func (r *cartRepo) upsert(ctx context.Context, userID, productID, qty int64, update interface{}) error {
+	item := &cart.CartItem{UserID: userID, ProductID: productID, Quantity: qty}
+	return conn(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "product_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"quantity": update, "updated_at": gorm.Expr("NOW()")}),
+	}).Create(item).Error
+}
+
+func (r *cartRepo) Remove(ctx context.Context, userID int64, productIDs ...int64) error {
+	if len(productIDs) == 0 {
+		return nil
+	}
+	return conn(ctx, r.db).
+		Where("user_id = ? AND product_id IN ?", userID, productIDs).
+		Delete(&cart.CartItem{}).Error
+}
@@ -0,0 +1,57 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/example/goseckill/internal/datamodels/coupon"
+)
+
+type couponRepo struct {
+	db *gorm.DB
+}
+
+// NewCouponRepository 创建优惠券仓储
+func NewCouponRepository(db *gorm.DB) coupon.Repository {
+	return &couponRepo{db: db}
+}
+
+func (r *couponRepo) CreateTemplate(ctx context.Context, t *coupon.CouponTemplate) error {
+	return conn(ctx, r.db).Create(t).Error
+}
+
+func (r *couponRepo) GetTemplate(ctx context.Context, id int64) (*coupon.CouponTemplate, error) {
+	var t coupon.CouponTemplate
+	if err := conn(ctx, r.db).First(&t, id).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *couponRepo) ListTemplates(ctx context.Context) ([]*coupon.CouponTemplate, error) {
+	var list []*coupon.CouponTemplate
+	if err := conn(ctx, r.db).Order("id DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *couponRepo) ListTemplatesByIDs(ctx context.Context, ids []int64) ([]*coupon.CouponTemplate, error) {
+	var list []*coupon.CouponTemplate
+	if len(ids) == 0 {
+		return list, nil
+	}
+	if err := conn(ctx, r.db).Where("id IN ?", ids).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *couponRepo) ListByUser(ctx context.Context, userID int64) ([]*coupon.Coupon, error) {
+	var list []*coupon.Coupon
+	if err := conn(ctx, r.db).Where("user_id = ?", userID).Order("id DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -9,10 +9,18 @@ import (
 
 	"github.com/example/goseckill/internal/config"
 	"github.com/example/goseckill/internal/datamodels/account"
+	"github.com/example/goseckill/internal/datamodels/admin_user"
+	"github.com/example/goseckill/internal/datamodels/audit"
+	"github.com/example/goseckill/internal/datamodels/cart"
 	"github.com/example/goseckill/internal/datamodels/chat"
+	"github.com/example/goseckill/internal/datamodels/coupon"
+	"github.com/example/goseckill/internal/datamodels/dead_letter"
+	"github.com/example/goseckill/internal/datamodels/ledger"
 	"github.com/example/goseckill/internal/datamodels/order"
 	"github.com/example/goseckill/internal/datamodels/product"
+	"github.com/example/goseckill/internal/datamodels/refresh_token"
 	"github.com/example/goseckill/internal/datamodels/seckill_activity"
+	"github.com/example/goseckill/internal/datamodels/seckill_ticket"
 	"github.com/example/goseckill/internal/datamodels/user"
 )
 
@@ -32,13 +40,25 @@ func Init(cfg *config.MySQLConfig) *gorm.DB {
 
 		if err = db.AutoMigrate(
 			&user.User{},
+			&refresh_token.RefreshToken{},
+			&admin_user.AdminUser{},
 			&product.Product{},
 			&order.Order{},
+			&order.OrderItem{},
+			&cart.CartItem{},
 			&chat.Message{},
 			&account.Account{},
 			&account.Transaction{},
+			&account.Freeze{},
+			&ledger.LedgerEntry{},
+			&coupon.CouponTemplate{},
+			&coupon.Coupon{},
 			&seckill_activity.SeckillActivity{},
 			&seckill_activity.SeckillActivityProduct{},
+			&seckill_activity.SeckillSettlement{},
+			&dead_letter.DeadLetter{},
+			&seckill_ticket.SeckillTicket{},
+			&audit.AuditLog{},
 		); err != nil {
 			log.Fatalf("auto migrate failed: %v", err)
 		}
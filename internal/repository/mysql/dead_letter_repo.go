@@ -0,0 +1,90 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/example/goseckill/internal/datamodels/dead_letter"
+)
+
+type deadLetterRepo struct {
+	db *gorm.DB
+}
+
+// NewDeadLetterRepository 创建死信仓储
+func NewDeadLetterRepository(db *gorm.DB) dead_letter.Repository {
+	return &deadLetterRepo{db: db}
+}
+
+func (r *deadLetterRepo) Create(ctx context.Context, d *dead_letter.DeadLetter) error {
+	return r.db.WithContext(ctx).Create(d).Error
+}
+
+func (r *deadLetterRepo) GetByID(ctx context.Context, id int64) (*dead_letter.DeadLetter, error) {
+	var d dead_letter.DeadLetter
+	if err := r.db.WithContext(ctx).First(&d, id).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *deadLetterRepo) GetByRequestID(ctx context.Context, requestID string) (*dead_letter.DeadLetter, error) {
+	var d dead_letter.DeadLetter
+	if err := r.db.WithContext(ctx).Where("request_id = ?", requestID).First(&d).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *deadLetterRepo) List(ctx context.Context, status int, limit int) ([]*dead_letter.DeadLetter, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	var list []*dead_letter.DeadLetter
+	q := r.db.WithContext(ctx).Order("id DESC").Limit(limit)
+	if status >= 0 {
+		q = q.Where("status = ?", status)
+	}
+	if err := q.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *deadLetterRepo) UpdateStatus(ctx context.Context, id int64, from, to int) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&dead_letter.DeadLetter{}).
+		Where("id = ? AND status = ?", id, from).
+		Update("status", to)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *deadLetterRepo) Reopen(ctx context.Context, id int64, attempts int, reason string) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&dead_letter.DeadLetter{}).
+		Where("id = ? AND status = ?", id, dead_letter.StatusRedriven).
+		Updates(map[string]interface{}{
+			"status":   dead_letter.StatusPending,
+			"attempts": attempts,
+			"reason":   reason,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *deadLetterRepo) UpdateRolledBack(ctx context.Context, id int64, from, to bool) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&dead_letter.DeadLetter{}).
+		Where("id = ? AND rolled_back = ?", id, from).
+		Update("rolled_back", to)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
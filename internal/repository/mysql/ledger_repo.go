@@ -0,0 +1,55 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/example/goseckill/internal/datamodels/ledger"
+)
+
+type ledgerRepo struct {
+	db *gorm.DB
+}
+
+// NewLedgerRepository 创建分录仓储
+func NewLedgerRepository(db *gorm.DB) ledger.Repository {
+	return &ledgerRepo{db: db}
+}
+
+type sideSums struct {
+	Debit  int64
+	Credit int64
+}
+
+func sumSides(q *gorm.DB) (int64, int64, error) {
+	var s sideSums
+	err := q.Model(&ledger.LedgerEntry{}).
+		Select("COALESCE(SUM(CASE WHEN side = ? THEN amount ELSE 0 END), 0) AS debit, "+
+			"COALESCE(SUM(CASE WHEN side = ? THEN amount ELSE 0 END), 0) AS credit",
+			ledger.SideDebit, ledger.SideCredit).
+		Scan(&s).Error
+	return s.Debit, s.Credit, err
+}
+
+func (r *ledgerRepo) SumAccount(ctx context.Context, account string) (int64, int64, error) {
+	return sumSides(conn(ctx, r.db).Where("account = ?", account))
+}
+
+func (r *ledgerRepo) Totals(ctx context.Context) (int64, int64, error) {
+	return sumSides(conn(ctx, r.db))
+}
+
+func (r *ledgerRepo) UnbalancedJournals(ctx context.Context, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	var ids []string
+	err := conn(ctx, r.db).Model(&ledger.LedgerEntry{}).
+		Select("journal_id").
+		Group("journal_id").
+		Having("SUM(CASE WHEN side = ? THEN amount ELSE -amount END) <> 0", ledger.SideDebit).
+		Limit(limit).
+		Pluck("journal_id", &ids).Error
+	return ids, err
+}
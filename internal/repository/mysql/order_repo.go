@@ -2,6 +2,7 @@ package mysql
 
 import (
 	"context"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -23,7 +24,15 @@ func (r *orderRepo) Create(ctx context.Context, o *order.Order) error {
 
 func (r *orderRepo) GetByID(ctx context.Context, id int64) (*order.Order, error) {
 	var o order.Order
-	if err := r.db.WithContext(ctx).First(&o, id).Error; err != nil {
+	if err := r.db.WithContext(ctx).Preload("Items").First(&o, id).Error; err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (r *orderRepo) GetByRequestID(ctx context.Context, requestID string) (*order.Order, error) {
+	var o order.Order
+	if err := r.db.WithContext(ctx).Preload("Items").Where("request_id = ?", requestID).First(&o).Error; err != nil {
 		return nil, err
 	}
 	return &o, nil
@@ -32,6 +41,7 @@ func (r *orderRepo) GetByID(ctx context.Context, id int64) (*order.Order, error)
 func (r *orderRepo) ListByUser(ctx context.Context, userID int64) ([]*order.Order, error) {
 	var list []*order.Order
 	if err := r.db.WithContext(ctx).
+		Preload("Items").
 		Where("user_id = ?", userID).
 		Order("id DESC").
 		Find(&list).Error; err != nil {
@@ -46,6 +56,7 @@ func (r *orderRepo) ListRecent(ctx context.Context, limit int) ([]*order.Order,
 	}
 	var list []*order.Order
 	if err := r.db.WithContext(ctx).
+		Preload("Items").
 		Order("id DESC").
 		Limit(limit).
 		Find(&list).Error; err != nil {
@@ -53,3 +64,18 @@ func (r *orderRepo) ListRecent(ctx context.Context, limit int) ([]*order.Order,
 	}
 	return list, nil
 }
+
+func (r *orderRepo) ListExpiredUnpaid(ctx context.Context, before time.Time, limit int) ([]*order.Order, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	var list []*order.Order
+	if err := r.db.WithContext(ctx).
+		Where("status = ? AND pay_deadline IS NOT NULL AND pay_deadline < ?", order.StatusCreated, before).
+		Order("pay_deadline ASC").
+		Limit(limit).
+		Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
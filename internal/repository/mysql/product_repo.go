@@ -69,6 +69,20 @@ func (r *productRepo) Update(ctx context.Context, p *product.Product) error {
 	return r.db.WithContext(ctx).Save(p).Error
 }
 
+func (r *productRepo) DecrSeckillStock(ctx context.Context, id, n int64) error {
+	res := conn(ctx, r.db).
+		Model(&product.Product{}).
+		Where("id = ? AND seckill_stock >= ?", id, n).
+		Update("seckill_stock", gorm.Expr("seckill_stock - ?", n))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return product.ErrSeckillStockNotEnough
+	}
+	return nil
+}
+
 func (r *productRepo) Delete(ctx context.Context, id int64) error {
 	return r.db.WithContext(ctx).Delete(&product.Product{}, id).Error
 }
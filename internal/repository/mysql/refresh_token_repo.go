@@ -0,0 +1,56 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/goseckill/internal/datamodels/refresh_token"
+)
+
+type refreshTokenRepo struct {
+	db *gorm.DB
+}
+
+// NewRefreshTokenRepository 创建刷新令牌仓储
+func NewRefreshTokenRepository(db *gorm.DB) refresh_token.Repository {
+	return &refreshTokenRepo{db: db}
+}
+
+func (r *refreshTokenRepo) Create(ctx context.Context, t *refresh_token.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(t).Error
+}
+
+func (r *refreshTokenRepo) GetByHash(ctx context.Context, hash string) (*refresh_token.RefreshToken, error) {
+	var t refresh_token.RefreshToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *refreshTokenRepo) Revoke(ctx context.Context, id int64) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&refresh_token.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *refreshTokenRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	return r.db.WithContext(ctx).
+		Model(&refresh_token.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *refreshTokenRepo) RevokeByUser(ctx context.Context, userID int64) error {
+	return r.db.WithContext(ctx).
+		Model(&refresh_token.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
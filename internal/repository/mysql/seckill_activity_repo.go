@@ -2,9 +2,11 @@ package mysql
 
 import (
 	"context"
+	"time"
 
 	"gorm.io/gorm"
 
+	"github.com/example/goseckill/internal/datamodels/product"
 	"github.com/example/goseckill/internal/datamodels/seckill_activity"
 )
 
@@ -41,36 +43,78 @@ func (r *seckillActivityRepo) Update(ctx context.Context, activity *seckill_acti
 	return r.db.WithContext(ctx).Save(activity).Error
 }
 
-func (r *seckillActivityRepo) Delete(ctx context.Context, id int64) error {
-	// 先删除关联的商品
-	if err := r.db.WithContext(ctx).Where("activity_id = ?", id).Delete(&seckill_activity.SeckillActivityProduct{}).Error; err != nil {
-		return err
+func (r *seckillActivityRepo) UpdateStatus(ctx context.Context, id int64, from, to int) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&seckill_activity.SeckillActivity{}).
+		Where("id = ? AND status = ?", id, from).
+		Update("status", to)
+	if res.Error != nil {
+		return false, res.Error
 	}
-	// 再删除活动
-	return r.db.WithContext(ctx).Delete(&seckill_activity.SeckillActivity{}, id).Error
+	return res.RowsAffected > 0, nil
+}
+
+func (r *seckillActivityRepo) Delete(ctx context.Context, id int64, status int, returnStock bool) (bool, error) {
+	deleted := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先按状态删除活动，状态已被并发修改时不做任何事
+		res := tx.Where("id = ? AND status = ?", id, status).Delete(&seckill_activity.SeckillActivity{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		if returnStock {
+			var products []*seckill_activity.SeckillActivityProduct
+			if err := tx.Where("activity_id = ?", id).Find(&products).Error; err != nil {
+				return err
+			}
+			for _, ap := range products {
+				if err := tx.Model(&product.Product{}).
+					Where("id = ?", ap.ProductID).
+					Update("stock", gorm.Expr("stock + ?", ap.SeckillStock)).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Where("activity_id = ?", id).Delete(&seckill_activity.SeckillActivityProduct{}).Error; err != nil {
+			return err
+		}
+		deleted = true
+		return nil
+	})
+	return deleted, err
 }
 
-func (r *seckillActivityRepo) AddProduct(ctx context.Context, activityID, productID, seckillStock int64) error {
+func (r *seckillActivityRepo) AddProduct(ctx context.Context, ap *seckill_activity.SeckillActivityProduct) error {
 	// 检查是否已存在
 	var existing seckill_activity.SeckillActivityProduct
-	err := r.db.WithContext(ctx).Where("activity_id = ? AND product_id = ?", activityID, productID).First(&existing).Error
+	err := r.db.WithContext(ctx).Where("activity_id = ? AND product_id = ?", ap.ActivityID, ap.ProductID).First(&existing).Error
 	if err == nil {
-		// 已存在，更新库存
-		existing.SeckillStock = seckillStock
+		// 已存在，更新库存、秒杀价与限购
+		existing.SeckillStock = ap.SeckillStock
+		existing.SeckillPrice = ap.SeckillPrice
+		existing.LimitPerUser = ap.LimitPerUser
 		return r.db.WithContext(ctx).Save(&existing).Error
 	}
 	if err != gorm.ErrRecordNotFound {
 		return err
 	}
 	// 不存在，创建新记录
-	ap := &seckill_activity.SeckillActivityProduct{
-		ActivityID:   activityID,
-		ProductID:    productID,
-		SeckillStock: seckillStock,
-	}
 	return r.db.WithContext(ctx).Create(ap).Error
 }
 
+func (r *seckillActivityRepo) GetActivityProduct(ctx context.Context, activityID, productID int64) (*seckill_activity.SeckillActivityProduct, error) {
+	var ap seckill_activity.SeckillActivityProduct
+	if err := r.db.WithContext(ctx).
+		Where("activity_id = ? AND product_id = ?", activityID, productID).
+		First(&ap).Error; err != nil {
+		return nil, err
+	}
+	return &ap, nil
+}
+
 func (r *seckillActivityRepo) RemoveProduct(ctx context.Context, activityID, productID int64) error {
 	return r.db.WithContext(ctx).
 		Where("activity_id = ? AND product_id = ?", activityID, productID).
@@ -98,3 +142,122 @@ func (r *seckillActivityRepo) GetActivitiesByProduct(ctx context.Context, produc
 	}
 	return activities, nil
 }
+
+func (r *seckillActivityRepo) Settle(ctx context.Context, st *seckill_activity.SeckillSettlement) (bool, error) {
+	settled := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		settled, err = settle(tx, st)
+		return err
+	})
+	return settled, err
+}
+
+func (r *seckillActivityRepo) CloseUnstarted(ctx context.Context, id int64, from, to int, settlements []*seckill_activity.SeckillSettlement) (bool, error) {
+	closed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&seckill_activity.SeckillActivity{}).
+			Where("id = ? AND status = ?", id, from).
+			Update("status", to)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		for _, st := range settlements {
+			if _, err := settle(tx, st); err != nil {
+				return err
+			}
+		}
+		closed = true
+		return nil
+	})
+	return closed, err
+}
+
+// settle 在事务中写入结算记录并归还库存，已结算过时返回 false
+func settle(tx *gorm.DB, st *seckill_activity.SeckillSettlement) (bool, error) {
+	var count int64
+	if err := tx.Model(&seckill_activity.SeckillSettlement{}).
+		Where("activity_id = ? AND product_id = ?", st.ActivityID, st.ProductID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+	// 唯一索引兜底并发结算
+	if err := tx.Create(st).Error; err != nil {
+		return false, err
+	}
+	updates := map[string]interface{}{
+		"stock": gorm.Expr("stock + ?", st.Returned),
+	}
+	if !st.Unstarted {
+		updates["seckill_stock"] = gorm.Expr("GREATEST(seckill_stock - ?, 0)", st.Returned)
+		updates["status"] = gorm.Expr("CASE WHEN status = 2 THEN 1 ELSE status END")
+	}
+	if err := tx.Model(&product.Product{}).
+		Where("id = ?", st.ProductID).
+		Updates(updates).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *seckillActivityRepo) ListSettlements(ctx context.Context, activityID int64) ([]*seckill_activity.SeckillSettlement, error) {
+	var list []*seckill_activity.SeckillSettlement
+	if err := r.db.WithContext(ctx).
+		Where("activity_id = ?", activityID).
+		Order("id ASC").
+		Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *seckillActivityRepo) ListUnsettledProducts(ctx context.Context, before time.Time) ([]*seckill_activity.SeckillActivityProduct, error) {
+	var list []*seckill_activity.SeckillActivityProduct
+	if err := r.db.WithContext(ctx).
+		Table("seckill_activity_products AS ap").
+		Select("ap.*").
+		Joins("INNER JOIN seckill_activities a ON a.id = ap.activity_id").
+		Joins("LEFT JOIN seckill_settlements st ON st.activity_id = ap.activity_id AND st.product_id = ap.product_id").
+		Where("a.status IN ? AND a.updated_at < ? AND st.id IS NULL",
+			[]int{seckill_activity.StatusEnded, seckill_activity.StatusCancelled}, before).
+		Order("ap.activity_id ASC, ap.id ASC").
+		Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *seckillActivityRepo) ReturnPending(ctx context.Context, activityID, productID, qty int64) (bool, error) {
+	returned := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&seckill_activity.SeckillSettlement{}).
+			Where("activity_id = ? AND product_id = ? AND pending >= ?", activityID, productID, qty).
+			Updates(map[string]interface{}{
+				"pending":  gorm.Expr("pending - ?", qty),
+				"returned": gorm.Expr("returned + ?", qty),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		if err := tx.Model(&product.Product{}).
+			Where("id = ?", productID).
+			Updates(map[string]interface{}{
+				"stock":         gorm.Expr("stock + ?", qty),
+				"seckill_stock": gorm.Expr("GREATEST(seckill_stock - ?, 0)", qty),
+			}).Error; err != nil {
+			return err
+		}
+		returned = true
+		return nil
+	})
+	return returned, err
+}
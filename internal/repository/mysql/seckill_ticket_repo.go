@@ -0,0 +1,45 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/example/goseckill/internal/datamodels/seckill_ticket"
+)
+
+type seckillTicketRepo struct {
+	db *gorm.DB
+}
+
+// NewSeckillTicketRepository 创建秒杀凭证仓储
+func NewSeckillTicketRepository(db *gorm.DB) seckill_ticket.Repository {
+	return &seckillTicketRepo{db: db}
+}
+
+func (r *seckillTicketRepo) Create(ctx context.Context, t *seckill_ticket.SeckillTicket) error {
+	return r.db.WithContext(ctx).Create(t).Error
+}
+
+func (r *seckillTicketRepo) GetByID(ctx context.Context, id string) (*seckill_ticket.SeckillTicket, error) {
+	var t seckill_ticket.SeckillTicket
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *seckillTicketRepo) UpdateResult(ctx context.Context, id string, from, to int, orderID int64, reason string) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Model(&seckill_ticket.SeckillTicket{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(map[string]interface{}{
+			"status":   to,
+			"order_id": orderID,
+			"reason":   reason,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
@@ -0,0 +1,23 @@
+package mysql
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// WithTx 将事务句柄放入 ctx，仓储方法检测到后会在该事务中执行，
+// 便于服务层把多个仓储操作与自身的 SQL 放进同一个事务。
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// conn 优先返回 ctx 中的事务句柄，否则使用仓储自身的连接
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok && tx != nil {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -37,6 +37,13 @@ func (r *userRepo) Create(ctx context.Context, u *user.User) error {
 	return r.db.WithContext(ctx).Create(u).Error
 }
 
+func (r *userRepo) UpdatePassword(ctx context.Context, id int64, password, salt string) error {
+	return r.db.WithContext(ctx).
+		Model(&user.User{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"password": password, "salt": salt}).Error
+}
+
 func (r *userRepo) ListAll(ctx context.Context) ([]*user.User, error) {
 	var list []*user.User
 	if err := r.db.WithContext(ctx).
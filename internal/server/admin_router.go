@@ -1,13 +1,20 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"strconv"
 	"time"
 
 	"github.com/kataras/iris/v12"
 
+	"github.com/example/goseckill/internal/auth"
 	"github.com/example/goseckill/internal/config"
+	"github.com/example/goseckill/internal/datamodels/audit"
+	"github.com/example/goseckill/internal/datamodels/coupon"
+	"github.com/example/goseckill/internal/datamodels/dead_letter"
 	"github.com/example/goseckill/internal/datamodels/product"
 	"github.com/example/goseckill/internal/infra/mq"
 	"github.com/example/goseckill/internal/infra/redis"
@@ -18,10 +25,14 @@ import (
 // RegisterAdminRoutes 注册后台管理端的 HTTP 路由
 // 端口通常是 8081，与前台 Web 服务分离。
 func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
+	if err := cfg.AdminAuth.Validate(); err != nil {
+		log.Fatalf("invalid admin auth config: %v", err)
+	}
+
 	// 初始化基础设施
 	db := mysql.Init(&cfg.MySQL)
 	redisClient := redis.Init(&cfg.Redis)
-	mqConn := mq.Init(&cfg.RabbitMQ)
+	broker := mq.Init(&cfg.MQ, &cfg.RabbitMQ)
 
 	// 仓储与服务
 	productRepo := mysql.NewProductRepository(db)
@@ -33,22 +44,184 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 	productSvc := service.NewProductService(productRepo)
 	orderSvc := service.NewOrderService(orderRepo)
 	chatSvc := service.NewChatService(chatRepo)
-	accountSvc := service.NewAccountService(db, productRepo, orderRepo, userRepo)
-	activitySvc := service.NewSeckillActivityService(activityRepo, productRepo)
-	seckillSvc := service.NewSeckillService(productRepo, activityRepo, redisClient, mqConn, &cfg.JWT)
+	events := service.NewSeckillEventPublisher(redisClient, &cfg.Push)
+	accountSvc := service.NewAccountService(db, productRepo, orderRepo, userRepo, redisClient, events, &cfg.Order)
+	auditSvc := service.NewAuditService(mysql.NewAuditRepository(db))
+	activitySvc := service.NewSeckillActivityService(activityRepo, productRepo, events, auditSvc)
+	ticketSvc := service.NewSeckillTicketService(mysql.NewSeckillTicketRepository(db), events)
+	seckillSvc := service.NewSeckillService(productRepo, activityRepo, redisClient, broker, ticketSvc, events, accountSvc)
+	deadLetterSvc := service.NewDeadLetterService(mysql.NewDeadLetterRepository(db), activityRepo, ticketSvc, events, accountSvc, redisClient, broker)
+	couponSvc := service.NewCouponService(db, mysql.NewCouponRepository(db))
+	adminAuthSvc := service.NewAdminAuthService(mysql.NewAdminUserRepository(db), &cfg.AdminAuth, auth.NewBcryptHasher(cfg.Password.BcryptCost))
+	if err := adminAuthSvc.EnsureBootstrap(context.Background()); err != nil {
+		log.Fatalf("failed to bootstrap admin account: %v", err)
+	}
 
 	// 静态资源
 	app.HandleDir("/assets", iris.Dir("./web/admin/assets"))
 	app.Get("/", func(ctx iris.Context) {
 		_ = ctx.ServeFile("./web/admin/index.html")
 	})
+	app.Get("/login", func(ctx iris.Context) {
+		_ = ctx.ServeFile("./web/admin/login.html")
+	})
 
 	api := app.Party("/api")
 
+	// ---------- 管理员登录与账号管理 ----------
+
+	// 管理员登录，返回后台令牌（与前台用户令牌不通用）
+	api.Post("/admin/login", func(ctx iris.Context) {
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		if err := ctx.ReadJSON(&req); err != nil {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
+			return
+		}
+		token, u, err := adminAuthSvc.Login(ctx.Request().Context(), req.Username, req.Password)
+		if err != nil {
+			if errors.Is(err, service.ErrAdminLoginFailed) {
+				ctx.StopWithJSON(401, iris.Map{"code": 401, "msg": err.Error()})
+				return
+			}
+			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "data": iris.Map{
+			"token":       token,
+			"username":    u.Username,
+			"role":        u.Role,
+			"permissions": auth.Permissions(u.Role),
+		}})
+	})
+
+	// 以下接口都需要管理员令牌，并按路由组要求相应权限
+	secured := api.Party("/", requireAdmin(adminAuthSvc))
+	readAPI := secured.Party("/", requirePermission(auth.PermRead))
+	productAPI := secured.Party("/", requirePermission(auth.PermProductWrite))
+	activityAPI := secured.Party("/", requirePermission(auth.PermActivityWrite))
+	couponAPI := secured.Party("/", requirePermission(auth.PermCouponWrite))
+	deadLetterAPI := secured.Party("/", requirePermission(auth.PermDeadLetterWrite))
+	chatAPI := secured.Party("/", requirePermission(auth.PermChat))
+	rechargeAPI := secured.Party("/", requirePermission(auth.PermAccountRecharge))
+	refundAPI := secured.Party("/", requirePermission(auth.PermOrderRefund))
+	adminAPI := secured.Party("/", requirePermission(auth.PermAdminManage))
+	auditAPI := secured.Party("/", requirePermission(auth.PermAuditRead))
+
+	// 当前登录的管理员及其权限
+	secured.Get("/admin/me", func(ctx iris.Context) {
+		u := currentAdmin(ctx)
+		ctx.JSON(iris.Map{"code": 0, "data": iris.Map{
+			"id":          u.ID,
+			"username":    u.Username,
+			"role":        u.Role,
+			"permissions": auth.Permissions(u.Role),
+		}})
+	})
+
+	// 管理员列表
+	adminAPI.Get("/admin/users", func(ctx iris.Context) {
+		list, err := adminAuthSvc.List(ctx.Request().Context())
+		if err != nil {
+			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "data": list})
+	})
+
+	// 创建管理员，role 为 admin / operator / finance / readonly
+	adminAPI.Post("/admin/users", func(ctx iris.Context) {
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Role     string `json:"role"`
+		}
+		if err := ctx.ReadJSON(&req); err != nil {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
+			return
+		}
+		u, err := adminAuthSvc.Create(ctx.Request().Context(), req.Username, req.Password, req.Role)
+		if err != nil {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
+			return
+		}
+		auditSvc.Record(ctx.Request().Context(), "admin_user.create", service.AuditEntityAdminUser, u.ID, nil, u)
+		ctx.JSON(iris.Map{"code": 0, "data": u})
+	})
+
+	// 修改管理员角色、停用状态或重置密码；不能停用自己或取消自己的超级管理员角色
+	adminAPI.Put("/admin/users/{id:uint64}", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		var req struct {
+			Role     string `json:"role"`
+			Disabled *bool  `json:"disabled"`
+			Password string `json:"password"`
+		}
+		if err := ctx.ReadJSON(&req); err != nil {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
+			return
+		}
+		if self := currentAdmin(ctx); self.ID == int64(id) &&
+			((req.Disabled != nil && *req.Disabled) || (req.Role != "" && req.Role != self.Role)) {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": "不能停用自己或修改自己的角色"})
+			return
+		}
+		before, _ := adminAuthSvc.Get(ctx.Request().Context(), int64(id))
+		u, err := adminAuthSvc.Update(ctx.Request().Context(), int64(id), req.Role, req.Disabled, req.Password)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrAdminNotFound):
+				ctx.StopWithJSON(404, iris.Map{"code": 404, "msg": err.Error()})
+			default:
+				ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
+			}
+			return
+		}
+		// 密码不进入审计内容，只记录是否重置过
+		auditSvc.Record(ctx.Request().Context(), "admin_user.update", service.AuditEntityAdminUser, u.ID, before,
+			iris.Map{"user": u, "password_reset": req.Password != ""})
+		ctx.JSON(iris.Map{"code": 0, "data": u})
+	})
+
+	// ---------- 审计日志 ----------
+
+	// 查询审计日志，按时间倒序分页：actor_id、action、entity_type、entity_id、from、to（2006-01-02 15:04:05）均可选
+	auditAPI.Get("/audit-logs", func(ctx iris.Context) {
+		f := audit.Filter{
+			Action:     ctx.URLParam("action"),
+			EntityType: ctx.URLParam("entity_type"),
+			ActorID:    ctx.URLParamInt64Default("actor_id", 0),
+			EntityID:   ctx.URLParamInt64Default("entity_id", 0),
+			Limit:      ctx.URLParamIntDefault("limit", 50),
+			Offset:     ctx.URLParamIntDefault("offset", 0),
+		}
+		var err error
+		if v := ctx.URLParam("from"); v != "" {
+			if f.From, err = parseAdminTime(v); err != nil {
+				ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": "invalid from: " + err.Error()})
+				return
+			}
+		}
+		if v := ctx.URLParam("to"); v != "" {
+			if f.To, err = parseAdminTime(v); err != nil {
+				ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": "invalid to: " + err.Error()})
+				return
+			}
+		}
+		list, total, err := auditSvc.List(ctx.Request().Context(), f)
+		if err != nil {
+			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "data": iris.Map{"total": total, "list": list}})
+	})
+
 	// ---------- 商品管理 ----------
 
 	// 商品列表（后台用：返回所有商品）
-	api.Get("/products", func(ctx iris.Context) {
+	readAPI.Get("/products", func(ctx iris.Context) {
 		list, err := productSvc.ListAll(ctx.Request().Context())
 		if err != nil {
 			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
@@ -58,7 +231,7 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 	})
 
 	// 创建商品
-	api.Post("/products", func(ctx iris.Context) {
+	productAPI.Post("/products", func(ctx iris.Context) {
 		var req productRequest
 		if err := ctx.ReadJSON(&req); err != nil {
 			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
@@ -73,17 +246,19 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
 			return
 		}
+		auditSvc.Record(ctx.Request().Context(), "product.create", service.AuditEntityProduct, p.ID, nil, p)
 		ctx.JSON(iris.Map{"code": 0, "data": p})
 	})
 
 	// 更新商品
-	api.Put("/products/{id:uint64}", func(ctx iris.Context) {
+	productAPI.Put("/products/{id:uint64}", func(ctx iris.Context) {
 		id, _ := ctx.Params().GetUint64("id")
 		p, err := productSvc.GetByID(ctx.Request().Context(), int64(id))
 		if err != nil {
 			ctx.StopWithJSON(404, iris.Map{"code": 404, "msg": "product not found"})
 			return
 		}
+		before := *p
 		var req productRequest
 		if err := ctx.ReadJSON(&req); err != nil {
 			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
@@ -97,13 +272,14 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
 			return
 		}
+		auditSvc.Record(ctx.Request().Context(), "product.update", service.AuditEntityProduct, p.ID, &before, p)
 		ctx.JSON(iris.Map{"code": 0, "data": p})
 	})
 
 	// ---------- 订单管理 ----------
 
 	// 最近订单列表
-	api.Get("/orders", func(ctx iris.Context) {
+	readAPI.Get("/orders", func(ctx iris.Context) {
 		limitStr := ctx.URLParamDefault("limit", "20")
 		limit, err := strconv.Atoi(limitStr)
 		if err != nil || limit <= 0 {
@@ -117,10 +293,35 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 		ctx.JSON(iris.Map{"code": 0, "data": list})
 	})
 
+	// 订单退款：已支付订单全额退回余额并归还库存（活动未结算时归还秒杀库存，含 Redis）
+	refundAPI.Post("/orders/{id:uint64}/refund", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		_ = ctx.ReadJSON(&req)
+		before, _ := orderRepo.GetByID(ctx.Request().Context(), int64(id))
+		o, err := accountSvc.Refund(ctx.Request().Context(), int64(id), req.Reason)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrOrderNotFound):
+				ctx.StopWithJSON(404, iris.Map{"code": 404, "msg": err.Error()})
+			case errors.Is(err, service.ErrOrderNotRefundable):
+				ctx.StopWithJSON(409, iris.Map{"code": 409, "msg": err.Error()})
+			default:
+				ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			}
+			return
+		}
+		auditSvc.Record(ctx.Request().Context(), "order.refund", service.AuditEntityOrder, o.ID, before,
+			iris.Map{"order": o, "reason": req.Reason})
+		ctx.JSON(iris.Map{"code": 0, "data": iris.Map{"order_id": o.ID, "status": o.Status}})
+	})
+
 	// ---------- 用户余额 / 订单管理 ----------
 
 	// 用户余额列表
-	api.Get("/users", func(ctx iris.Context) {
+	readAPI.Get("/users", func(ctx iris.Context) {
 		list, err := accountSvc.ListAccounts(ctx.Request().Context())
 		if err != nil {
 			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
@@ -130,7 +331,7 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 	})
 
 	// 给指定用户充值（单位：分）
-	api.Post("/users/{id:uint64}/recharge", func(ctx iris.Context) {
+	rechargeAPI.Post("/users/{id:uint64}/recharge", func(ctx iris.Context) {
 		uid, _ := ctx.Params().GetUint64("id")
 		var req struct {
 			Amount int64 `json:"amount"`
@@ -139,11 +340,14 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": "无效充值金额"})
 			return
 		}
+		before, _ := accountSvc.GetSummary(ctx.Request().Context(), int64(uid))
 		acc, err := accountSvc.Recharge(ctx.Request().Context(), int64(uid), req.Amount)
 		if err != nil {
 			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
 			return
 		}
+		auditSvc.Record(ctx.Request().Context(), "account.recharge", service.AuditEntityAccount, int64(uid), before,
+			iris.Map{"account": acc, "amount": req.Amount})
 		ctx.JSON(iris.Map{"code": 0, "data": iris.Map{
 			"user_id": uid,
 			"balance": acc.Balance,
@@ -152,7 +356,7 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 	})
 
 	// 指定用户订单
-	api.Get("/users/{id:uint64}/orders", func(ctx iris.Context) {
+	readAPI.Get("/users/{id:uint64}/orders", func(ctx iris.Context) {
 		uid, _ := ctx.Params().GetUint64("id")
 		list, err := accountSvc.ListOrdersByUser(ctx.Request().Context(), int64(uid))
 		if err != nil {
@@ -162,11 +366,101 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 		ctx.JSON(iris.Map{"code": 0, "data": list})
 	})
 
+	// ---------- 优惠券管理 ----------
+
+	// 优惠券模板列表
+	readAPI.Get("/coupon-templates", func(ctx iris.Context) {
+		list, err := couponSvc.ListTemplates(ctx.Request().Context())
+		if err != nil {
+			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "data": list})
+	})
+
+	// 创建优惠券模板：type 为 fixed（value 为减免金额，分）或 percent（value 为减免百分比）
+	couponAPI.Post("/coupon-templates", func(ctx iris.Context) {
+		var req struct {
+			Name         string `json:"name"`
+			Type         string `json:"type"`
+			Value        int64  `json:"value"`
+			MinSpend     int64  `json:"min_spend"`
+			Category     string `json:"category"`
+			ProductID    int64  `json:"product_id"`
+			Seckill      bool   `json:"seckill"`
+			Claimable    bool   `json:"claimable"`
+			ValidFrom    string `json:"valid_from"`
+			ValidTo      string `json:"valid_to"`
+			TotalLimit   int64  `json:"total_limit"`
+			PerUserLimit int64  `json:"per_user_limit"`
+		}
+		if err := ctx.ReadJSON(&req); err != nil {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
+			return
+		}
+		from, err := parseAdminTime(req.ValidFrom)
+		if err != nil {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": "invalid valid_from: " + err.Error()})
+			return
+		}
+		to, err := parseAdminTime(req.ValidTo)
+		if err != nil {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": "invalid valid_to: " + err.Error()})
+			return
+		}
+		t := &coupon.CouponTemplate{
+			Name:         req.Name,
+			Type:         req.Type,
+			Value:        req.Value,
+			MinSpend:     req.MinSpend,
+			Category:     req.Category,
+			ProductID:    req.ProductID,
+			Seckill:      req.Seckill,
+			Claimable:    req.Claimable,
+			ValidFrom:    from,
+			ValidTo:      to,
+			TotalLimit:   req.TotalLimit,
+			PerUserLimit: req.PerUserLimit,
+		}
+		if err := couponSvc.CreateTemplate(ctx.Request().Context(), t); err != nil {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
+			return
+		}
+		auditSvc.Record(ctx.Request().Context(), "coupon_template.create", service.AuditEntityCoupon, t.ID, nil, t)
+		ctx.JSON(iris.Map{"code": 0, "data": t})
+	})
+
+	// 向用户发放优惠券，逐个用户返回发放结果（超过发放总量或每人上限的用户发放失败）
+	couponAPI.Post("/coupon-templates/{id:uint64}/issue", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		var req struct {
+			UserIDs []int64 `json:"user_ids"`
+		}
+		if err := ctx.ReadJSON(&req); err != nil || len(req.UserIDs) == 0 {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": "user_ids 不能为空"})
+			return
+		}
+		results := make([]iris.Map, 0, len(req.UserIDs))
+		for _, uid := range req.UserIDs {
+			c, err := couponSvc.Issue(ctx.Request().Context(), int64(id), uid)
+			if errors.Is(err, service.ErrCouponTemplateNotFound) {
+				ctx.StopWithJSON(404, iris.Map{"code": 404, "msg": err.Error()})
+				return
+			}
+			if err != nil {
+				results = append(results, iris.Map{"user_id": uid, "error": err.Error()})
+				continue
+			}
+			results = append(results, iris.Map{"user_id": uid, "coupon_id": c.ID})
+		}
+		auditSvc.Record(ctx.Request().Context(), "coupon_template.issue", service.AuditEntityCoupon, int64(id), nil, results)
+		ctx.JSON(iris.Map{"code": 0, "data": results})
+	})
+
 	// ---------- 秒杀活动管理 ----------
 
 	// 获取所有活动列表
-	api.Get("/seckill-activities", func(ctx iris.Context) {
-		_ = activitySvc.CheckAndUpdateExpiredActivities(ctx.Request().Context())
+	readAPI.Get("/seckill-activities", func(ctx iris.Context) {
 		list, err := activitySvc.ListActivities(ctx.Request().Context())
 		if err != nil {
 			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
@@ -176,8 +470,7 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 	})
 
 	// 获取活动详情（包含商品列表）
-	api.Get("/seckill-activities/{id:uint64}", func(ctx iris.Context) {
-		_ = activitySvc.CheckAndUpdateExpiredActivities(ctx.Request().Context())
+	readAPI.Get("/seckill-activities/{id:uint64}", func(ctx iris.Context) {
 		id, _ := ctx.Params().GetUint64("id")
 		data, err := activitySvc.GetActivity(ctx.Request().Context(), int64(id))
 		if err != nil {
@@ -187,8 +480,19 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 		ctx.JSON(iris.Map{"code": 0, "data": data})
 	})
 
+	// 活动结束后的库存结算记录（每个商品一条：划拨、成交、归还普通库存、在途数量）
+	readAPI.Get("/seckill-activities/{id:uint64}/settlements", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		list, err := activitySvc.ListSettlements(ctx.Request().Context(), int64(id))
+		if err != nil {
+			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "data": list})
+	})
+
 	// 创建秒杀活动
-	api.Post("/seckill-activities", func(ctx iris.Context) {
+	activityAPI.Post("/seckill-activities", func(ctx iris.Context) {
 		var req struct {
 			Name          string          `json:"name"`
 			Description   string          `json:"description"`
@@ -198,11 +502,18 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 			LimitPerUser  int64           `json:"limit_per_user"`
 			ProductIDs    []int64         `json:"product_ids"`
 			ProductStocks map[int64]int64 `json:"product_stocks"`
+			ProductPrices map[int64]int64 `json:"product_prices"` // 固定秒杀价（分），可选
+			ProductLimits map[int64]int64 `json:"product_limits"` // 每人限购，可选
+			Draft         bool            `json:"draft"`
 		}
 		if err := ctx.ReadJSON(&req); err != nil {
 			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
 			return
 		}
+		if msg := validateActivityProductTerms(req.ProductPrices, req.ProductLimits); msg != "" {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": msg})
+			return
+		}
 		start, err := parseAdminTime(req.StartTime)
 		if err != nil {
 			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": "invalid start_time: " + err.Error()})
@@ -229,16 +540,19 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 			LimitPerUser:  req.LimitPerUser,
 			ProductIDs:    req.ProductIDs,
 			ProductStocks: req.ProductStocks,
+			ProductPrices: req.ProductPrices,
+			ProductLimits: req.ProductLimits,
+			Draft:         req.Draft,
 		})
 		if err != nil {
-			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			stopWithActivityError(ctx, err)
 			return
 		}
 		ctx.JSON(iris.Map{"code": 0, "data": activity})
 	})
 
 	// 更新秒杀活动（不含商品列表）
-	api.Put("/seckill-activities/{id:uint64}", func(ctx iris.Context) {
+	activityAPI.Put("/seckill-activities/{id:uint64}", func(ctx iris.Context) {
 		id, _ := ctx.Params().GetUint64("id")
 		var req struct {
 			Name         string  `json:"name"`
@@ -274,35 +588,170 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 			Discount:     req.Discount,
 			LimitPerUser: req.LimitPerUser,
 		}); err != nil {
-			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			stopWithActivityError(ctx, err)
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "data": "ok"})
+	})
+
+	// 重新配置活动商品及其秒杀库存
+	activityAPI.Put("/seckill-activities/{id:uint64}/products", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		var req struct {
+			ProductIDs    []int64         `json:"product_ids"`
+			ProductStocks map[int64]int64 `json:"product_stocks"`
+			ProductPrices map[int64]int64 `json:"product_prices"`
+			ProductLimits map[int64]int64 `json:"product_limits"`
+		}
+		if err := ctx.ReadJSON(&req); err != nil {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
+			return
+		}
+		if msg := validateActivityProductTerms(req.ProductPrices, req.ProductLimits); msg != "" {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": msg})
+			return
+		}
+		if err := activitySvc.UpdateActivityProducts(ctx.Request().Context(), int64(id), &service.UpdateActivityProductsRequest{
+			ProductIDs:    req.ProductIDs,
+			ProductStocks: req.ProductStocks,
+			ProductPrices: req.ProductPrices,
+			ProductLimits: req.ProductLimits,
+		}); err != nil {
+			stopWithActivityError(ctx, err)
 			return
 		}
 		ctx.JSON(iris.Map{"code": 0, "data": "ok"})
 	})
 
+	// ----- 活动状态迁移 -----
+
+	// 发布草稿活动
+	activityAPI.Post("/seckill-activities/{id:uint64}/publish", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		if err := activitySvc.PublishActivity(ctx.Request().Context(), int64(id)); err != nil {
+			stopWithActivityError(ctx, err)
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "msg": "activity published"})
+	})
+
+	// 撤回未开始的活动为草稿
+	activityAPI.Post("/seckill-activities/{id:uint64}/unpublish", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		if err := activitySvc.UnpublishActivity(ctx.Request().Context(), int64(id)); err != nil {
+			stopWithActivityError(ctx, err)
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "msg": "activity unpublished"})
+	})
+
 	// 启动活动（更新商品状态并同步库存到 Redis）
-	api.Post("/seckill-activities/{id:uint64}/start", func(ctx iris.Context) {
+	activityAPI.Post("/seckill-activities/{id:uint64}/start", func(ctx iris.Context) {
 		id, _ := ctx.Params().GetUint64("id")
 		if err := activitySvc.StartActivity(ctx.Request().Context(), int64(id), seckillSvc); err != nil {
-			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			stopWithActivityError(ctx, err)
 			return
 		}
 		ctx.JSON(iris.Map{"code": 0, "msg": "activity started"})
 	})
 
+	// 暂停进行中的活动，暂停后立即拒绝新的秒杀请求
+	activityAPI.Post("/seckill-activities/{id:uint64}/pause", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		if err := activitySvc.PauseActivity(ctx.Request().Context(), int64(id)); err != nil {
+			stopWithActivityError(ctx, err)
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "msg": "activity paused"})
+	})
+
+	// 恢复暂停的活动（已过结束时间则直接结束）
+	activityAPI.Post("/seckill-activities/{id:uint64}/resume", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		if err := activitySvc.ResumeActivity(ctx.Request().Context(), int64(id), seckillSvc); err != nil {
+			stopWithActivityError(ctx, err)
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "msg": "activity resumed"})
+	})
+
+	// 提前结束活动并结算库存
+	activityAPI.Post("/seckill-activities/{id:uint64}/end", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		if err := activitySvc.EndActivity(ctx.Request().Context(), int64(id), seckillSvc); err != nil {
+			stopWithActivityError(ctx, err)
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "msg": "activity ended"})
+	})
+
+	// 取消活动：归还未售出库存并清理 Redis 中的库存、限购与秒杀地址
+	activityAPI.Post("/seckill-activities/{id:uint64}/cancel", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		if err := activitySvc.CancelActivity(ctx.Request().Context(), int64(id), seckillSvc); err != nil {
+			stopWithActivityError(ctx, err)
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "msg": "activity cancelled"})
+	})
+
 	// 删除秒杀活动
-	api.Delete("/seckill-activities/{id:uint64}", func(ctx iris.Context) {
+	activityAPI.Delete("/seckill-activities/{id:uint64}", func(ctx iris.Context) {
 		id, _ := ctx.Params().GetUint64("id")
 		if err := activitySvc.DeleteActivity(ctx.Request().Context(), int64(id)); err != nil {
-			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			stopWithActivityError(ctx, err)
 			return
 		}
 		ctx.JSON(iris.Map{"code": 0, "msg": "deleted"})
 	})
 
+	// ---------- 秒杀死信管理 ----------
+
+	// 死信列表（status: 0 待处理 1 已重新投递 2 已丢弃，不传返回全部）
+	readAPI.Get("/seckill/dead-letters", func(ctx iris.Context) {
+		status, err := strconv.Atoi(ctx.URLParamDefault("status", "-1"))
+		if err != nil {
+			status = -1
+		}
+		limit, err := strconv.Atoi(ctx.URLParamDefault("limit", "50"))
+		if err != nil || limit <= 0 {
+			limit = 50
+		}
+		list, err := deadLetterSvc.List(ctx.Request().Context(), status, limit)
+		if err != nil {
+			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "data": list})
+	})
+
+	// 重新投递死信（重新占用库存后写回秒杀队列）
+	deadLetterAPI.Post("/seckill/dead-letters/{id:uint64}/redrive", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		if err := deadLetterSvc.Redrive(ctx.Request().Context(), int64(id)); err != nil {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
+			return
+		}
+		auditSvc.Record(ctx.Request().Context(), "dead_letter.redrive", service.AuditEntityDeadLetter, int64(id),
+			iris.Map{"status": dead_letter.StatusPending}, iris.Map{"status": dead_letter.StatusRedriven})
+		ctx.JSON(iris.Map{"code": 0, "msg": "redriven"})
+	})
+
+	// 丢弃死信
+	deadLetterAPI.Post("/seckill/dead-letters/{id:uint64}/discard", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		if err := deadLetterSvc.Discard(ctx.Request().Context(), int64(id)); err != nil {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
+			return
+		}
+		auditSvc.Record(ctx.Request().Context(), "dead_letter.discard", service.AuditEntityDeadLetter, int64(id),
+			iris.Map{"status": dead_letter.StatusPending}, iris.Map{"status": dead_letter.StatusDiscarded})
+		ctx.JSON(iris.Map{"code": 0, "msg": "discarded"})
+	})
+
 	// ---------- 聊天示例接口 ----------
 
-	api.Get("/chat/contacts", func(ctx iris.Context) {
+	chatAPI.Get("/chat/contacts", func(ctx iris.Context) {
 		// 返回内置的联系人列表
 		type contact struct {
 			ID          string `json:"id"`
@@ -319,7 +768,7 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 		ctx.JSON(iris.Map{"code": 0, "data": contacts})
 	})
 
-	api.Get("/chat/messages/{id:string}", func(ctx iris.Context) {
+	chatAPI.Get("/chat/messages/{id:string}", func(ctx iris.Context) {
 		contactID := ctx.Params().GetString("id")
 		afterIDStr := ctx.URLParamDefault("after_id", "0")
 		limitStr := ctx.URLParamDefault("limit", "50")
@@ -341,7 +790,7 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 		ctx.JSON(iris.Map{"code": 0, "data": list})
 	})
 
-	api.Post("/chat/messages/{id:string}", func(ctx iris.Context) {
+	chatAPI.Post("/chat/messages/{id:string}", func(ctx iris.Context) {
 		contactID := ctx.Params().GetString("id")
 		var req struct {
 			Content string `json:"content"`
@@ -359,6 +808,7 @@ func RegisterAdminRoutes(app *iris.Application, cfg *config.Config) {
 			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
 			return
 		}
+		auditSvc.Record(ctx.Request().Context(), "chat.send", service.AuditEntityChat, int64(m.ID), nil, m)
 		ctx.JSON(iris.Map{"code": 0, "data": m})
 	})
 }
@@ -414,6 +864,30 @@ func (r *productRequest) applyTo(p *product.Product, keepEmptyTime bool) error {
 	return nil
 }
 
+// stopWithActivityError 活动操作失败：非法状态迁移与活动时间重叠返回 409，其余按服务端错误处理
+func stopWithActivityError(ctx iris.Context, err error) {
+	if errors.Is(err, service.ErrActivityTransition) || errors.Is(err, service.ErrActivityOverlap) {
+		ctx.StopWithJSON(409, iris.Map{"code": 409, "msg": err.Error()})
+		return
+	}
+	ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+}
+
+// validateActivityProductTerms 校验按商品设置的秒杀价与限购，均不能为负数（0 表示使用活动的折扣/限购）
+func validateActivityProductTerms(prices, limits map[int64]int64) string {
+	for pid, price := range prices {
+		if price < 0 {
+			return fmt.Sprintf("invalid seckill price for product %d", pid)
+		}
+	}
+	for pid, limit := range limits {
+		if limit < 0 {
+			return fmt.Sprintf("invalid limit_per_user for product %d", pid)
+		}
+	}
+	return ""
+}
+
 // 支持多种常见时间格式，精确到秒
 func parseAdminTime(v string) (time.Time, error) {
 	layouts := []string{
@@ -1,7 +1,10 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"strconv"
 	"strings"
 	"time"
@@ -13,6 +16,7 @@ import (
 	"github.com/example/goseckill/internal/auth"
 	"github.com/example/goseckill/internal/config"
 	"github.com/example/goseckill/internal/datamodels/product"
+	"github.com/example/goseckill/internal/datamodels/seckill_activity"
 	"github.com/example/goseckill/internal/infra/mq"
 	"github.com/example/goseckill/internal/infra/redis"
 	"github.com/example/goseckill/internal/middleware"
@@ -23,10 +27,16 @@ import (
 
 // RegisterRoutes 注册所有 HTTP 路由
 func RegisterRoutes(app *iris.Application, cfg *config.Config) {
+	// 访问令牌密钥没有默认值，未配置或配置错误时拒绝启动
+	jwtKeys, err := auth.LoadKeySet(&cfg.JWT)
+	if err != nil {
+		log.Fatalf("load jwt keys: %v", err)
+	}
+
 	// 初始化基础设施
 	db := mysql.Init(&cfg.MySQL)
 	redisClient := redis.Init(&cfg.Redis)
-	mqConn := mq.Init(&cfg.RabbitMQ)
+	broker := mq.Init(&cfg.MQ, &cfg.RabbitMQ)
 
 	// 静态资源：挂载前端静态文件（CSS/JS/图片）
 	app.HandleDir("/assets", iris.Dir("./web/assets"))
@@ -44,13 +54,59 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 	activityRepo := mysql.NewSeckillActivityRepository(db)
 	_ = orderRepo
 
-	userSvc := service.NewUserService(userRepo, &cfg.JWT)
-	productSvc := service.NewProductService(productRepo)
-	accountSvc := service.NewAccountService(db, productRepo, orderRepo, userRepo)
-	activitySvc := service.NewSeckillActivityService(activityRepo, productRepo)
-	seckillSvc := service.NewSeckillService(productRepo, activityRepo, redisClient, mqConn, &cfg.JWT)
+	// 访问令牌鉴权：一致性哈希分片的解析缓存 + Redis 吊销列表，刷新令牌保存在 MySQL
 	authRing := auth.NewConsistentHashRing(cfg.Auth.Nodes, cfg.Auth.HashReplicas)
-	tokenCache := auth.NewTokenCache(redisClient, authRing, time.Duration(cfg.Auth.TokenCacheTTLSeconds)*time.Second)
+	revocations := auth.NewRevocationList(redisClient, time.Duration(cfg.JWT.AccessTTLSeconds)*time.Second)
+	tokenCache := auth.NewTokenCache(redisClient, authRing, time.Duration(cfg.Auth.TokenCacheTTLSeconds)*time.Second, revocations)
+	tokenSvc := service.NewTokenService(mysql.NewRefreshTokenRepository(db), userRepo, &cfg.JWT, revocations, tokenCache)
+
+	userSvc := service.NewUserService(userRepo, auth.NewBcryptHasher(cfg.Password.BcryptCost), tokenSvc)
+	productSvc := service.NewProductService(productRepo)
+	events := service.NewSeckillEventPublisher(redisClient, &cfg.Push)
+	accountSvc := service.NewAccountService(db, productRepo, orderRepo, userRepo, redisClient, events, &cfg.Order)
+	activitySvc := service.NewSeckillActivityService(activityRepo, productRepo, events, service.NewAuditService(mysql.NewAuditRepository(db)))
+	ticketSvc := service.NewSeckillTicketService(mysql.NewSeckillTicketRepository(db), events)
+	couponSvc := service.NewCouponService(db, mysql.NewCouponRepository(db))
+	cartSvc := service.NewCartService(mysql.NewCartRepository(db), productRepo, accountSvc)
+	seckillSvc := service.NewSeckillService(productRepo, activityRepo, redisClient, broker, ticketSvc, events, accountSvc)
+
+	// 实时推送：订阅 Redis 上的秒杀事件，分发给本实例的 SSE 连接
+	eventHub := service.NewSeckillEventHub(redis.NewPubSub(&cfg.Redis), &cfg.Push)
+	go func() {
+		if err := eventHub.Run(context.Background()); err != nil {
+			log.Printf("seckill event hub stopped: %v", err)
+		}
+	}()
+	heartbeat := time.Duration(cfg.Push.HeartbeatSeconds) * time.Second
+
+	// 活动调度：各实例竞选主节点，由主节点在开始/结束时间点推进活动状态，读接口不再有副作用
+	scheduler := service.NewActivityScheduler(activitySvc, seckillSvc, redisClient, &cfg.Scheduler)
+	go func() {
+		if err := scheduler.Run(context.Background()); err != nil {
+			log.Printf("activity scheduler stopped: %v", err)
+		}
+	}()
+
+	// memory 队列只在本进程内可见，需要在 web 进程中同时启动秒杀消费者
+	if cfg.MQ.Backend == mq.BackendMemory {
+		deadLetterSvc := service.NewDeadLetterService(mysql.NewDeadLetterRepository(db), activityRepo, ticketSvc, events, accountSvc, redisClient, broker)
+		orderSvc := service.NewSeckillOrderService(db, orderRepo, redisClient, events, broker, &cfg.Order)
+		worker := service.NewSeckillWorker(productRepo, activitySvc, accountSvc, orderSvc, deadLetterSvc, ticketSvc, redisClient, broker, &cfg.Worker)
+		go func() {
+			if err := worker.Run(context.Background(), broker); err != nil {
+				log.Printf("embedded seckill worker stopped: %v", err)
+			}
+		}()
+		go func() {
+			if err := orderSvc.Run(context.Background(), broker); err != nil {
+				log.Printf("embedded order timeout consumer stopped: %v", err)
+			}
+		}()
+	}
+	// JWKS：公开非对称签名密钥的公钥，其他服务据此按 kid 校验访问令牌（HS256 密钥不公开）
+	app.Get("/.well-known/jwks.json", func(ctx iris.Context) {
+		ctx.JSON(iris.Map{"keys": jwtKeys.PublicJWKs()})
+	})
 
 	api := app.Party("/api")
 
@@ -89,22 +145,47 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
 			return
 		}
-		token, err := userSvc.Login(ctx.Request().Context(), req.Username, req.Password)
+		pair, err := userSvc.Login(ctx.Request().Context(), req.Username, req.Password)
 		if err != nil {
 			ctx.StopWithJSON(401, iris.Map{"code": 401, "msg": err.Error()})
 			return
 		}
-		ctx.JSON(iris.Map{"code": 0, "data": iris.Map{"token": token}})
+		ctx.JSON(iris.Map{"code": 0, "data": pair})
+	})
+
+	// 用刷新令牌换取新的访问令牌与刷新令牌（旧刷新令牌随即失效）。
+	// 请求体中的 refresh_token 优先；网页登录时刷新令牌在 HttpOnly cookie 中，换取后同时更新 cookie。
+	api.Post("/auth/refresh", func(ctx iris.Context) {
+		var req struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		_ = ctx.ReadJSON(&req)
+		fromCookie := req.RefreshToken == ""
+		if fromCookie {
+			req.RefreshToken = ctx.GetCookie("refresh_token")
+		}
+		pair, err := tokenSvc.Refresh(ctx.Request().Context(), req.RefreshToken)
+		if err != nil {
+			if errors.Is(err, service.ErrRefreshTokenInvalid) {
+				if fromCookie {
+					webcontrollers.ClearTokenCookies(ctx)
+				}
+				ctx.StopWithJSON(401, iris.Map{"code": 401, "msg": err.Error()})
+				return
+			}
+			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			return
+		}
+		if fromCookie {
+			webcontrollers.SetTokenCookies(ctx, pair)
+		}
+		ctx.JSON(iris.Map{"code": 0, "data": pair})
 	})
 
 	// 获取商品实时秒杀库存（从Redis）- 必须在 /products 之前注册，避免路由冲突
 	api.Get("/products/{id:uint64}/seckill-stock", func(ctx iris.Context) {
 		pid, _ := ctx.Params().GetUint64("id")
 
-		// 先自动激活已到开始时间的活动，再检查并更新已过期的活动
-		_ = activitySvc.CheckAndActivateStartedActivities(ctx.Request().Context(), seckillSvc)
-		_ = activitySvc.CheckAndUpdateExpiredActivities(ctx.Request().Context())
-
 		// 检查活动是否还在进行中
 		p, err := productSvc.GetByID(ctx.Request().Context(), int64(pid))
 		if err != nil {
@@ -126,8 +207,14 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 		}
 
 		now := time.Now()
+		// 活动暂停时不可秒杀，库存保留到恢复后继续售卖
+		if activity.Status == seckill_activity.StatusPaused && now.Before(activity.EndTime) {
+			ctx.JSON(iris.Map{"code": 0, "data": iris.Map{"stock": 0, "is_active": false, "is_paused": true}})
+			return
+		}
+
 		// 如果活动已结束，返回0
-		if now.After(activity.EndTime) || now.Equal(activity.EndTime) || activity.Status != 1 {
+		if now.After(activity.EndTime) || now.Equal(activity.EndTime) || activity.Status != seckill_activity.StatusRunning {
 			ctx.JSON(iris.Map{"code": 0, "data": iris.Map{"stock": 0, "is_active": false}})
 			return
 		}
@@ -139,7 +226,7 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 		}
 
 		// 活动进行中，返回实际库存
-		stockKey := fmt.Sprintf("seckill:stock:%d", pid)
+		stockKey := service.SeckillStockKey(int64(pid), activity.ID)
 		var stockStr string
 		var stock int
 		if err := redisClient.Do(radix.Cmd(&stockStr, "GET", stockKey)); err != nil || stockStr == "" {
@@ -157,6 +244,12 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 		ctx.JSON(iris.Map{"code": 0, "data": iris.Map{"stock": stock, "is_active": true}})
 	})
 
+	// 商品实时事件（SSE）：秒杀库存变化与活动状态变化，替代详情页轮询
+	api.Get("/products/{id:uint64}/events", func(ctx iris.Context) {
+		pid, _ := ctx.Params().GetUint64("id")
+		serveEvents(ctx, eventHub, heartbeat, service.ProductEventChannel(int64(pid)))
+	})
+
 	// 商品列表（支持按分类筛选）- 公开接口，无需登录（必须在 /products/{id}/seckill-stock 之后）
 	api.Get("/products", func(ctx iris.Context) {
 		category := ctx.URLParam("category")                  // 获取分类参数：men, women, accessories, 或空（全部）
@@ -197,24 +290,10 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 			list = filtered
 		}
 
-		// 先自动激活已到开始时间的活动，再检查并更新已过期的活动
-		_ = activitySvc.CheckAndActivateStartedActivities(ctx.Request().Context(), seckillSvc)
-		_ = activitySvc.CheckAndUpdateExpiredActivities(ctx.Request().Context())
-
 		// 为每个商品查询活动信息（限购数量等）
 		now := time.Now()
 		productList := make([]map[string]interface{}, 0, len(list))
 		for _, p := range list {
-			// 重新查询商品，因为CheckAndUpdateExpiredActivities可能已经更新了商品状态
-			updatedProduct, err := productSvc.GetByID(ctx.Request().Context(), p.ID)
-			if err != nil {
-				continue // 跳过查询失败的商品
-			}
-			// 如果商品状态被恢复为正常，使用更新后的商品信息
-			if updatedProduct.Status == 1 && p.Status == 2 {
-				p = updatedProduct
-			}
-
 			// 只显示状态为1（正常）或2（秒杀中）的商品
 			if p.Status != 1 && p.Status != 2 {
 				continue // 跳过状态为0（下线）的商品
@@ -239,29 +318,23 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 				if err == nil && activity != nil {
 					// 检查活动是否还在进行中
 					if now.After(activity.StartTime) && now.Before(activity.EndTime) && activity.Status == 1 {
+						price, limit := activitySvc.SeckillTerms(ctx.Request().Context(), activity, p)
 						productData["activity"] = map[string]interface{}{
 							"id":             activity.ID,
 							"name":           activity.Name,
 							"discount":       activity.Discount,
-							"limit_per_user": activity.LimitPerUser,
+							"seckill_price":  price,
+							"limit_per_user": limit,
 							"start_time":     activity.StartTime,
 							"end_time":       activity.EndTime,
 						}
-					} else {
-						// 活动已结束，自动恢复商品状态
-						if now.After(activity.EndTime) {
-							p.Status = 1
-							p.SeckillStock = 0
-							_ = productSvc.Update(ctx.Request().Context(), p)
-							productData["Status"] = 1
-							productData["SeckillStock"] = 0
-						}
+					} else if now.After(activity.EndTime) {
+						// 活动已结束但调度器尚未恢复商品状态，按正常商品展示
+						productData["Status"] = 1
+						productData["SeckillStock"] = 0
 					}
 				} else {
-					// 如果没有找到活动，但商品状态是2，可能是活动已删除，恢复商品状态
-					p.Status = 1
-					p.SeckillStock = 0
-					_ = productSvc.Update(ctx.Request().Context(), p)
+					// 没有找到活动（可能已删除），按正常商品展示
 					productData["Status"] = 1
 					productData["SeckillStock"] = 0
 				}
@@ -280,24 +353,44 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 			ctx.StopWithJSON(401, iris.Map{"code": 401, "msg": "missing token"})
 			return
 		}
-		// 先尝试命中一致性哈希分片的 JWT 缓存，加速鉴权
-		if cached, ok, _ := tokenCache.Get(ctx.Request().Context(), token); ok && cached != nil {
-			ctx.Values().Set("user_id", cached.UserID)
-			ctx.Values().Set("username", cached.Username)
-			ctx.Next()
-			return
-		}
-		claims, err := auth.ParseToken(&cfg.JWT, token)
+		// 先尝试命中一致性哈希分片的 JWT 缓存，未命中时解析签名；两种情况都会检查吊销列表
+		claims, err := tokenSvc.Authenticate(ctx.Request().Context(), token)
 		if err != nil {
-			ctx.StopWithJSON(401, iris.Map{"code": 401, "msg": "invalid token"})
+			if errors.Is(err, service.ErrTokenInvalid) {
+				ctx.StopWithJSON(401, iris.Map{"code": 401, "msg": "invalid token"})
+				return
+			}
+			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
 			return
 		}
-		_ = tokenCache.Set(ctx.Request().Context(), token, claims)
 		ctx.Values().Set("user_id", claims.UserID)
 		ctx.Values().Set("username", claims.Username)
+		ctx.Values().Set("claims", claims)
 		ctx.Next()
 	})
 
+	// 退出当前会话：访问令牌立即失效，会话的刷新令牌全部吊销
+	authAPI.Post("/auth/logout", func(ctx iris.Context) {
+		claims, _ := ctx.Values().Get("claims").(*auth.Claims)
+		if err := tokenSvc.Logout(ctx.Request().Context(), ctx.GetHeader("Authorization"), claims); err != nil {
+			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			return
+		}
+		webcontrollers.ClearTokenCookies(ctx)
+		ctx.JSON(iris.Map{"code": 0, "msg": "logged out"})
+	})
+
+	// 退出所有设备：吊销该用户全部刷新令牌，此前签发的访问令牌全部失效
+	authAPI.Post("/auth/logout-all", func(ctx iris.Context) {
+		userID := ctx.Values().GetInt64Default("user_id", 0)
+		if err := tokenSvc.LogoutAll(ctx.Request().Context(), userID); err != nil {
+			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			return
+		}
+		webcontrollers.ClearTokenCookies(ctx)
+		ctx.JSON(iris.Map{"code": 0, "msg": "logged out from all devices"})
+	})
+
 	// 账户余额示例接口（可替换为真实数据源）
 	authAPI.Get("/user/account", func(ctx iris.Context) {
 		userID := ctx.Values().GetInt64Default("user_id", 0)
@@ -370,6 +463,7 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 		var req struct {
 			ProductID int64 `json:"product_id"`
 			Quantity  int64 `json:"quantity"`
+			CouponID  int64 `json:"coupon_id"` // 可选，使用的优惠券
 		}
 		if err := ctx.ReadJSON(&req); err != nil {
 			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
@@ -379,9 +473,9 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": "参数错误"})
 			return
 		}
-		o, err := accountSvc.Purchase(ctx.Request().Context(), userID, req.ProductID, req.Quantity)
+		o, err := accountSvc.Purchase(ctx.Request().Context(), userID, req.ProductID, req.Quantity, req.CouponID)
 		if err != nil {
-			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
+			stopWithCheckoutError(ctx, err)
 			return
 		}
 		ctx.JSON(iris.Map{
@@ -390,6 +484,7 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 				"order_id":  o.ID,
 				"productID": o.ProductID,
 				"price":     o.Price,
+				"discount":  o.Discount,
 				"status":    o.Status,
 			},
 		})
@@ -407,16 +502,169 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 		ctx.JSON(iris.Map{"code": 0, "data": iris.Map{"path": path}})
 	})
 
-	// 发起秒杀（添加限流）
+	// 发起秒杀（添加限流），可通过查询参数 coupon_id 使用优惠券
 	authAPI.Post("/seckill/{id:uint64}/{path:string}", middleware.SeckillRateLimit(), func(ctx iris.Context) {
 		pid, _ := ctx.Params().GetUint64("id")
 		path := ctx.Params().Get("path")
 		userID := ctx.Values().GetInt64Default("user_id", 0)
-		if err := seckillSvc.Seckill(ctx.Request().Context(), userID, int64(pid), path); err != nil {
+		couponID := ctx.URLParamInt64Default("coupon_id", 0)
+		ticket, err := seckillSvc.Seckill(ctx.Request().Context(), userID, int64(pid), path, couponID)
+		if err != nil {
 			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
 			return
 		}
-		ctx.JSON(iris.Map{"code": 0, "msg": "queued"})
+		ctx.JSON(iris.Map{"code": 0, "msg": "queued", "data": iris.Map{"ticket": ticket}})
+	})
+
+	// 个人秒杀结果实时推送（SSE）：Worker 写入最终结果后立即推送，需携带 Authorization 头
+	authAPI.Get("/seckill/events", func(ctx iris.Context) {
+		userID := ctx.Values().GetInt64Default("user_id", 0)
+		serveEvents(ctx, eventHub, heartbeat, service.UserEventChannel(userID))
+	})
+
+	// 按凭证查询秒杀结果：queued 排队中 / succeeded 成功（带订单ID）/ failed 失败（带原因）
+	authAPI.Get("/seckill/tickets/{ticket:string}", func(ctx iris.Context) {
+		userID := ctx.Values().GetInt64Default("user_id", 0)
+		t, err := ticketSvc.Get(ctx.Request().Context(), userID, ctx.Params().Get("ticket"))
+		if err != nil {
+			if errors.Is(err, service.ErrSeckillTicketNotFound) {
+				ctx.StopWithJSON(404, iris.Map{"code": 404, "msg": err.Error()})
+				return
+			}
+			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			return
+		}
+		data := iris.Map{
+			"ticket":     t.ID,
+			"product_id": t.ProductID,
+			"status":     service.SeckillTicketStatusName(t.Status),
+		}
+		if t.OrderID > 0 {
+			data["order_id"] = t.OrderID
+		}
+		if t.Reason != "" {
+			data["reason"] = t.Reason
+		}
+		ctx.JSON(iris.Map{"code": 0, "data": data})
+	})
+
+	// 购物车
+	authAPI.Get("/cart", func(ctx iris.Context) {
+		userID := ctx.Values().GetInt64Default("user_id", 0)
+		list, err := cartSvc.List(ctx.Request().Context(), userID)
+		if err != nil {
+			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "data": list})
+	})
+
+	// 加入购物车，已存在时累加数量
+	authAPI.Post("/cart/items", func(ctx iris.Context) {
+		userID := ctx.Values().GetInt64Default("user_id", 0)
+		var req service.PurchaseItem
+		if err := ctx.ReadJSON(&req); err != nil || req.ProductID <= 0 {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": "参数错误"})
+			return
+		}
+		if err := cartSvc.Add(ctx.Request().Context(), userID, req.ProductID, req.Quantity); err != nil {
+			stopWithCheckoutError(ctx, err)
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "msg": "ok"})
+	})
+
+	// 修改购物车中商品的数量，quantity 为 0 时移除
+	authAPI.Put("/cart/items/{id:uint64}", func(ctx iris.Context) {
+		pid, _ := ctx.Params().GetUint64("id")
+		userID := ctx.Values().GetInt64Default("user_id", 0)
+		var req struct {
+			Quantity int64 `json:"quantity"`
+		}
+		if err := ctx.ReadJSON(&req); err != nil {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": "参数错误"})
+			return
+		}
+		if err := cartSvc.Update(ctx.Request().Context(), userID, int64(pid), req.Quantity); err != nil {
+			stopWithCheckoutError(ctx, err)
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "msg": "ok"})
+	})
+
+	// 从购物车移除商品
+	authAPI.Delete("/cart/items/{id:uint64}", func(ctx iris.Context) {
+		pid, _ := ctx.Params().GetUint64("id")
+		userID := ctx.Values().GetInt64Default("user_id", 0)
+		if err := cartSvc.Remove(ctx.Request().Context(), userID, int64(pid)); err != nil {
+			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "msg": "ok"})
+	})
+
+	// 结算购物车：product_ids 为空时结算全部商品，可选 coupon_id；失败时返回失败的商品ID
+	authAPI.Post("/cart/checkout", func(ctx iris.Context) {
+		userID := ctx.Values().GetInt64Default("user_id", 0)
+		var req struct {
+			ProductIDs []int64 `json:"product_ids"`
+			CouponID   int64   `json:"coupon_id"`
+		}
+		if err := ctx.ReadJSON(&req); err != nil && ctx.GetContentLength() > 0 {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
+			return
+		}
+		o, err := cartSvc.Checkout(ctx.Request().Context(), userID, req.ProductIDs, req.CouponID)
+		if err != nil {
+			stopWithCheckoutError(ctx, err)
+			return
+		}
+		ctx.JSON(iris.Map{
+			"code": 0,
+			"data": iris.Map{
+				"order_id": o.ID,
+				"price":    o.Price,
+				"discount": o.Discount,
+				"quantity": o.Quantity,
+				"status":   o.Status,
+			},
+		})
+	})
+
+	// 我的优惠券
+	authAPI.Get("/coupons", func(ctx iris.Context) {
+		userID := ctx.Values().GetInt64Default("user_id", 0)
+		list, err := couponSvc.ListByUser(ctx.Request().Context(), userID)
+		if err != nil {
+			ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "data": list})
+	})
+
+	// 领取优惠券（仅限允许自行领取的模板）
+	authAPI.Post("/coupons/claim", func(ctx iris.Context) {
+		userID := ctx.Values().GetInt64Default("user_id", 0)
+		var req struct {
+			TemplateID int64 `json:"template_id"`
+		}
+		if err := ctx.ReadJSON(&req); err != nil || req.TemplateID <= 0 {
+			ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": "参数错误"})
+			return
+		}
+		c, err := couponSvc.Claim(ctx.Request().Context(), userID, req.TemplateID)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrCouponTemplateNotFound):
+				ctx.StopWithJSON(404, iris.Map{"code": 404, "msg": err.Error()})
+			case errors.Is(err, service.ErrCouponSoldOut), errors.Is(err, service.ErrCouponClaimLimit):
+				ctx.StopWithJSON(409, iris.Map{"code": 409, "msg": err.Error()})
+			default:
+				ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
+			}
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "data": c})
 	})
 
 	// 订单查询接口
@@ -430,7 +678,65 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 		ctx.JSON(iris.Map{"code": 0, "data": list})
 	})
 
-	// 秒杀结果查询接口
+	// 查询单个订单（只能查询自己的订单），待支付订单带支付截止时间
+	authAPI.Get("/orders/{id:uint64}", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		userID := ctx.Values().GetInt64Default("user_id", 0)
+		o, err := orderRepo.GetByID(ctx.Request().Context(), int64(id))
+		if err != nil || o.UserID != userID {
+			ctx.StopWithJSON(404, iris.Map{"code": 404, "msg": service.ErrOrderNotFound.Error()})
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "data": o})
+	})
+
+	// 支付待支付的秒杀订单：从余额扣款，超过支付时限的订单不能再支付
+	authAPI.Post("/orders/{id:uint64}/pay", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		userID := ctx.Values().GetInt64Default("user_id", 0)
+		o, err := accountSvc.PayOrder(ctx.Request().Context(), userID, int64(id))
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrOrderNotFound):
+				ctx.StopWithJSON(404, iris.Map{"code": 404, "msg": err.Error()})
+			case errors.Is(err, service.ErrOrderNotPayable), errors.Is(err, service.ErrOrderPaymentExpired):
+				ctx.StopWithJSON(409, iris.Map{"code": 409, "msg": err.Error()})
+			default:
+				ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
+			}
+			return
+		}
+		ctx.JSON(iris.Map{
+			"code": 0,
+			"data": iris.Map{
+				"order_id": o.ID,
+				"price":    o.Price,
+				"status":   o.Status,
+				"paid_at":  o.PaidAt,
+			},
+		})
+	})
+
+	// 取消订单：待支付订单直接取消；已支付订单在退款时限内取消并退款到余额，库存同时归还
+	authAPI.Post("/orders/{id:uint64}/cancel", func(ctx iris.Context) {
+		id, _ := ctx.Params().GetUint64("id")
+		userID := ctx.Values().GetInt64Default("user_id", 0)
+		o, err := accountSvc.CancelOrder(ctx.Request().Context(), userID, int64(id))
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrOrderNotFound):
+				ctx.StopWithJSON(404, iris.Map{"code": 404, "msg": err.Error()})
+			case errors.Is(err, service.ErrOrderNotCancellable):
+				ctx.StopWithJSON(409, iris.Map{"code": 409, "msg": err.Error()})
+			default:
+				ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": err.Error()})
+			}
+			return
+		}
+		ctx.JSON(iris.Map{"code": 0, "data": iris.Map{"order_id": o.ID, "status": o.Status}})
+	})
+
+	// 秒杀结果查询接口（旧接口，按商品猜测结果，无法区分失败与处理中；新客户端请使用 /seckill/tickets/{ticket}）
 	authAPI.Get("/seckill/{id:uint64}/result", func(ctx iris.Context) {
 		productID, _ := ctx.Params().GetUint64("id")
 		userID := ctx.Values().GetInt64Default("user_id", 0)
@@ -580,10 +886,6 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 	api.Get("/products/{id:uint64}/activity", func(ctx iris.Context) {
 		pid, _ := ctx.Params().GetUint64("id")
 
-		// 自动激活已到开始时间的活动，并更新已过期的活动
-		_ = activitySvc.CheckAndActivateStartedActivities(ctx.Request().Context(), seckillSvc)
-		_ = activitySvc.CheckAndUpdateExpiredActivities(ctx.Request().Context())
-
 		// 直接根据商品ID查询关联活动（不再强依赖商品当前 Status）
 		activity, err := activitySvc.GetActivityByProduct(ctx.Request().Context(), int64(pid))
 		if err != nil || activity == nil {
@@ -592,30 +894,34 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 		}
 
 		now := time.Now()
-		isActive := now.After(activity.StartTime) && now.Before(activity.EndTime) && activity.Status == 1
-
-		ctx.JSON(iris.Map{
-			"code": 0,
-			"data": map[string]interface{}{
-				"id":             activity.ID,
-				"name":           activity.Name,
-				"discount":       activity.Discount,
-				"limit_per_user": activity.LimitPerUser,
-				"start_time":     activity.StartTime,
-				"end_time":       activity.EndTime,
-				"is_active":      isActive,
-			},
-		})
+		inWindow := now.After(activity.StartTime) && now.Before(activity.EndTime)
+		isActive := inWindow && activity.Status == seckill_activity.StatusRunning
+		isPaused := inWindow && activity.Status == seckill_activity.StatusPaused
+
+		data := map[string]interface{}{
+			"id":             activity.ID,
+			"name":           activity.Name,
+			"discount":       activity.Discount,
+			"limit_per_user": activity.LimitPerUser,
+			"start_time":     activity.StartTime,
+			"end_time":       activity.EndTime,
+			"is_active":      isActive,
+			"is_paused":      isPaused,
+			"status":         service.ActivityStatusName[activity.Status],
+		}
+		// 秒杀价与限购按商品在活动中的设置计算，前端直接展示，不再自行按折扣换算
+		if p, err := productSvc.GetByID(ctx.Request().Context(), int64(pid)); err == nil && p != nil {
+			price, limit := activitySvc.SeckillTerms(ctx.Request().Context(), activity, p)
+			data["seckill_price"] = price
+			data["limit_per_user"] = limit
+		}
+		ctx.JSON(iris.Map{"code": 0, "data": data})
 	})
 
 	// 商品详情页：/product/{id}
 	app.Get("/product/{id:uint64}", func(ctx iris.Context) {
 		pid, _ := ctx.Params().GetUint64("id")
 
-		// 自动激活已到开始时间的活动，并检查已过期的活动
-		_ = activitySvc.CheckAndActivateStartedActivities(ctx.Request().Context(), seckillSvc)
-		_ = activitySvc.CheckAndUpdateExpiredActivities(ctx.Request().Context())
-
 		p, err := productSvc.GetByID(ctx.Request().Context(), int64(pid))
 		if err != nil {
 			// 记录错误日志
@@ -638,10 +944,6 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 			return
 		}
 
-		// 再次自动激活/更新活动状态，确保活动信息与商品状态一致
-		_ = activitySvc.CheckAndActivateStartedActivities(ctx.Request().Context(), seckillSvc)
-		_ = activitySvc.CheckAndUpdateExpiredActivities(ctx.Request().Context())
-
 		// 查询商品的活动信息（用于模板初始渲染）
 		var activityInfo map[string]interface{}
 		if p.Status == 2 {
@@ -649,19 +951,20 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 			if err == nil && activity != nil {
 				now := time.Now()
 				if now.After(activity.StartTime) && now.Before(activity.EndTime) && activity.Status == 1 {
+					price, limit := activitySvc.SeckillTerms(ctx.Request().Context(), activity, p)
 					activityInfo = map[string]interface{}{
 						"id":             activity.ID,
 						"name":           activity.Name,
 						"discount":       activity.Discount,
-						"limit_per_user": activity.LimitPerUser,
+						"seckill_price":  price,
+						"limit_per_user": limit,
 						"start_time":     activity.StartTime,
 						"end_time":       activity.EndTime,
 					}
 				} else if now.After(activity.EndTime) {
-					// 活动已结束，自动恢复商品状态
+					// 活动已结束但调度器尚未恢复商品状态，按正常商品展示（只改本次渲染的副本）
 					p.Status = 1
 					p.SeckillStock = 0
-					_ = productSvc.Update(ctx.Request().Context(), p)
 				}
 			}
 		}
@@ -726,7 +1029,7 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 	})
 
 	// 用户登录 / 注册表单路由
-	userController := webcontrollers.NewUserController(userSvc)
+	userController := webcontrollers.NewUserController(userSvc, tokenSvc)
 	app.Get("/login", userController.ShowLogin)
 	app.Get("/register", userController.ShowRegister)
 	app.Get("/user/login", userController.ShowLogin)
@@ -736,3 +1039,23 @@ func RegisterRoutes(app *iris.Application, cfg *config.Config) {
 	app.Post("/user/login", userController.PostLogin)
 	app.Post("/user/add", userController.PostAdd)
 }
+
+// stopWithCheckoutError 购买/结算失败：某个商品不可购买时返回 409 并带上商品ID，其他业务错误返回 400，
+// 系统错误（数据库、Redis 等）只记录日志，返回 500 与通用提示
+func stopWithCheckoutError(ctx iris.Context, err error) {
+	var itemErr *service.CheckoutItemError
+	if errors.As(err, &itemErr) {
+		ctx.StopWithJSON(409, iris.Map{
+			"code": 409,
+			"msg":  err.Error(),
+			"data": iris.Map{"product_id": itemErr.ProductID, "reason": itemErr.Err.Error()},
+		})
+		return
+	}
+	if service.IsCheckoutError(err) {
+		ctx.StopWithJSON(400, iris.Map{"code": 400, "msg": err.Error()})
+		return
+	}
+	log.Printf("checkout failed: path=%s err=%v", ctx.Path(), err)
+	ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": "下单失败，请稍后重试"})
+}
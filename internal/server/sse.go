@@ -0,0 +1,54 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kataras/iris/v12"
+
+	"github.com/example/goseckill/internal/service"
+)
+
+// serveEvents 以 Server-Sent Events 推送订阅频道上的事件，直到客户端断开。
+// 每条事件的 data 为 service.SeckillEvent 的 JSON，客户端按其中的 type 分发。
+func serveEvents(ctx iris.Context, hub *service.SeckillEventHub, heartbeat time.Duration, channels ...string) {
+	flusher, ok := ctx.ResponseWriter().Flusher()
+	if !ok {
+		ctx.StopWithJSON(500, iris.Map{"code": 500, "msg": "streaming unsupported"})
+		return
+	}
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+
+	ctx.ContentType("text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no") // 关闭 nginx 缓冲
+
+	events, cancel := hub.Subscribe(channels...)
+	defer cancel()
+
+	w := ctx.ResponseWriter()
+	_, _ = fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	done := ctx.Request().Context().Done()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case payload := <-events:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", payload); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
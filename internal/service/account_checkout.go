@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/goseckill/internal/datamodels/account"
+	"github.com/example/goseckill/internal/datamodels/coupon"
+	"github.com/example/goseckill/internal/datamodels/ledger"
+	"github.com/example/goseckill/internal/datamodels/order"
+	"github.com/example/goseckill/internal/datamodels/product"
+)
+
+var (
+	// ErrProductNotFound 商品不存在
+	ErrProductNotFound = errors.New("商品不存在")
+	// ErrProductUnavailable 商品不是在售状态
+	ErrProductUnavailable = errors.New("商品不可购买")
+	// ErrOutOfStock 库存不足
+	ErrOutOfStock = errors.New("库存不足")
+	// ErrNoPurchaseItems 结算时没有任何商品
+	ErrNoPurchaseItems = errors.New("没有要购买的商品")
+	// ErrInvalidProductID 商品ID无效
+	ErrInvalidProductID = errors.New("商品ID无效")
+	// ErrInvalidQuantity 购买数量无效
+	ErrInvalidQuantity = errors.New("数量必须大于 0")
+)
+
+// IsCheckoutError 是否为购买/结算的业务错误（商品、库存、余额、优惠券、购物车校验失败），
+// 其余错误（数据库、Redis 等）属于系统错误，不应把原始信息返回给用户
+func IsCheckoutError(err error) bool {
+	var itemErr *CheckoutItemError
+	return errors.As(err, &itemErr) ||
+		errors.Is(err, ErrNoPurchaseItems) ||
+		errors.Is(err, ErrInvalidProductID) ||
+		errors.Is(err, ErrInsufficientBalance) ||
+		errors.Is(err, ErrCartEmpty) ||
+		errors.Is(err, ErrCartQuantity) ||
+		errors.Is(err, ErrProductNotFound) ||
+		errors.Is(err, ErrProductUnavailable) ||
+		isCouponError(err)
+}
+
+// PurchaseItem 结算中的一种商品
+type PurchaseItem struct {
+	ProductID int64 `json:"product_id"`
+	Quantity  int64 `json:"quantity"`
+}
+
+// CheckoutItemError 结算失败的商品及原因，可用 errors.Is 判断具体原因
+type CheckoutItemError struct {
+	ProductID int64
+	Err       error
+}
+
+func (e *CheckoutItemError) Error() string {
+	return fmt.Sprintf("商品 %d：%v", e.ProductID, e.Err)
+}
+
+func (e *CheckoutItemError) Unwrap() error {
+	return e.Err
+}
+
+// Purchase 购买商品（扣余额、减库存、生成订单、写流水）。couponID 大于 0 时使用该优惠券抵扣并核销。
+func (s *AccountService) Purchase(ctx context.Context, userID, productID, qty, couponID int64) (*order.Order, error) {
+	return s.Checkout(ctx, userID, []PurchaseItem{{ProductID: productID, Quantity: qty}}, couponID)
+}
+
+// Checkout 一次购买多种商品：在同一事务中锁定账户与全部商品，校验在售状态、库存与余额，
+// 扣减余额与库存，创建订单及订单明细并写流水。任一商品校验失败时整单失败，返回 *CheckoutItemError 指明商品。
+// 同一商品出现多次时合并数量；只有一种商品时订单记录 ProductID，与单商品购买一致。
+func (s *AccountService) Checkout(ctx context.Context, userID int64, items []PurchaseItem, couponID int64) (*order.Order, error) {
+	items, err := mergePurchaseItems(items)
+	if err != nil {
+		return nil, err
+	}
+
+	var resultOrder *order.Order
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 1) 锁定/创建账户
+		acc, err := lockAccount(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		// 2) 按商品ID顺序锁定全部商品，避免并发结算之间死锁
+		ids := make([]int64, 0, len(items))
+		for _, it := range items {
+			ids = append(ids, it.ProductID)
+		}
+		var list []*product.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", ids).
+			Order("id ASC").
+			Find(&list).Error; err != nil {
+			return err
+		}
+		products := make(map[int64]*product.Product, len(list))
+		for _, p := range list {
+			products[p.ID] = p
+		}
+
+		// 3) 逐个商品校验并计算小计
+		lines := make([]couponLine, 0, len(items))
+		var total, qty int64
+		for _, it := range items {
+			p, ok := products[it.ProductID]
+			switch {
+			case !ok:
+				return &CheckoutItemError{ProductID: it.ProductID, Err: ErrProductNotFound}
+			case p.Status != 1:
+				return &CheckoutItemError{ProductID: it.ProductID, Err: ErrProductUnavailable}
+			case p.Stock < it.Quantity:
+				return &CheckoutItemError{ProductID: it.ProductID, Err: fmt.Errorf("%w，剩余 %d 件", ErrOutOfStock, p.Stock)}
+			}
+			amount := p.Price * it.Quantity
+			lines = append(lines, couponLine{product: p, amount: amount})
+			total += amount
+			qty += it.Quantity
+		}
+
+		// 4) 优惠券减免并校验余额
+		var c *coupon.Coupon
+		var discount int64
+		if couponID > 0 {
+			if c, discount, err = applyCoupon(tx, userID, couponID, lines, false); err != nil {
+				return err
+			}
+			total -= discount
+		}
+		if acc.Balance < total {
+			return insufficientBalance(total, acc.Balance)
+		}
+
+		// 5) 扣减余额与库存
+		acc.Balance -= total
+		if err := tx.Save(acc).Error; err != nil {
+			return err
+		}
+		for _, it := range items {
+			if err := tx.Model(&product.Product{}).
+				Where("id = ?", it.ProductID).
+				Update("stock", gorm.Expr("stock - ?", it.Quantity)).Error; err != nil {
+				return err
+			}
+		}
+
+		// 6) 创建订单与订单明细（商品名称、单价与优惠的快照）
+		now := time.Now()
+		o := order.Order{
+			UserID:   userID,
+			Quantity: qty,
+			Price:    total,
+			CouponID: couponID,
+			Discount: discount,
+			Status:   order.StatusPaid,
+			PaidAt:   &now,
+		}
+		if len(items) == 1 {
+			o.ProductID = items[0].ProductID
+		}
+		for i, it := range items {
+			o.Items = append(o.Items, &order.OrderItem{
+				ProductID:   it.ProductID,
+				ProductName: lines[i].product.Name,
+				Source:      order.SourceNormal,
+				Quantity:    it.Quantity,
+				UnitPrice:   lines[i].product.Price,
+				Discount:    lines[i].discount,
+				Amount:      lines[i].amount - lines[i].discount,
+			})
+		}
+		if err := tx.Create(&o).Error; err != nil {
+			return err
+		}
+		resultOrder = &o
+
+		// 7) 核销优惠券并写交易流水
+		note := fmt.Sprintf("订单 #%d", o.ID)
+		if c != nil {
+			if err := useCoupon(tx, c, o.ID); err != nil {
+				return err
+			}
+			note += couponNote(couponID, discount)
+		}
+		return bookTransaction(tx, &account.Transaction{
+			UserID:   userID,
+			Amount:   -total,
+			Type:     "purchase",
+			Status:   "success",
+			Note:     note,
+			CouponID: couponID,
+		}, ledger.UserAvailable(userID), ledger.AccountRevenue, total)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resultOrder, nil
+}
+
+// mergePurchaseItems 校验数量并合并重复商品，按商品ID排序
+func mergePurchaseItems(items []PurchaseItem) ([]PurchaseItem, error) {
+	if len(items) == 0 {
+		return nil, ErrNoPurchaseItems
+	}
+	qty := make(map[int64]int64, len(items))
+	for _, it := range items {
+		if it.ProductID <= 0 {
+			return nil, ErrInvalidProductID
+		}
+		if it.Quantity <= 0 {
+			return nil, &CheckoutItemError{ProductID: it.ProductID, Err: ErrInvalidQuantity}
+		}
+		qty[it.ProductID] += it.Quantity
+	}
+	merged := make([]PurchaseItem, 0, len(qty))
+	for id, n := range qty {
+		merged = append(merged, PurchaseItem{ProductID: id, Quantity: n})
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].ProductID < merged[j].ProductID })
+	return merged, nil
+}
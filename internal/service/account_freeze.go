@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/goseckill/internal/datamodels/account"
+	"github.com/example/goseckill/internal/datamodels/coupon"
+	"github.com/example/goseckill/internal/datamodels/ledger"
+	"github.com/example/goseckill/internal/datamodels/order"
+	"github.com/example/goseckill/internal/datamodels/product"
+)
+
+// ErrInsufficientBalance 可用余额不足
+var ErrInsufficientBalance = errors.New("余额不足")
+
+// insufficientBalance 带金额说明的余额不足错误，可用 errors.Is(err, ErrInsufficientBalance) 判断
+func insufficientBalance(need, have int64) error {
+	return fmt.Errorf("%w，需 ¥%.2f，当前 ¥%.2f", ErrInsufficientBalance, float64(need)/100, float64(have)/100)
+}
+
+// FreezeForSeckill 秒杀准入时把秒杀价从可用余额转入冻结金额，返回实际冻结的金额，余额不足返回 ErrInsufficientBalance。
+// couponID 大于 0 时在同一事务中校验并占用该优惠券（p 用于校验适用范围），冻结减免后的金额。
+// 按 requestID 幂等：已冻结或已结算时直接返回；已解冻（例如进入死信后重新投递）时按原金额重新冻结并重新占用原优惠券，
+// 此时忽略 amount、p 与 couponID。
+func (s *AccountService) FreezeForSeckill(ctx context.Context, userID, amount int64, requestID string, p *product.Product, couponID int64) (int64, error) {
+	if amount <= 0 || requestID == "" {
+		return 0, errors.New("冻结金额与请求ID不能为空")
+	}
+	var frozen int64
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		acc, err := lockAccount(tx, userID)
+		if err != nil {
+			return err
+		}
+		f, err := lockFreeze(tx, requestID)
+		if err != nil {
+			return err
+		}
+		if f != nil && f.Status != account.FreezeStatusReleased {
+			frozen = f.Amount
+			return nil
+		}
+
+		// 首次冻结时核算优惠券；重新冻结时沿用原金额并重新占用原优惠券
+		var c *coupon.Coupon
+		if f == nil {
+			f = &account.Freeze{UserID: userID, RequestID: requestID, Amount: amount}
+			if couponID > 0 {
+				var discount int64
+				if c, discount, err = applyCoupon(tx, userID, couponID, []couponLine{{product: p, amount: amount}}, true); err != nil {
+					return err
+				}
+				f.Amount -= discount
+				f.CouponID = couponID
+				f.Discount = discount
+			}
+		} else if f.CouponID > 0 {
+			var t *coupon.CouponTemplate
+			if c, t, err = lockCoupon(tx, userID, f.CouponID); err != nil {
+				return err
+			}
+			if c.Status != coupon.StatusUnused || !t.Valid(time.Now()) {
+				return fmt.Errorf("%w：原优惠券已使用或已过期", ErrCouponUnavailable)
+			}
+		}
+		if acc.Balance < f.Amount {
+			return insufficientBalance(f.Amount, acc.Balance)
+		}
+
+		acc.Balance -= f.Amount
+		acc.Frozen += f.Amount
+		if err := tx.Save(acc).Error; err != nil {
+			return err
+		}
+		f.Status = account.FreezeStatusFrozen
+		if err := tx.Save(f).Error; err != nil {
+			return err
+		}
+		note := fmt.Sprintf("秒杀请求 %s 冻结", requestID)
+		if c != nil {
+			if err := reserveCoupon(tx, c, requestID); err != nil {
+				return err
+			}
+			note += couponNote(f.CouponID, f.Discount)
+		}
+		frozen = f.Amount
+		return bookTransaction(tx, &account.Transaction{
+			UserID:   userID,
+			Amount:   -f.Amount,
+			Frozen:   f.Amount,
+			Type:     "freeze",
+			Status:   "success",
+			Note:     note,
+			CouponID: f.CouponID,
+		}, ledger.UserAvailable(userID), ledger.UserFrozen(userID), f.Amount)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return frozen, nil
+}
+
+// ReleaseFreeze 下单失败时解冻秒杀请求冻结的资金，退回可用余额。
+// 没有冻结记录、已结算或已解冻时不做任何事；已创建订单的冻结随订单支付或取消处理，这里也不解冻。
+func (s *AccountService) ReleaseFreeze(ctx context.Context, requestID, reason string) error {
+	if requestID == "" {
+		return nil
+	}
+	var snapshot account.Freeze
+	if err := s.db.WithContext(ctx).Where("request_id = ?", requestID).First(&snapshot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		acc, err := lockAccount(tx, snapshot.UserID)
+		if err != nil {
+			return err
+		}
+		f, err := lockFreeze(tx, requestID)
+		if err != nil {
+			return err
+		}
+		if f == nil || f.Status != account.FreezeStatusFrozen || f.OrderID > 0 {
+			return nil
+		}
+		return releaseFreeze(tx, acc, f, fmt.Sprintf("秒杀请求 %s 解冻：%s", requestID, reason))
+	})
+}
+
+// lockFreeze 锁定秒杀请求的冻结记录，不存在时返回 nil。调用方需已锁定对应账户。
+func lockFreeze(tx *gorm.DB, requestID string) (*account.Freeze, error) {
+	if requestID == "" {
+		return nil, nil
+	}
+	var f account.Freeze
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("request_id = ?", requestID).
+		First(&f).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// lockOrderFreeze 锁定订单对应的仍处于冻结状态的资金，没有时返回 nil
+func lockOrderFreeze(tx *gorm.DB, o *order.Order) (*account.Freeze, error) {
+	if o.RequestID == nil {
+		return nil, nil
+	}
+	f, err := lockFreeze(tx, *o.RequestID)
+	if err != nil || f == nil || f.Status != account.FreezeStatusFrozen {
+		return nil, err
+	}
+	return f, nil
+}
+
+// settleFreeze 冻结资金转为订单支付：只减少冻结金额，可用余额已在冻结时扣除；占用的优惠券同时核销
+func settleFreeze(tx *gorm.DB, acc *account.Account, f *account.Freeze, o *order.Order) error {
+	acc.Frozen -= f.Amount
+	if err := tx.Save(acc).Error; err != nil {
+		return err
+	}
+	f.Status = account.FreezeStatusSettled
+	f.OrderID = o.ID
+	if err := tx.Save(f).Error; err != nil {
+		return err
+	}
+	note := fmt.Sprintf("秒杀订单 #%d（冻结资金转支付）", o.ID)
+	if f.CouponID > 0 {
+		if err := settleCoupon(tx, acc.UserID, f.CouponID, o.ID); err != nil {
+			return err
+		}
+		note += couponNote(f.CouponID, f.Discount)
+	}
+	return bookTransaction(tx, &account.Transaction{
+		UserID:   acc.UserID,
+		Frozen:   -f.Amount,
+		Type:     "seckill",
+		Status:   "success",
+		Note:     note,
+		CouponID: f.CouponID,
+	}, ledger.UserFrozen(acc.UserID), ledger.AccountRevenue, f.Amount)
+}
+
+// releaseFreeze 解冻资金，退回可用余额，并退回占用的优惠券
+func releaseFreeze(tx *gorm.DB, acc *account.Account, f *account.Freeze, note string) error {
+	acc.Balance += f.Amount
+	acc.Frozen -= f.Amount
+	if err := tx.Save(acc).Error; err != nil {
+		return err
+	}
+	f.Status = account.FreezeStatusReleased
+	if err := tx.Save(f).Error; err != nil {
+		return err
+	}
+	if f.CouponID > 0 {
+		if err := releaseCoupon(tx, acc.UserID, f.CouponID); err != nil {
+			return err
+		}
+	}
+	return bookTransaction(tx, &account.Transaction{
+		UserID: acc.UserID,
+		Amount: f.Amount,
+		Frozen: -f.Amount,
+		Type:   "unfreeze",
+		Status: "success",
+		Note:   note,
+	}, ledger.UserFrozen(acc.UserID), ledger.UserAvailable(acc.UserID), f.Amount)
+}
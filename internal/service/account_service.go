@@ -4,34 +4,54 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"time"
 
+	radix "github.com/mediocregopher/radix/v3"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 
+	"github.com/example/goseckill/internal/config"
 	"github.com/example/goseckill/internal/datamodels/account"
+	"github.com/example/goseckill/internal/datamodels/ledger"
 	"github.com/example/goseckill/internal/datamodels/order"
 	"github.com/example/goseckill/internal/datamodels/product"
+	"github.com/example/goseckill/internal/datamodels/seckill_activity"
 	"github.com/example/goseckill/internal/datamodels/user"
 	"github.com/example/goseckill/internal/repository/mysql"
 )
 
-// AccountService 提供账户余额与交易能力，并内置购买逻辑
+// AccountService 提供账户余额与交易能力，并内置购买、取消与退款逻辑
 type AccountService struct {
 	db          *gorm.DB
 	accountRepo account.Repository
 	productRepo product.Repository
 	orderRepo   order.Repository
 	userRepo    user.Repository
+	redis       radix.Client
+	events      *SeckillEventPublisher
+	cfg         config.OrderConfig
 }
 
-// NewAccountService 创建账户服务
-func NewAccountService(db *gorm.DB, productRepo product.Repository, orderRepo order.Repository, userRepo user.Repository) *AccountService {
+// NewAccountService 创建账户服务。redis 与 events 用于取消/退款秒杀订单时归还活动的 Redis 库存。
+func NewAccountService(
+	db *gorm.DB,
+	productRepo product.Repository,
+	orderRepo order.Repository,
+	userRepo user.Repository,
+	redis radix.Client,
+	events *SeckillEventPublisher,
+	cfg *config.OrderConfig,
+) *AccountService {
 	return &AccountService{
 		db:          db,
 		accountRepo: mysql.NewAccountRepository(db),
 		productRepo: productRepo,
 		orderRepo:   orderRepo,
 		userRepo:    userRepo,
+		redis:       redis,
+		events:      events,
+		cfg:         *cfg,
 	}
 }
 
@@ -45,18 +65,37 @@ func (s *AccountService) GetSummary(ctx context.Context, userID int64) (*account
 	if acc.Balance == 0 && acc.UserID == userID && s.userRepo != nil {
 		u, err := s.userRepo.GetByID(ctx, userID)
 		if err == nil && u != nil && u.Username == "admin" {
-			acc.Balance = 10000 // 100 元
-			if err := s.accountRepo.Update(ctx, acc); err != nil {
-				return nil, err
-			}
-			_ = s.accountRepo.CreateTransaction(ctx, &account.Transaction{
-				UserID: userID,
-				Amount: 10000,
-				Type:   "gift",
-				Status: "success",
-				Note:   "新用户赠送",
-			})
+			return s.gift(ctx, userID, 10000) // 100 元
+		}
+	}
+	return acc, nil
+}
+
+// gift 余额为 0 时赠送金额，在账户行锁内复查余额，避免并发请求重复赠送
+func (s *AccountService) gift(ctx context.Context, userID, amount int64) (*account.Account, error) {
+	var acc *account.Account
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if acc, err = lockAccount(tx, userID); err != nil {
+			return err
+		}
+		if acc.Balance != 0 {
+			return nil
+		}
+		acc.Balance += amount
+		if err := tx.Save(acc).Error; err != nil {
+			return err
 		}
+		return bookTransaction(tx, &account.Transaction{
+			UserID: userID,
+			Amount: amount,
+			Type:   "gift",
+			Status: "success",
+			Note:   "新用户赠送",
+		}, ledger.AccountGift, ledger.UserAvailable(userID), amount)
+	})
+	if err != nil {
+		return nil, err
 	}
 	return acc, nil
 }
@@ -103,150 +142,398 @@ func (s *AccountService) ListOrdersByUser(ctx context.Context, userID int64) ([]
 	return s.orderRepo.ListByUser(ctx, userID)
 }
 
-// Purchase 购买商品（扣余额、减库存、生成订单、写流水）
-func (s *AccountService) Purchase(ctx context.Context, userID, productID, qty int64) (*order.Order, error) {
-	if qty <= 0 {
-		return nil, errors.New("数量必须大于 0")
+// ErrDuplicateSeckillRequest 同一秒杀请求已经生成过订单
+var ErrDuplicateSeckillRequest = errors.New("秒杀请求已处理")
+
+var (
+	// ErrOrderNotFound 订单不存在或不属于当前用户
+	ErrOrderNotFound = errors.New("订单不存在")
+	// ErrOrderNotPayable 订单不是待支付状态（已支付或已取消）
+	ErrOrderNotPayable = errors.New("订单不是待支付状态")
+	// ErrOrderPaymentExpired 订单已超过支付时限，等待自动取消
+	ErrOrderPaymentExpired = errors.New("订单已超过支付时限")
+	// ErrOrderNotCancellable 订单已取消，或已支付且超过用户可退款时限
+	ErrOrderNotCancellable = errors.New("订单当前不可取消")
+	// ErrOrderNotRefundable 只有已支付的订单可以退款
+	ErrOrderNotRefundable = errors.New("订单未支付或已取消，不能退款")
+)
+
+// SeckillCharge 秒杀下单：在同一个事务中条件扣减秒杀库存、扣款、创建订单和流水，
+// 库存、资金与订单要么一起提交，要么一起回滚。
+// 准入时已冻结资金的请求按冻结金额将冻结资金转为支付；没有冻结记录时按 price（单位分）从余额扣款。
+// requestID 非空时保证幂等：同一请求ID已有订单则返回该订单与 ErrDuplicateSeckillRequest，不重复扣费。
+func (s *AccountService) SeckillCharge(ctx context.Context, userID, productID, activityID, price int64, requestID string) (*order.Order, error) {
+	return s.seckillOrder(ctx, userID, productID, activityID, price, requestID, nil)
+}
+
+// SeckillOrder 秒杀下单但暂不扣款：在同一个事务中条件扣减秒杀库存并创建待支付订单，
+// 准入时冻结的资金继续冻结，用户需在 deadline 前调用 PayOrder 完成支付，
+// 超时由 SeckillOrderService 取消、解冻资金并归还库存。
+// 幂等规则与 SeckillCharge 相同。
+func (s *AccountService) SeckillOrder(ctx context.Context, userID, productID, activityID, price int64, requestID string, deadline time.Time) (*order.Order, error) {
+	return s.seckillOrder(ctx, userID, productID, activityID, price, requestID, &deadline)
+}
+
+// seckillOrder deadline 为 nil 时立即扣款并创建已支付订单，否则创建待支付订单
+func (s *AccountService) seckillOrder(ctx context.Context, userID, productID, activityID, price int64, requestID string, deadline *time.Time) (*order.Order, error) {
+	if price <= 0 {
+		return nil, errors.New("价格必须大于 0")
 	}
 
 	var resultOrder *order.Order
 	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// 1) 锁定/创建账户
-		var acc account.Account
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("user_id = ?", userID).
-			First(&acc).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				acc = account.Account{UserID: userID}
-				if err := tx.Create(&acc).Error; err != nil {
-					return err
-				}
-			} else {
+		acc, err := lockAccount(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		// 2) 幂等校验：账户行锁已将同一用户的请求串行化，此处查询不会与并发重复消息竞争
+		if requestID != "" {
+			var existing order.Order
+			err := tx.Where("request_id = ?", requestID).First(&existing).Error
+			if err == nil {
+				resultOrder = &existing
+				return ErrDuplicateSeckillRequest
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
 				return err
 			}
 		}
 
-		// 2) 锁定商品
-		var p product.Product
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			First(&p, productID).Error; err != nil {
-			return fmt.Errorf("商品不存在: %w", err)
-		}
-		if p.Status != 1 {
-			return fmt.Errorf("商品不可购买")
-		}
-		if p.Stock < qty {
-			return fmt.Errorf("库存不足")
+		// 3) 准入时已冻结资金的请求以冻结金额为订单金额；没有冻结记录的旧消息立即支付时校验余额
+		f, err := lockFreeze(tx, requestID)
+		if err != nil {
+			return err
 		}
-
-		// 3) 计算总价并校验余额
-		total := p.Price * qty
-		if acc.Balance < total {
-			return fmt.Errorf("余额不足，需 ¥%.2f，当前 ¥%.2f", float64(total)/100, float64(acc.Balance)/100)
+		if f != nil && f.Status == account.FreezeStatusFrozen {
+			price = f.Amount
+		} else {
+			f = nil
+			if deadline == nil && acc.Balance < price {
+				return insufficientBalance(price, acc.Balance)
+			}
 		}
 
-		// 4) 扣减余额与库存
-		acc.Balance -= total
-		if err := tx.Save(&acc).Error; err != nil {
+		// 4) 条件扣减秒杀库存（WHERE seckill_stock >= 1），不覆盖其他并发修改
+		if err := s.productRepo.DecrSeckillStock(mysql.WithTx(ctx, tx), productID, 1); err != nil {
 			return err
 		}
-
-		p.Stock -= qty
-		if err := tx.Save(&p).Error; err != nil {
+		var p product.Product
+		if err := tx.First(&p, productID).Error; err != nil {
 			return err
 		}
 
-		// 5) 创建订单
+		// 5) 创建订单，request_id 唯一索引兜底防止重复
 		o := order.Order{
-			UserID:    userID,
-			ProductID: productID,
-			Price:     total,
-			Status:    1, // 已支付
+			UserID:      userID,
+			ProductID:   productID,
+			ActivityID:  activityID,
+			Quantity:    1,
+			Price:       price,
+			Status:      order.StatusCreated,
+			PayDeadline: deadline,
+		}
+		if requestID != "" {
+			o.RequestID = &requestID
+		}
+		if f != nil {
+			o.CouponID = f.CouponID
+			o.Discount = f.Discount
+		}
+		// 明细记录商品原价，秒杀折扣与优惠券减免计入明细优惠
+		unitPrice := p.Price
+		if unitPrice < price {
+			unitPrice = price
+		}
+		o.Items = []*order.OrderItem{{
+			ProductID:   productID,
+			ProductName: p.Name,
+			ActivityID:  activityID,
+			Source:      order.SourceSeckill,
+			Quantity:    1,
+			UnitPrice:   unitPrice,
+			Discount:    unitPrice - price,
+			Amount:      price,
+		}}
+		if deadline == nil {
+			now := time.Now()
+			o.Status = order.StatusPaid
+			o.PaidAt = &now
 		}
 		if err := tx.Create(&o).Error; err != nil {
 			return err
 		}
 		resultOrder = &o
 
-		// 6) 写交易流水
-		if err := tx.Create(&account.Transaction{
-			UserID: userID,
-			Amount: -total,
-			Type:   "purchase",
-			Status: "success",
-			Note:   fmt.Sprintf("订单 #%d", o.ID),
-		}).Error; err != nil {
-			return err
+		// 6) 立即支付时将冻结资金转为支付（或扣减余额）并写交易流水；待支付订单继续占用冻结资金
+		if f != nil {
+			if deadline == nil {
+				return settleFreeze(tx, acc, f, &o)
+			}
+			f.OrderID = o.ID
+			return tx.Save(f).Error
+		}
+		if deadline == nil {
+			return chargeOrder(tx, acc, &o)
 		}
-
 		return nil
 	})
 
 	return resultOrder, err
 }
 
-// SeckillCharge 秒杀扣费（不再操作商品表，只扣减余额、创建订单和流水）
-// price 单位为分，调用方需要自行根据秒杀折扣计算好价格。
-func (s *AccountService) SeckillCharge(ctx context.Context, userID, productID, price int64) (*order.Order, error) {
-	if price <= 0 {
-		return nil, errors.New("价格必须大于 0")
-	}
-
-	var resultOrder *order.Order
+// PayOrder 支付待支付的秒杀订单：在同一事务中扣减余额、写流水并将订单置为已支付。
+// 订单行锁保证支付与超时取消互斥，二者只有一个能生效。
+func (s *AccountService) PayOrder(ctx context.Context, userID, orderID int64) (*order.Order, error) {
+	var o order.Order
 	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// 1) 锁定/创建账户
-		var acc account.Account
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("user_id = ?", userID).
-			First(&acc).Error; err != nil {
+		acc, err := lockAccount(tx, userID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&o, orderID).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				acc = account.Account{UserID: userID}
-				if err := tx.Create(&acc).Error; err != nil {
-					return err
-				}
-			} else {
-				return err
+				return ErrOrderNotFound
 			}
+			return err
 		}
-
-		// 2) 校验余额
-		if acc.Balance < price {
-			return fmt.Errorf("余额不足，需 ¥%.2f，当前 ¥%.2f", float64(price)/100, float64(acc.Balance)/100)
+		if o.UserID != userID {
+			return ErrOrderNotFound
+		}
+		if o.Status != order.StatusCreated {
+			return ErrOrderNotPayable
+		}
+		now := time.Now()
+		if o.PayDeadline != nil && !now.Before(*o.PayDeadline) {
+			return ErrOrderPaymentExpired
+		}
+		// 准入时冻结的资金直接转为支付，否则从可用余额扣款
+		f, err := lockOrderFreeze(tx, &o)
+		if err != nil {
+			return err
+		}
+		if f == nil && acc.Balance < o.Price {
+			return insufficientBalance(o.Price, acc.Balance)
 		}
 
-		// 3) 扣减余额
-		acc.Balance -= price
-		if err := tx.Save(&acc).Error; err != nil {
+		o.Status = order.StatusPaid
+		o.PaidAt = &now
+		if err := tx.Save(&o).Error; err != nil {
 			return err
 		}
+		if f != nil {
+			return settleFreeze(tx, acc, f, &o)
+		}
+		return chargeOrder(tx, acc, &o)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
 
-		// 4) 创建订单（状态为已支付）
-		o := order.Order{
-			UserID:    userID,
-			ProductID: productID,
-			Price:     price,
-			Status:    1, // 已支付
+// lockAccount 锁定用户账户行，不存在时创建
+func lockAccount(tx *gorm.DB, userID int64) (*account.Account, error) {
+	var acc account.Account
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userID).
+		First(&acc).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
 		}
-		if err := tx.Create(&o).Error; err != nil {
-			return err
+		acc = account.Account{UserID: userID}
+		if err := tx.Create(&acc).Error; err != nil {
+			return nil, err
 		}
-		resultOrder = &o
+	}
+	return &acc, nil
+}
 
-		// 5) 写交易流水
-		if err := tx.Create(&account.Transaction{
-			UserID: userID,
-			Amount: -price,
-			Type:   "seckill",
-			Status: "success",
-			Note:   fmt.Sprintf("秒杀订单 #%d", o.ID),
-		}).Error; err != nil {
-			return err
+// chargeOrder 从已锁定的账户扣除秒杀订单金额并写交易流水
+func chargeOrder(tx *gorm.DB, acc *account.Account, o *order.Order) error {
+	acc.Balance -= o.Price
+	if err := tx.Save(acc).Error; err != nil {
+		return err
+	}
+	return bookTransaction(tx, &account.Transaction{
+		UserID: acc.UserID,
+		Amount: -o.Price,
+		Type:   "seckill",
+		Status: "success",
+		Note:   fmt.Sprintf("秒杀订单 #%d", o.ID),
+	}, ledger.UserAvailable(acc.UserID), ledger.AccountRevenue, o.Price)
+}
+
+// CancelOrder 用户取消自己的订单：待支付订单直接取消；已支付订单在支付后 UserRefundWindowSeconds 内
+// 可取消并全额退款。库存归还规则见 restoreOrderStock。
+func (s *AccountService) CancelOrder(ctx context.Context, userID, orderID int64) (*order.Order, error) {
+	window := time.Duration(s.cfg.UserRefundWindowSeconds) * time.Second
+	return s.cancelOrder(ctx, orderID, "用户取消", func(o *order.Order) error {
+		if o.UserID != userID {
+			return ErrOrderNotFound
 		}
+		switch o.Status {
+		case order.StatusCreated:
+			return nil
+		case order.StatusPaid:
+			paidAt := o.CreatedAt
+			if o.PaidAt != nil {
+				paidAt = *o.PaidAt
+			}
+			if window > 0 && time.Since(paidAt) < window {
+				return nil
+			}
+		}
+		return ErrOrderNotCancellable
+	})
+}
 
+// Refund 后台退款：将已支付订单置为已取消，全额退回余额并归还库存
+func (s *AccountService) Refund(ctx context.Context, orderID int64, reason string) (*order.Order, error) {
+	if reason == "" {
+		reason = "后台退款"
+	}
+	return s.cancelOrder(ctx, orderID, reason, func(o *order.Order) error {
+		if o.Status != order.StatusPaid {
+			return ErrOrderNotRefundable
+		}
 		return nil
 	})
+}
 
-	return resultOrder, err
+// cancelOrder 在同一个事务中取消订单：待支付订单解冻资金，已支付订单退回余额并写 refund 流水，
+// 订单使用的优惠券一并退回，然后归还库存。
+// 先锁账户再锁订单，与 PayOrder 的加锁顺序一致；check 在订单行锁内校验是否允许取消。
+// 归还到活动秒杀库存时，事务提交后再归还 Redis 库存。
+func (s *AccountService) cancelOrder(ctx context.Context, orderID int64, note string, check func(o *order.Order) error) (*order.Order, error) {
+	snapshot, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+
+	var o order.Order
+	toSeckill := false
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		acc, err := lockAccount(tx, snapshot.UserID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&o, orderID).Error; err != nil {
+			return err
+		}
+		if err := check(&o); err != nil {
+			return err
+		}
+
+		// 待支付订单解冻准入时冻结的资金，已支付订单退回余额
+		if o.Status == order.StatusCreated {
+			f, err := lockOrderFreeze(tx, &o)
+			if err != nil {
+				return err
+			}
+			if f != nil {
+				if err := releaseFreeze(tx, acc, f, fmt.Sprintf("订单 #%d 取消解冻：%s", o.ID, note)); err != nil {
+					return err
+				}
+			}
+		}
+		if o.Status == order.StatusPaid {
+			acc.Balance += o.Price
+			if err := tx.Save(acc).Error; err != nil {
+				return err
+			}
+			if err := bookTransaction(tx, &account.Transaction{
+				UserID: acc.UserID,
+				Amount: o.Price,
+				Type:   "refund",
+				Status: "success",
+				Note:   fmt.Sprintf("订单 #%d 退款：%s", o.ID, note),
+			}, ledger.AccountRefund, ledger.UserAvailable(acc.UserID), o.Price); err != nil {
+				return err
+			}
+			// 退款后退回订单使用的优惠券
+			if o.CouponID > 0 {
+				if err := releaseCoupon(tx, o.UserID, o.CouponID); err != nil {
+					return err
+				}
+			}
+		}
+
+		o.Status = order.StatusCancelled
+		if err := tx.Save(&o).Error; err != nil {
+			return err
+		}
+		toSeckill, err = restoreOrderStock(tx, &o)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if toSeckill {
+		restoreSeckillRedis(s.redis, s.events, &o)
+	}
+	return &o, nil
+}
+
+// restoreOrderStock 在事务中归还已取消订单占用的库存，返回是否归还到了活动秒杀库存。
+// 活动商品尚未结算时归还 MySQL 秒杀库存（调用方需在提交后归还 Redis 库存）；
+// 活动已结束/取消并已结算，或非活动订单时归还普通库存；有订单明细的按明细逐个商品归还。
+func restoreOrderStock(tx *gorm.DB, o *order.Order) (bool, error) {
+	toSeckill := false
+	if o.ActivityID > 0 {
+		var settled int64
+		if err := tx.Model(&seckill_activity.SeckillSettlement{}).
+			Where("activity_id = ? AND product_id = ?", o.ActivityID, o.ProductID).
+			Count(&settled).Error; err != nil {
+			return false, err
+		}
+		toSeckill = settled == 0
+	}
+	column := "stock"
+	if toSeckill {
+		column = "seckill_stock"
+	}
+
+	// 购物车结算的订单按明细逐个商品归还
+	var items []*order.OrderItem
+	if o.ActivityID == 0 {
+		if err := tx.Where("order_id = ?", o.ID).Find(&items).Error; err != nil {
+			return false, err
+		}
+	}
+	if len(items) == 0 {
+		qty := o.Quantity
+		if qty <= 0 {
+			qty = 1
+		}
+		items = []*order.OrderItem{{ProductID: o.ProductID, Quantity: qty}}
+	}
+	for _, it := range items {
+		if err := tx.Model(&product.Product{}).
+			Where("id = ?", it.ProductID).
+			Update(column, gorm.Expr(column+" + ?", it.Quantity)).Error; err != nil {
+			return false, err
+		}
+	}
+	return toSeckill, nil
+}
+
+// restoreSeckillRedis 归还秒杀订单在活动 Redis 库存中占用的一件并回退用户的限购计数。
+// MySQL 事务已提交，失败只能记录日志，由 stock-sync 以 MySQL 为准修复。
+func restoreSeckillRedis(redis radix.Client, events *SeckillEventPublisher, o *order.Order) {
+	if err := redis.Do(seckillRollbackScript.Cmd(nil,
+		fmt.Sprintf(redisSeckillLimitKey, o.UserID, o.ProductID, o.ActivityID),
+		SeckillStockKey(o.ProductID, o.ActivityID),
+	)); err != nil {
+		log.Printf("failed to restore redis stock of cancelled order %d: %v", o.ID, err)
+		GetMonitor().RecordRedisError()
+		return
+	}
+	events.NotifyStock(o.ProductID, o.ActivityID)
 }
 
 // Recharge 简单充值示例，方便测试
@@ -272,13 +559,13 @@ func (s *AccountService) Recharge(ctx context.Context, userID, amount int64) (*a
 		if err := tx.Save(&acc).Error; err != nil {
 			return err
 		}
-		if err := tx.Create(&account.Transaction{
+		if err := bookTransaction(tx, &account.Transaction{
 			UserID: userID,
 			Amount: amount,
 			Type:   "recharge",
 			Status: "success",
 			Note:   "手动充值",
-		}).Error; err != nil {
+		}, ledger.AccountRecharge, ledger.UserAvailable(userID), amount); err != nil {
 			return err
 		}
 		return nil
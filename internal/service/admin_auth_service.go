@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"gorm.io/gorm"
+
+	"github.com/example/goseckill/internal/auth"
+	"github.com/example/goseckill/internal/config"
+	"github.com/example/goseckill/internal/datamodels/admin_user"
+)
+
+var (
+	// ErrAdminLoginFailed 用户名或密码错误，或账号已停用
+	ErrAdminLoginFailed = errors.New("用户名或密码错误")
+	// ErrAdminUnauthorized 令牌无效、已过期或账号已停用
+	ErrAdminUnauthorized = errors.New("未登录或登录已失效")
+	// ErrAdminNotFound 管理员不存在
+	ErrAdminNotFound = errors.New("管理员不存在")
+	// ErrInvalidAdminRole 未定义的角色
+	ErrInvalidAdminRole = errors.New("无效的角色")
+)
+
+// AdminAuthService 后台管理员账号、登录与令牌校验
+type AdminAuthService struct {
+	repo   admin_user.Repository
+	cfg    *config.AdminAuthConfig
+	hasher auth.PasswordHasher
+}
+
+// NewAdminAuthService 创建管理员鉴权服务
+func NewAdminAuthService(repo admin_user.Repository, cfg *config.AdminAuthConfig, hasher auth.PasswordHasher) *AdminAuthService {
+	return &AdminAuthService{repo: repo, cfg: cfg, hasher: hasher}
+}
+
+// EnsureBootstrap 没有任何管理员时按配置创建初始超级管理员
+func (s *AdminAuthService) EnsureBootstrap(ctx context.Context) error {
+	n, err := s.repo.Count(ctx)
+	if err != nil || n > 0 {
+		return err
+	}
+	if s.cfg.BootstrapUsername == "" || s.cfg.BootstrapPassword == "" {
+		return errors.New("没有任何管理员账号，且未配置初始管理员密码")
+	}
+	if _, err := s.Create(ctx, s.cfg.BootstrapUsername, s.cfg.BootstrapPassword, auth.RoleAdmin); err != nil {
+		return err
+	}
+	log.Printf("⚠️  已创建初始超级管理员 %s，请登录后立即修改密码", s.cfg.BootstrapUsername)
+	return nil
+}
+
+// Login 校验用户名密码并签发管理员令牌，旧版 SHA-256 哈希在登录成功后迁移为 bcrypt
+func (s *AdminAuthService) Login(ctx context.Context, username, password string) (string, *admin_user.AdminUser, error) {
+	u, err := s.repo.GetByUsername(ctx, username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil, ErrAdminLoginFailed
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	ok, rehash := auth.VerifyPassword(s.hasher, u.Password, u.Salt, password)
+	if u.Disabled || !ok {
+		return "", nil, ErrAdminLoginFailed
+	}
+	if rehash {
+		if err := s.setPassword(u, password); err == nil {
+			if err := s.repo.Update(ctx, u); err != nil {
+				log.Printf("rehash password of admin %d failed: %v", u.ID, err)
+			}
+		}
+	}
+	token, err := auth.GenerateAdminToken(s.cfg, u.ID, u.Username, u.Role)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, u, nil
+}
+
+// Authenticate 校验令牌并重新读取管理员账号：停用或角色变更立即生效，不依赖令牌中的角色
+func (s *AdminAuthService) Authenticate(ctx context.Context, token string) (*admin_user.AdminUser, error) {
+	claims, err := auth.ParseAdminToken(s.cfg, token)
+	if err != nil {
+		return nil, ErrAdminUnauthorized
+	}
+	u, err := s.repo.GetByID(ctx, claims.AdminID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAdminUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+	if u.Disabled {
+		return nil, ErrAdminUnauthorized
+	}
+	return u, nil
+}
+
+// Create 创建管理员
+func (s *AdminAuthService) Create(ctx context.Context, username, password, role string) (*admin_user.AdminUser, error) {
+	if username == "" || len(password) < 6 {
+		return nil, errors.New("用户名不能为空，密码至少 6 位")
+	}
+	if !auth.ValidRole(role) {
+		return nil, ErrInvalidAdminRole
+	}
+	u := &admin_user.AdminUser{Username: username, Role: role}
+	if err := s.setPassword(u, password); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Get 查询管理员
+func (s *AdminAuthService) Get(ctx context.Context, id int64) (*admin_user.AdminUser, error) {
+	u, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAdminNotFound
+	}
+	return u, err
+}
+
+// List 查询全部管理员
+func (s *AdminAuthService) List(ctx context.Context) ([]*admin_user.AdminUser, error) {
+	return s.repo.List(ctx)
+}
+
+// Update 修改管理员的角色、停用状态或密码，空值表示不修改
+func (s *AdminAuthService) Update(ctx context.Context, id int64, role string, disabled *bool, password string) (*admin_user.AdminUser, error) {
+	u, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAdminNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if role != "" {
+		if !auth.ValidRole(role) {
+			return nil, ErrInvalidAdminRole
+		}
+		u.Role = role
+	}
+	if disabled != nil {
+		u.Disabled = *disabled
+	}
+	if password != "" {
+		if len(password) < 6 {
+			return nil, errors.New("密码至少 6 位")
+		}
+		if err := s.setPassword(u, password); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.Update(ctx, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// setPassword 以 bcrypt 计算密码哈希，清空旧版哈希使用的盐
+func (s *AdminAuthService) setPassword(u *admin_user.AdminUser, password string) error {
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	u.Password = hash
+	u.Salt = ""
+	return nil
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/example/goseckill/internal/datamodels/audit"
+)
+
+// 审计操作对象类型
+const (
+	AuditEntityProduct    = "product"
+	AuditEntityOrder      = "order"
+	AuditEntityAccount    = "account"
+	AuditEntityCoupon     = "coupon_template"
+	AuditEntityActivity   = "seckill_activity"
+	AuditEntityDeadLetter = "dead_letter"
+	AuditEntityChat       = "chat_message"
+	AuditEntityAdminUser  = "admin_user"
+)
+
+// AuditActor 执行操作的管理员及其来源 IP，由后台鉴权中间件放入请求上下文
+type AuditActor struct {
+	ID   int64
+	Name string
+	IP   string
+}
+
+type auditActorKey struct{}
+
+// WithAuditActor 在上下文中记录操作人，之后经由该上下文的写操作都记在此人名下
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// auditActorFrom 取上下文中的操作人，没有时视为系统（调度器、命令行工具等）
+func auditActorFrom(ctx context.Context) AuditActor {
+	if actor, ok := ctx.Value(auditActorKey{}).(AuditActor); ok {
+		return actor
+	}
+	return AuditActor{Name: "system"}
+}
+
+// AuditService 记录与查询后台写操作的审计日志
+type AuditService struct {
+	repo audit.Repository
+}
+
+// NewAuditService 创建审计服务
+func NewAuditService(repo audit.Repository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Record 记录一次写操作，before / after 为修改前后的对象（序列化为 JSON，nil 表示没有）。
+// 审计写入失败只记录日志，不影响已经完成的业务操作；s 为 nil 时不记录。
+func (s *AuditService) Record(ctx context.Context, action, entityType string, entityID int64, before, after interface{}) {
+	if s == nil {
+		return
+	}
+	actor := auditActorFrom(ctx)
+	l := &audit.AuditLog{
+		ActorID:    actor.ID,
+		ActorName:  actor.Name,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     auditJSON(before),
+		After:      auditJSON(after),
+		IP:         actor.IP,
+	}
+	// 请求可能已经结束，审计记录仍需写入
+	if err := s.repo.Create(context.WithoutCancel(ctx), l); err != nil {
+		log.Printf("write audit log %s %s#%d by %s failed: %v", action, entityType, entityID, actor.Name, err)
+	}
+}
+
+// List 按条件查询审计日志，返回当前页与总数
+func (s *AuditService) List(ctx context.Context, f audit.Filter) ([]*audit.AuditLog, int64, error) {
+	if f.Limit <= 0 || f.Limit > 200 {
+		f.Limit = 50
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	return s.repo.List(ctx, f)
+}
+
+func auditJSON(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil || string(b) == "null" {
+		return ""
+	}
+	return string(b)
+}
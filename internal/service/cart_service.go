@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/example/goseckill/internal/datamodels/cart"
+	"github.com/example/goseckill/internal/datamodels/order"
+	"github.com/example/goseckill/internal/datamodels/product"
+)
+
+// maxCartQuantity 购物车中单个商品的最大数量
+const maxCartQuantity = 99
+
+var (
+	// ErrCartEmpty 购物车中没有要结算的商品
+	ErrCartEmpty = errors.New("购物车为空")
+	// ErrCartQuantity 购物车中商品数量超出范围
+	ErrCartQuantity = errors.New("数量必须在 1-99 之间")
+)
+
+// CartLine 购物车中的一种商品及其当前价格与库存
+type CartLine struct {
+	ProductID int64  `json:"product_id"`
+	Quantity  int64  `json:"quantity"`
+	Name      string `json:"name"`
+	Price     int64  `json:"price"`     // 当前单价，单位分
+	Subtotal  int64  `json:"subtotal"`  // 当前单价 × 数量
+	Stock     int64  `json:"stock"`     // 当前普通库存
+	Available bool   `json:"available"` // 商品在售且库存充足
+}
+
+// CartService 用户购物车（持久化在 MySQL），结算通过 AccountService.Checkout 完成
+type CartService struct {
+	repo        cart.Repository
+	productRepo product.Repository
+	accounts    *AccountService
+}
+
+// NewCartService 创建购物车服务
+func NewCartService(repo cart.Repository, productRepo product.Repository, accounts *AccountService) *CartService {
+	return &CartService{repo: repo, productRepo: productRepo, accounts: accounts}
+}
+
+// List 查询购物车，附带商品当前价格与是否可购买；已删除的商品标记为不可购买
+func (s *CartService) List(ctx context.Context, userID int64) ([]*CartLine, error) {
+	items, err := s.repo.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]*CartLine, 0, len(items))
+	for _, it := range items {
+		line := &CartLine{ProductID: it.ProductID, Quantity: it.Quantity}
+		p, err := s.productRepo.GetByID(ctx, it.ProductID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if p != nil && err == nil {
+			line.Name = p.Name
+			line.Price = p.Price
+			line.Subtotal = p.Price * it.Quantity
+			line.Stock = p.Stock
+			line.Available = p.Status == 1 && p.Stock >= it.Quantity
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// Add 把商品加入购物车，已存在时累加数量，累加后超过上限返回 ErrCartQuantity
+func (s *CartService) Add(ctx context.Context, userID, productID, qty int64) error {
+	if qty <= 0 || qty > maxCartQuantity {
+		return ErrCartQuantity
+	}
+	if err := s.checkProduct(ctx, productID); err != nil {
+		return err
+	}
+	item, err := s.repo.Get(ctx, userID, productID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if item != nil && item.Quantity+qty > maxCartQuantity {
+		return ErrCartQuantity
+	}
+	return s.repo.Add(ctx, userID, productID, qty, maxCartQuantity)
+}
+
+// Update 修改购物车中商品的数量，数量为 0 时移除
+func (s *CartService) Update(ctx context.Context, userID, productID, qty int64) error {
+	if qty <= 0 {
+		return s.repo.Remove(ctx, userID, productID)
+	}
+	if qty > maxCartQuantity {
+		return ErrCartQuantity
+	}
+	if err := s.checkProduct(ctx, productID); err != nil {
+		return err
+	}
+	return s.repo.Set(ctx, userID, productID, qty)
+}
+
+// Remove 从购物车移除商品
+func (s *CartService) Remove(ctx context.Context, userID, productID int64) error {
+	return s.repo.Remove(ctx, userID, productID)
+}
+
+// Checkout 结算购物车：productIDs 为空时结算全部商品，否则只结算指定商品。
+// 下单成功后从购物车移除已结算的商品；失败时购物车保持不变，错误为 *CheckoutItemError 时指明失败的商品。
+func (s *CartService) Checkout(ctx context.Context, userID int64, productIDs []int64, couponID int64) (*order.Order, error) {
+	list, err := s.repo.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[int64]bool, len(productIDs))
+	for _, id := range productIDs {
+		selected[id] = true
+	}
+	items := make([]PurchaseItem, 0, len(list))
+	ids := make([]int64, 0, len(list))
+	for _, it := range list {
+		if len(selected) > 0 && !selected[it.ProductID] {
+			continue
+		}
+		items = append(items, PurchaseItem{ProductID: it.ProductID, Quantity: it.Quantity})
+		ids = append(ids, it.ProductID)
+	}
+	if len(items) == 0 {
+		return nil, ErrCartEmpty
+	}
+
+	o, err := s.accounts.Checkout(ctx, userID, items, couponID)
+	if err != nil {
+		return nil, err
+	}
+	// 订单已提交，清理购物车失败不影响结果
+	_ = s.repo.Remove(ctx, userID, ids...)
+	return o, nil
+}
+
+func (s *CartService) checkProduct(ctx context.Context, productID int64) error {
+	p, err := s.productRepo.GetByID(ctx, productID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &CheckoutItemError{ProductID: productID, Err: ErrProductNotFound}
+	}
+	if err != nil {
+		return err
+	}
+	if p.Status != 1 {
+		return &CheckoutItemError{ProductID: productID, Err: ErrProductUnavailable}
+	}
+	return nil
+}
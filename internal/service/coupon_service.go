@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/goseckill/internal/datamodels/coupon"
+	"github.com/example/goseckill/internal/datamodels/product"
+)
+
+var (
+	// ErrCouponNotFound 优惠券不存在或不属于当前用户
+	ErrCouponNotFound = errors.New("优惠券不存在")
+	// ErrCouponTemplateNotFound 优惠券模板不存在
+	ErrCouponTemplateNotFound = errors.New("优惠券模板不存在")
+	// ErrCouponUnavailable 优惠券已使用、被其他秒杀请求占用或不在有效期内
+	ErrCouponUnavailable = errors.New("优惠券不可用")
+	// ErrCouponNotApplicable 优惠券不适用于该商品或订单金额未达门槛
+	ErrCouponNotApplicable = errors.New("优惠券不适用于该订单")
+	// ErrCouponSoldOut 优惠券已发完
+	ErrCouponSoldOut = errors.New("优惠券已发完")
+	// ErrCouponClaimLimit 用户持有该券已达上限
+	ErrCouponClaimLimit = errors.New("已达到该优惠券的领取上限")
+	// ErrCouponNotClaimable 该券只能由后台发放
+	ErrCouponNotClaimable = errors.New("该优惠券不能自行领取")
+)
+
+// isCouponError 是否为优惠券校验失败（用户侧错误）
+func isCouponError(err error) bool {
+	return errors.Is(err, ErrCouponNotFound) || errors.Is(err, ErrCouponUnavailable) || errors.Is(err, ErrCouponNotApplicable)
+}
+
+// UserCoupon 用户持有的优惠券及其模板
+type UserCoupon struct {
+	*coupon.Coupon
+	Template *coupon.CouponTemplate `json:"template"`
+}
+
+// CouponService 优惠券模板管理、发放与领取。下单时的核销见 applyCoupon。
+type CouponService struct {
+	db   *gorm.DB
+	repo coupon.Repository
+}
+
+// NewCouponService 创建优惠券服务
+func NewCouponService(db *gorm.DB, repo coupon.Repository) *CouponService {
+	return &CouponService{db: db, repo: repo}
+}
+
+// CreateTemplate 校验并创建优惠券模板
+func (s *CouponService) CreateTemplate(ctx context.Context, t *coupon.CouponTemplate) error {
+	if t.Name == "" {
+		return errors.New("优惠券名称不能为空")
+	}
+	switch t.Type {
+	case coupon.TypeFixed:
+		if t.Value <= 0 {
+			return errors.New("减免金额必须大于 0")
+		}
+	case coupon.TypePercent:
+		if t.Value <= 0 || t.Value >= 100 {
+			return errors.New("减免百分比必须在 1-99 之间")
+		}
+	default:
+		return fmt.Errorf("不支持的优惠类型: %s", t.Type)
+	}
+	if t.MinSpend < 0 || t.TotalLimit < 0 || t.ProductID < 0 {
+		return errors.New("参数错误")
+	}
+	if !t.ValidTo.After(t.ValidFrom) {
+		return errors.New("有效期结束时间必须晚于开始时间")
+	}
+	if t.PerUserLimit <= 0 {
+		t.PerUserLimit = 1
+	}
+	t.Issued = 0
+	return s.repo.CreateTemplate(ctx, t)
+}
+
+// ListTemplates 查询全部优惠券模板
+func (s *CouponService) ListTemplates(ctx context.Context) ([]*coupon.CouponTemplate, error) {
+	return s.repo.ListTemplates(ctx)
+}
+
+// Issue 后台向用户发放一张优惠券，受发放总量与每人上限约束
+func (s *CouponService) Issue(ctx context.Context, templateID, userID int64) (*coupon.Coupon, error) {
+	return s.issue(ctx, templateID, userID, false)
+}
+
+// Claim 用户自行领取一张优惠券，只能领取允许领取的模板
+func (s *CouponService) Claim(ctx context.Context, userID, templateID int64) (*coupon.Coupon, error) {
+	return s.issue(ctx, templateID, userID, true)
+}
+
+func (s *CouponService) issue(ctx context.Context, templateID, userID int64, claim bool) (*coupon.Coupon, error) {
+	var c *coupon.Coupon
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 锁定模板行，发放数量与每人上限的校验和计数在锁内完成
+		var t coupon.CouponTemplate
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, templateID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCouponTemplateNotFound
+			}
+			return err
+		}
+		if claim && !t.Claimable {
+			return ErrCouponNotClaimable
+		}
+		if !time.Now().Before(t.ValidTo) {
+			return fmt.Errorf("%w：已过有效期", ErrCouponUnavailable)
+		}
+		if t.TotalLimit > 0 && t.Issued >= t.TotalLimit {
+			return ErrCouponSoldOut
+		}
+		var held int64
+		if err := tx.Model(&coupon.Coupon{}).
+			Where("template_id = ? AND user_id = ?", templateID, userID).
+			Count(&held).Error; err != nil {
+			return err
+		}
+		if held >= t.PerUserLimit {
+			return ErrCouponClaimLimit
+		}
+
+		c = &coupon.Coupon{TemplateID: templateID, UserID: userID, Status: coupon.StatusUnused}
+		if err := tx.Create(c).Error; err != nil {
+			return err
+		}
+		return tx.Model(&t).Update("issued", gorm.Expr("issued + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ListByUser 查询用户持有的优惠券
+func (s *CouponService) ListByUser(ctx context.Context, userID int64) ([]*UserCoupon, error) {
+	list, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(list))
+	for _, c := range list {
+		ids = append(ids, c.TemplateID)
+	}
+	templates, err := s.repo.ListTemplatesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*coupon.CouponTemplate, len(templates))
+	for _, t := range templates {
+		byID[t.ID] = t
+	}
+	out := make([]*UserCoupon, 0, len(list))
+	for _, c := range list {
+		out = append(out, &UserCoupon{Coupon: c, Template: byID[c.TemplateID]})
+	}
+	return out, nil
+}
+
+// couponLine 订单中的一种商品及其金额，用于计算优惠券的适用金额；applyCoupon 把减免按金额比例分摊到 discount
+type couponLine struct {
+	product  *product.Product
+	amount   int64
+	discount int64
+}
+
+// applyCoupon 锁定用户的一张未使用优惠券，校验有效期、适用场景、商品范围与金额门槛，
+// 返回券和减免金额：门槛与减免按适用范围内商品的金额合计计算，并分摊到各商品的 discount。
+// 调用方需已锁定账户，并在事务内继续占用或核销该券。
+func applyCoupon(tx *gorm.DB, userID, couponID int64, lines []couponLine, seckill bool) (*coupon.Coupon, int64, error) {
+	c, t, err := lockCoupon(tx, userID, couponID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.Status != coupon.StatusUnused {
+		return nil, 0, fmt.Errorf("%w：已使用或正在使用", ErrCouponUnavailable)
+	}
+	if !t.Valid(time.Now()) {
+		return nil, 0, fmt.Errorf("%w：不在有效期内", ErrCouponUnavailable)
+	}
+	if seckill && !t.Seckill {
+		return nil, 0, fmt.Errorf("%w：不能用于秒杀", ErrCouponNotApplicable)
+	}
+	var eligible int64
+	applicable := make([]int, 0, len(lines))
+	for i, l := range lines {
+		if (t.ProductID > 0 && t.ProductID != l.product.ID) || (t.Category != "" && t.Category != l.product.Category) {
+			continue
+		}
+		eligible += l.amount
+		applicable = append(applicable, i)
+	}
+	if eligible == 0 {
+		return nil, 0, fmt.Errorf("%w：商品不在适用范围内", ErrCouponNotApplicable)
+	}
+	if eligible < t.MinSpend {
+		return nil, 0, fmt.Errorf("%w：需满 ¥%.2f", ErrCouponNotApplicable, float64(t.MinSpend)/100)
+	}
+	discount := t.Discount(eligible)
+
+	// 按金额比例分摊到适用的商品，余数计入最后一个适用商品
+	remaining := discount
+	for _, i := range applicable {
+		share := discount * lines[i].amount / eligible
+		lines[i].discount = share
+		remaining -= share
+	}
+	lines[applicable[len(applicable)-1]].discount += remaining
+	return c, discount, nil
+}
+
+// lockCoupon 锁定用户的优惠券并读取其模板
+func lockCoupon(tx *gorm.DB, userID, couponID int64) (*coupon.Coupon, *coupon.CouponTemplate, error) {
+	var c coupon.Coupon
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND user_id = ?", couponID, userID).
+		First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrCouponNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var t coupon.CouponTemplate
+	if err := tx.First(&t, c.TemplateID).Error; err != nil {
+		return nil, nil, err
+	}
+	return &c, &t, nil
+}
+
+// useCoupon 核销优惠券，记录使用该券的订单
+func useCoupon(tx *gorm.DB, c *coupon.Coupon, orderID int64) error {
+	now := time.Now()
+	c.Status = coupon.StatusUsed
+	c.OrderID = orderID
+	c.UsedAt = &now
+	return tx.Save(c).Error
+}
+
+// reserveCoupon 秒杀准入时占用优惠券，等订单支付后核销、下单失败或订单取消后退回
+func reserveCoupon(tx *gorm.DB, c *coupon.Coupon, requestID string) error {
+	c.Status = coupon.StatusLocked
+	c.RequestID = requestID
+	return tx.Save(c).Error
+}
+
+// settleCoupon 秒杀订单支付后核销占用的优惠券
+func settleCoupon(tx *gorm.DB, userID, couponID, orderID int64) error {
+	c, _, err := lockCoupon(tx, userID, couponID)
+	if err != nil {
+		return err
+	}
+	return useCoupon(tx, c, orderID)
+}
+
+// releaseCoupon 退回优惠券（秒杀下单失败、订单取消或退款），券已过期的仍然退回，只是不能再使用
+func releaseCoupon(tx *gorm.DB, userID, couponID int64) error {
+	c, _, err := lockCoupon(tx, userID, couponID)
+	if errors.Is(err, ErrCouponNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if c.Status == coupon.StatusUnused {
+		return nil
+	}
+	c.Status = coupon.StatusUnused
+	c.RequestID = ""
+	c.OrderID = 0
+	c.UsedAt = nil
+	return tx.Save(c).Error
+}
+
+// couponNote 交易流水中的优惠券说明
+func couponNote(couponID, discount int64) string {
+	return fmt.Sprintf("（优惠券 #%d 减免 ¥%.2f）", couponID, float64(discount)/100)
+}
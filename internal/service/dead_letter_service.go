@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	radix "github.com/mediocregopher/radix/v3"
+	"gorm.io/gorm"
+
+	"github.com/example/goseckill/internal/datamodels/dead_letter"
+	"github.com/example/goseckill/internal/datamodels/seckill_activity"
+	"github.com/example/goseckill/internal/infra/mq"
+)
+
+var (
+	// ErrDeadLetterNotPending 死信已被重新投递或丢弃
+	ErrDeadLetterNotPending = errors.New("死信已处理，不能重复操作")
+	// ErrDeadLetterNotRolledBack 死信占用的库存还没有归还（写入死信的步骤仍在重试），暂不能重新投递
+	ErrDeadLetterNotRolledBack = errors.New("死信库存尚未归还，请稍后再试")
+)
+
+// DeadLetterService 秒杀死信管理：记录多次重试仍失败的消息，并支持后台重新投递或丢弃
+type DeadLetterService struct {
+	repo       dead_letter.Repository
+	activities seckill_activity.Repository
+	tickets    *SeckillTicketService
+	events     *SeckillEventPublisher
+	accounts   *AccountService
+	redis      radix.Client
+	publisher  mq.Publisher
+}
+
+// NewDeadLetterService 创建死信服务
+func NewDeadLetterService(
+	repo dead_letter.Repository,
+	activities seckill_activity.Repository,
+	tickets *SeckillTicketService,
+	events *SeckillEventPublisher,
+	accounts *AccountService,
+	redis radix.Client,
+	publisher mq.Publisher,
+) *DeadLetterService {
+	return &DeadLetterService{
+		repo:       repo,
+		activities: activities,
+		tickets:    tickets,
+		events:     events,
+		accounts:   accounts,
+		redis:      redis,
+		publisher:  publisher,
+	}
+}
+
+// Record 将秒杀消息写入死信，并最终归还其在 Redis 中占用的库存与限购计数，解冻准入时冻结的资金。
+// 任一步骤失败时消息会被重新投递，Record 可以安全地重复执行：同一请求只记录一条死信，库存只归还一次，
+// 解冻本身是幂等的。m 为 nil 表示消息体无法解析，此时只记录原文。
+func (s *DeadLetterService) Record(ctx context.Context, body []byte, m *SeckillMessage, attempts int, reason string) error {
+	d, err := s.record(ctx, body, m, attempts, truncate(reason, 512))
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return nil
+	}
+	if !d.RolledBack {
+		if err := s.rollbackOnce(ctx, d, m); err != nil {
+			return err
+		}
+	}
+	if s.accounts != nil {
+		if err := s.accounts.ReleaseFreeze(ctx, m.RequestID, "下单失败: "+truncate(reason, 128)); err != nil {
+			GetMonitor().RecordDBError()
+			return err
+		}
+	}
+	return nil
+}
+
+// record 写入死信。同一请求已有死信时沿用原记录：重复投递的消息不再新增记录，
+// 重新投递后再次失败的消息回到待处理并更新尝试次数与失败原因。
+func (s *DeadLetterService) record(ctx context.Context, body []byte, m *SeckillMessage, attempts int, reason string) (*dead_letter.DeadLetter, error) {
+	d := &dead_letter.DeadLetter{
+		Queue:    SeckillQueue,
+		Body:     string(body),
+		Attempts: attempts,
+		Reason:   reason,
+		Status:   dead_letter.StatusPending,
+	}
+	if m != nil {
+		d.UserID = m.UserID
+		d.ProductID = m.ProductID
+	}
+	if m == nil || m.RequestID == "" {
+		return d, s.repo.Create(ctx, d)
+	}
+
+	requestID := m.RequestID
+	d.RequestID = &requestID
+	existing, err := s.repo.GetByRequestID(ctx, requestID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		createErr := s.repo.Create(ctx, d)
+		if createErr == nil {
+			return d, nil
+		}
+		// 并发写入同一请求时唯一索引冲突，改为读取已有记录
+		if existing, err = s.repo.GetByRequestID(ctx, requestID); err != nil {
+			return nil, createErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if existing.Status == dead_letter.StatusRedriven {
+		if _, err := s.repo.Reopen(ctx, existing.ID, attempts, reason); err != nil {
+			return nil, err
+		}
+		existing.Status, existing.Attempts, existing.Reason = dead_letter.StatusPending, attempts, reason
+	}
+	return existing, nil
+}
+
+// rollbackOnce 先抢占死信的库存归还标记再归还库存，归还失败时撤销标记，由重新投递的消息重试；
+// 标记已被抢占说明库存已经归还，不再重复执行
+func (s *DeadLetterService) rollbackOnce(ctx context.Context, d *dead_letter.DeadLetter, m *SeckillMessage) error {
+	ok, err := s.repo.UpdateRolledBack(ctx, d.ID, false, true)
+	if err != nil {
+		GetMonitor().RecordDBError()
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	if err := s.rollbackStock(ctx, m); err != nil {
+		_, _ = s.repo.UpdateRolledBack(ctx, d.ID, true, false)
+		return err
+	}
+	d.RolledBack = true
+	return nil
+}
+
+// rollbackStock 归还消息占用的库存。活动已结束并结算时，已准入未成交的数量保留在结算记录的 Pending 中，
+// 直接归还商品普通库存（结算后 Redis 中该活动的库存不会再被结算）；否则归还 Redis 库存与限购计数。
+func (s *DeadLetterService) rollbackStock(ctx context.Context, m *SeckillMessage) error {
+	if s.activities != nil {
+		ok, err := s.activities.ReturnPending(ctx, m.ActivityID, m.ProductID, 1)
+		if err != nil {
+			GetMonitor().RecordDBError()
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	err := s.redis.Do(seckillRollbackScript.Cmd(nil,
+		fmt.Sprintf(redisSeckillLimitKey, m.UserID, m.ProductID, m.ActivityID),
+		SeckillStockKey(m.ProductID, m.ActivityID),
+	))
+	if err != nil {
+		GetMonitor().RecordRedisError()
+		return err
+	}
+	s.events.NotifyStock(m.ProductID, m.ActivityID)
+	return nil
+}
+
+// List 查询死信，status 小于 0 时返回全部
+func (s *DeadLetterService) List(ctx context.Context, status, limit int) ([]*dead_letter.DeadLetter, error) {
+	return s.repo.List(ctx, status, limit)
+}
+
+// Redrive 重新投递一条待处理的死信：重新占用 Redis 库存与限购计数、重新冻结资金后写回秒杀队列，重试次数清零
+func (s *DeadLetterService) Redrive(ctx context.Context, id int64) error {
+	d, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	var m SeckillMessage
+	if err := json.Unmarshal([]byte(d.Body), &m); err != nil {
+		return fmt.Errorf("死信消息格式错误，无法重新投递: %v", err)
+	}
+	if !d.RolledBack {
+		return ErrDeadLetterNotRolledBack
+	}
+
+	// 先抢占状态，避免并发重复投递
+	ok, err := s.repo.UpdateStatus(ctx, id, dead_letter.StatusPending, dead_letter.StatusRedriven)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDeadLetterNotPending
+	}
+
+	limitKey := fmt.Sprintf(redisSeckillLimitKey, m.UserID, m.ProductID, m.ActivityID)
+	stockKey := SeckillStockKey(m.ProductID, m.ActivityID)
+	var code int
+	if err := s.redis.Do(seckillReserveScript.Cmd(&code, limitKey, stockKey, strconv.Itoa(seckillLimitExpireSeconds))); err != nil {
+		GetMonitor().RecordRedisError()
+		_, _ = s.repo.UpdateStatus(ctx, id, dead_letter.StatusRedriven, dead_letter.StatusPending)
+		return err
+	}
+	if code != admitOK {
+		_, _ = s.repo.UpdateStatus(ctx, id, dead_letter.StatusRedriven, dead_letter.StatusPending)
+		return ErrSeckillSoldOut
+	}
+	s.events.NotifyStock(m.ProductID, m.ActivityID)
+	// 库存已重新占用，消息再次进入死信时需要重新归还
+	if ok, err := s.repo.UpdateRolledBack(ctx, id, true, false); err != nil || !ok {
+		_ = s.redis.Do(seckillRollbackScript.Cmd(nil, limitKey, stockKey))
+		_, _ = s.repo.UpdateStatus(ctx, id, dead_letter.StatusRedriven, dead_letter.StatusPending)
+		s.events.NotifyStock(m.ProductID, m.ActivityID)
+		if err == nil {
+			err = ErrDeadLetterNotPending
+		}
+		return err
+	}
+
+	// 准入时冻结过资金的请求需要重新冻结（并重新占用原优惠券），余额不足或优惠券已不可用时不能重新投递
+	frozen := false
+	if s.accounts != nil && m.Frozen > 0 && m.RequestID != "" {
+		if _, err := s.accounts.FreezeForSeckill(ctx, m.UserID, m.Frozen, m.RequestID, nil, 0); err != nil {
+			_ = s.redis.Do(seckillRollbackScript.Cmd(nil, limitKey, stockKey))
+			_, _ = s.repo.UpdateRolledBack(ctx, id, false, true)
+			_, _ = s.repo.UpdateStatus(ctx, id, dead_letter.StatusRedriven, dead_letter.StatusPending)
+			s.events.NotifyStock(m.ProductID, m.ActivityID)
+			return err
+		}
+		frozen = true
+	}
+	// undo 撤销本次重新投递已占用的库存与冻结资金
+	undo := func(reason string) {
+		_ = s.redis.Do(seckillRollbackScript.Cmd(nil, limitKey, stockKey))
+		_, _ = s.repo.UpdateRolledBack(ctx, id, false, true)
+		if frozen {
+			_ = s.accounts.ReleaseFreeze(ctx, m.RequestID, reason)
+		}
+		_, _ = s.repo.UpdateStatus(ctx, id, dead_letter.StatusRedriven, dead_letter.StatusPending)
+		s.events.NotifyStock(m.ProductID, m.ActivityID)
+	}
+
+	// 清除进入死信时写下的已处理标记，否则 Worker 会把重新投递的消息当作重复消息忽略
+	if m.RequestID != "" {
+		_ = s.redis.Do(radix.Cmd(nil, "DEL", fmt.Sprintf(redisSeckillProcessedKey, m.RequestID)))
+	}
+
+	// 凭证恢复为排队中，客户端可继续凭原凭证查询
+	if s.tickets != nil && m.RequestID != "" {
+		if err := s.tickets.Requeue(ctx, m.RequestID); err != nil {
+			undo("重新投递失败: " + err.Error())
+			return err
+		}
+	}
+
+	if err := s.publisher.Publish(ctx, SeckillQueue, &mq.Message{Body: []byte(d.Body)}); err != nil {
+		GetMonitor().RecordMQError()
+		if s.tickets != nil && m.RequestID != "" {
+			_ = s.tickets.Fail(ctx, &m, d.Reason)
+		}
+		undo("重新投递失败: " + err.Error())
+		return err
+	}
+	return nil
+}
+
+// Discard 丢弃一条待处理的死信（库存已在进入死信时归还）
+func (s *DeadLetterService) Discard(ctx context.Context, id int64) error {
+	ok, err := s.repo.UpdateStatus(ctx, id, dead_letter.StatusPending, dead_letter.StatusDiscarded)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDeadLetterNotPending
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
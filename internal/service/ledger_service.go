@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/example/goseckill/internal/datamodels/account"
+	"github.com/example/goseckill/internal/datamodels/ledger"
+	"github.com/example/goseckill/internal/repository/mysql"
+)
+
+// bookTransaction 在同一事务中写入账户流水与对应的一借一贷两条分录，分录金额为 amount（正数）。
+// 所有改动余额或冻结金额的地方都必须通过这里记账，对账才能从分录还原出账户余额。
+func bookTransaction(tx *gorm.DB, t *account.Transaction, debit, credit string, amount int64) error {
+	if err := tx.Create(t).Error; err != nil {
+		return err
+	}
+	return postEntries(tx, newRequestID(), t.ID, debit, credit, amount, t.Type, t.Note)
+}
+
+// postEntries 写入一笔借贷分录
+func postEntries(tx *gorm.DB, journalID string, transactionID int64, debit, credit string, amount int64, typ, note string) error {
+	entries := []*ledger.LedgerEntry{
+		{JournalID: journalID, TransactionID: transactionID, Account: debit, Side: ledger.SideDebit, Amount: amount, Type: typ, Note: note},
+		{JournalID: journalID, TransactionID: transactionID, Account: credit, Side: ledger.SideCredit, Amount: amount, Type: typ, Note: note},
+	}
+	return tx.Create(&entries).Error
+}
+
+// LedgerMismatch 账户余额与分录还原结果不一致的一项
+type LedgerMismatch struct {
+	UserID  int64  `json:"user_id"`
+	Account string `json:"account"` // 分录账户，如 user:1:available
+	Stored  int64  `json:"stored"`  // 账户表中的金额
+	Ledger  int64  `json:"ledger"`  // 按分录还原的金额
+	Fixed   bool   `json:"fixed"`   // 是否已写入调整分录
+}
+
+// ReconcileReport 对账结果
+type ReconcileReport struct {
+	Accounts           int               `json:"accounts"`
+	TotalDebit         int64             `json:"total_debit"`
+	TotalCredit        int64             `json:"total_credit"`
+	UnbalancedJournals []string          `json:"unbalanced_journals"`
+	Mismatches         []*LedgerMismatch `json:"mismatches"`
+}
+
+// LedgerService 复式记账对账：按分录重新计算每个用户的可用余额与冻结金额，并与账户表比较
+type LedgerService struct {
+	db          *gorm.DB
+	accountRepo account.Repository
+	ledgerRepo  ledger.Repository
+}
+
+// NewLedgerService 创建对账服务
+func NewLedgerService(db *gorm.DB, ledgerRepo ledger.Repository) *LedgerService {
+	return &LedgerService{
+		db:          db,
+		accountRepo: mysql.NewAccountRepository(db),
+		ledgerRepo:  ledgerRepo,
+	}
+}
+
+// Reconcile 逐个账户对账。每个账户在其行锁内汇总分录，与记账时的加锁顺序一致，不受并发交易影响。
+// fix 为 true 时，对不一致的账户以账户表为准写入与 system:adjustment 对冲的调整分录
+// （首次上线时用于为已有余额补记期初分录）。
+func (s *LedgerService) Reconcile(ctx context.Context, fix bool) (*ReconcileReport, error) {
+	accounts, err := s.accountRepo.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &ReconcileReport{Accounts: len(accounts)}
+	for _, a := range accounts {
+		mismatches, err := s.reconcileAccount(ctx, a.UserID, fix)
+		if err != nil {
+			return nil, fmt.Errorf("reconcile user %d: %w", a.UserID, err)
+		}
+		report.Mismatches = append(report.Mismatches, mismatches...)
+	}
+
+	// 调整分录写入后再统计全局借贷平衡
+	if report.TotalDebit, report.TotalCredit, err = s.ledgerRepo.Totals(ctx); err != nil {
+		return nil, err
+	}
+	if report.UnbalancedJournals, err = s.ledgerRepo.UnbalancedJournals(ctx, 100); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (s *LedgerService) reconcileAccount(ctx context.Context, userID int64, fix bool) ([]*LedgerMismatch, error) {
+	var result []*LedgerMismatch
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		acc, err := lockAccount(tx, userID)
+		if err != nil {
+			return err
+		}
+		txCtx := mysql.WithTx(ctx, tx)
+		for _, item := range []struct {
+			account string
+			stored  int64
+		}{
+			{ledger.UserAvailable(userID), acc.Balance},
+			{ledger.UserFrozen(userID), acc.Frozen},
+		} {
+			debit, credit, err := s.ledgerRepo.SumAccount(txCtx, item.account)
+			if err != nil {
+				return err
+			}
+			balance := credit - debit
+			if balance == item.stored {
+				continue
+			}
+			m := &LedgerMismatch{UserID: userID, Account: item.account, Stored: item.stored, Ledger: balance}
+			result = append(result, m)
+			if !fix {
+				continue
+			}
+			diff := item.stored - balance
+			debitAcct, creditAcct := ledger.AccountAdjustment, item.account
+			if diff < 0 {
+				debitAcct, creditAcct, diff = item.account, ledger.AccountAdjustment, -diff
+			}
+			note := fmt.Sprintf("对账调整：账户 %d，分录 %d → %d", item.stored, balance, item.stored)
+			if err := postEntries(tx, newRequestID(), 0, debitAcct, creditAcct, diff, "adjustment", note); err != nil {
+				return err
+			}
+			m.Fixed = true
+		}
+		return nil
+	})
+	return result, err
+}
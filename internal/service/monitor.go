@@ -21,6 +21,9 @@ type Monitor struct {
 	SeckillSuccess   int64
 	WorkerProcessed  int64
 	WorkerFailed     int64
+	WorkerRetried    int64
+	WorkerDeadLettered int64
+	WorkerDuplicates int64
 
 	// 时间统计
 	LastRedisError   time.Time
@@ -99,6 +102,27 @@ func (m *Monitor) RecordWorkerFailed() {
 	m.WorkerErrors++
 }
 
+// RecordWorkerRetried 记录Worker延迟重试
+func (m *Monitor) RecordWorkerRetried() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.WorkerRetried++
+}
+
+// RecordWorkerDeadLettered 记录Worker将消息移入死信
+func (m *Monitor) RecordWorkerDeadLettered() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.WorkerDeadLettered++
+}
+
+// RecordWorkerDuplicate 记录Worker忽略的重复消息
+func (m *Monitor) RecordWorkerDuplicate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.WorkerDuplicates++
+}
+
 // GetStats 获取统计信息
 func (m *Monitor) GetStats() map[string]interface{} {
 	m.mu.RLock()
@@ -129,6 +153,9 @@ func (m *Monitor) GetStats() map[string]interface{} {
 			"seckill_success_rate": successRate,
 			"worker_processed":    m.WorkerProcessed,
 			"worker_failed":       m.WorkerFailed,
+			"worker_retried":      m.WorkerRetried,
+			"worker_dead_lettered": m.WorkerDeadLettered,
+			"worker_duplicates":   m.WorkerDuplicates,
 			"worker_success_rate": workerSuccessRate,
 		},
 		"last_events": map[string]interface{}{
@@ -154,4 +181,7 @@ func (m *Monitor) Reset() {
 	m.SeckillSuccess = 0
 	m.WorkerProcessed = 0
 	m.WorkerFailed = 0
+	m.WorkerRetried = 0
+	m.WorkerDeadLettered = 0
+	m.WorkerDuplicates = 0
 }
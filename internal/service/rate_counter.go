@@ -0,0 +1,53 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// rateCounter 按秒分桶的滑动窗口计数器，用于统计最近一段时间的吞吐
+type rateCounter struct {
+	mu      sync.Mutex
+	buckets []int64
+	seconds []int64 // 每个桶对应的 Unix 秒，用于识别过期桶
+}
+
+func newRateCounter(window int) *rateCounter {
+	return &rateCounter{
+		buckets: make([]int64, window),
+		seconds: make([]int64, window),
+	}
+}
+
+// Add 在当前秒的桶中累加
+func (r *rateCounter) Add(n int64) {
+	now := time.Now().Unix()
+	idx := int(now % int64(len(r.buckets)))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seconds[idx] != now {
+		r.seconds[idx] = now
+		r.buckets[idx] = 0
+	}
+	r.buckets[idx] += n
+}
+
+// Sum 窗口内的总数
+func (r *rateCounter) Sum() int64 {
+	now := time.Now().Unix()
+	window := int64(len(r.buckets))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var total int64
+	for i, sec := range r.seconds {
+		if now-sec < window {
+			total += r.buckets[i]
+		}
+	}
+	return total
+}
+
+// PerSecond 窗口内的平均每秒数量
+func (r *rateCounter) PerSecond() float64 {
+	return float64(r.Sum()) / float64(len(r.buckets))
+}
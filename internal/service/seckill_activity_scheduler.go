@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	radix "github.com/mediocregopher/radix/v3"
+
+	"github.com/example/goseckill/internal/config"
+)
+
+// redisSchedulerLeaderKey 活动调度主节点锁，值为持有者的实例ID
+const redisSchedulerLeaderKey = "seckill:scheduler:leader"
+
+// schedulerRenewScript 仅当锁仍由自己持有时续期
+//
+// KEYS[1] 锁键  ARGV[1] 实例ID  ARGV[2] 过期毫秒数
+var schedulerRenewScript = radix.NewEvalScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// schedulerReleaseScript 仅当锁仍由自己持有时释放
+//
+// KEYS[1] 锁键  ARGV[1] 实例ID
+var schedulerReleaseScript = radix.NewEvalScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// ActivityScheduler 秒杀活动调度器：在活动的开始/结束时间点精确地启动、结束活动。
+// 每个 web 实例都运行一个，通过 Redis 锁选出唯一的主节点执行，其余实例只参与竞选；
+// 主节点宕机后锁过期，由其他实例接管。
+type ActivityScheduler struct {
+	activitySvc *SeckillActivityService
+	seckillSvc  *SeckillService
+	redis       radix.Client
+	id          string
+	lockTTL     time.Duration
+	resync      time.Duration
+
+	leader int32
+}
+
+// NewActivityScheduler 创建活动调度器
+func NewActivityScheduler(
+	activitySvc *SeckillActivityService,
+	seckillSvc *SeckillService,
+	redis radix.Client,
+	cfg *config.SchedulerConfig,
+) *ActivityScheduler {
+	lockTTL := time.Duration(cfg.LockTTLSeconds) * time.Second
+	if lockTTL <= 0 {
+		lockTTL = 10 * time.Second
+	}
+	resync := time.Duration(cfg.ResyncSeconds) * time.Second
+	if resync <= 0 {
+		resync = 5 * time.Second
+	}
+	return &ActivityScheduler{
+		activitySvc: activitySvc,
+		seckillSvc:  seckillSvc,
+		redis:       redis,
+		id:          newRequestID(),
+		lockTTL:     lockTTL,
+		resync:      resync,
+	}
+}
+
+// Run 竞选主节点并调度活动，直到 ctx 取消；退出时主动释放锁以便其他实例立即接管
+func (s *ActivityScheduler) Run(ctx context.Context) error {
+	renew := s.lockTTL / 3
+	var next time.Time // 主节点下一次同步活动状态的时间
+	wasLeader := false
+	for {
+		leader := s.elect()
+		if leader != wasLeader {
+			if leader {
+				log.Printf("activity scheduler %s became leader", s.id)
+			} else {
+				log.Printf("activity scheduler %s lost leadership", s.id)
+			}
+		}
+		if leader && (!wasLeader || !time.Now().Before(next)) {
+			next = s.sync(ctx)
+		}
+		wasLeader = leader
+
+		wait := renew
+		if leader {
+			if d := time.Until(next); d < wait {
+				wait = d
+			}
+		}
+		if wait < 10*time.Millisecond {
+			wait = 10 * time.Millisecond
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.release()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// IsLeader 当前实例是否为调度主节点
+func (s *ActivityScheduler) IsLeader() bool {
+	return atomic.LoadInt32(&s.leader) == 1
+}
+
+// sync 推进所有活动状态，返回下一次需要同步的时间：最近的活动边界，最迟不超过 resync
+func (s *ActivityScheduler) sync(ctx context.Context) time.Time {
+	next := time.Now().Add(s.resync)
+	boundary, err := s.activitySvc.SyncActivityStates(ctx, s.seckillSvc)
+	if err != nil {
+		log.Printf("sync activity states failed: %v", err)
+		GetMonitor().RecordDBError()
+		return next
+	}
+	if !boundary.IsZero() && boundary.Before(next) {
+		next = boundary
+	}
+	return next
+}
+
+// elect 已是主节点时续期，否则尝试抢锁。Redis 不可用时视为非主节点，避免多个实例同时执行
+func (s *ActivityScheduler) elect() bool {
+	ttl := strconv.FormatInt(s.lockTTL.Milliseconds(), 10)
+	ok := false
+	if s.IsLeader() {
+		var renewed int
+		if err := s.redis.Do(schedulerRenewScript.Cmd(&renewed, redisSchedulerLeaderKey, s.id, ttl)); err != nil {
+			GetMonitor().RecordRedisError()
+		}
+		ok = renewed == 1
+	}
+	if !ok {
+		var reply string
+		if err := s.redis.Do(radix.Cmd(&reply, "SET", redisSchedulerLeaderKey, s.id, "NX", "PX", ttl)); err != nil {
+			GetMonitor().RecordRedisError()
+		}
+		ok = reply == "OK"
+	}
+	if ok {
+		atomic.StoreInt32(&s.leader, 1)
+	} else {
+		atomic.StoreInt32(&s.leader, 0)
+	}
+	return ok
+}
+
+func (s *ActivityScheduler) release() {
+	if !s.IsLeader() {
+		return
+	}
+	_ = s.redis.Do(schedulerReleaseScript.Cmd(nil, redisSchedulerLeaderKey, s.id))
+	atomic.StoreInt32(&s.leader, 0)
+}
@@ -2,6 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"time"
 
 	"github.com/example/goseckill/internal/datamodels/product"
@@ -15,22 +19,93 @@ import (
 //   - 根据时间窗口自动更新活动状态
 //   - 启动活动时同步商品状态与秒杀库存到 Redis
 //   - 为前台/后台提供活动查询能力
+//   - 活动状态变化时向相关商品页推送事件
+//   - 所有修改写入审计日志（后台操作记在管理员名下，调度器操作记为 system）
+
+// ErrActivityOverlap 商品已参与时间窗口重叠的其他活动。
+// 同一商品同一时刻最多属于一个未结束的活动，否则无法确定按哪个活动的折扣与限购秒杀。
+var ErrActivityOverlap = errors.New("商品已参与时间重叠的秒杀活动")
 
 type SeckillActivityService struct {
 	activityRepo seckill_activity.Repository
 	productRepo  product.Repository
+	events       *SeckillEventPublisher
+	audit        *AuditService
 }
 
-// NewSeckillActivityService 创建秒杀活动服务
-func NewSeckillActivityService(activityRepo seckill_activity.Repository, productRepo product.Repository) *SeckillActivityService {
+// NewSeckillActivityService 创建秒杀活动服务，events 可为 nil（不推送），audit 可为 nil（不记录审计日志）
+func NewSeckillActivityService(activityRepo seckill_activity.Repository, productRepo product.Repository, events *SeckillEventPublisher, audit *AuditService) *SeckillActivityService {
 	return &SeckillActivityService{
 		activityRepo: activityRepo,
 		productRepo:  productRepo,
+		events:       events,
+		audit:        audit,
+	}
+}
+
+// activitySnapshot 审计日志中记录的活动内容：活动本身及各商品的秒杀库存、秒杀价与限购
+type activitySnapshot struct {
+	Activity *seckill_activity.SeckillActivity          `json:"activity"`
+	Products []*seckill_activity.SeckillActivityProduct `json:"products"`
+}
+
+// snapshot 读取活动及其商品配置，读取失败时返回 nil（审计记录中不带该部分）
+func (s *SeckillActivityService) snapshot(ctx context.Context, id int64) *activitySnapshot {
+	activity, err := s.activityRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil
+	}
+	products, _ := s.activityRepo.GetProductsByActivity(ctx, id)
+	return &activitySnapshot{Activity: activity, Products: products}
+}
+
+// notifyActivity 向活动下所有商品的页面推送活动状态变化
+func (s *SeckillActivityService) notifyActivity(ctx context.Context, activity *seckill_activity.SeckillActivity) {
+	if s.events == nil {
+		return
+	}
+	products, err := s.activityRepo.GetProductsByActivity(ctx, activity.ID)
+	if err != nil {
+		return
+	}
+	ids := make([]int64, 0, len(products))
+	for _, ap := range products {
+		ids = append(ids, ap.ProductID)
+	}
+	s.events.PublishActivity(activity.ID, activity.Status, ids...)
+}
+
+// checkOverlap 校验 productIDs 在 [start, end) 内没有参与其他未结束的活动（已结束、已取消的活动不占用时间窗口）。
+// excludeID 为当前活动自身，新建活动时传 0。
+func (s *SeckillActivityService) checkOverlap(ctx context.Context, excludeID int64, productIDs []int64, start, end time.Time) error {
+	if !start.Before(end) {
+		return fmt.Errorf("活动开始时间必须早于结束时间")
 	}
+	for _, pid := range productIDs {
+		activities, err := s.activityRepo.GetActivitiesByProduct(ctx, pid)
+		if err != nil {
+			return err
+		}
+		for _, other := range activities {
+			if other.ID == excludeID ||
+				other.Status == seckill_activity.StatusEnded || other.Status == seckill_activity.StatusCancelled {
+				continue
+			}
+			if start.Before(other.EndTime) && other.StartTime.Before(end) {
+				return fmt.Errorf("%w：商品 %d 已在活动 %d「%s」（%s ~ %s）中", ErrActivityOverlap, pid, other.ID, other.Name,
+					other.StartTime.Format("2006-01-02 15:04:05"), other.EndTime.Format("2006-01-02 15:04:05"))
+			}
+		}
+	}
+	return nil
 }
 
-// CreateActivity 创建秒杀活动
+// CreateActivity 创建秒杀活动，商品与其他未结束活动的时间窗口重叠时返回 ErrActivityOverlap
 func (s *SeckillActivityService) CreateActivity(ctx context.Context, req *CreateActivityRequest) (*seckill_activity.SeckillActivity, error) {
+	if err := s.checkOverlap(ctx, 0, req.ProductIDs, req.StartTime, req.EndTime); err != nil {
+		return nil, err
+	}
+
 	activity := &seckill_activity.SeckillActivity{
 		Name:         req.Name,
 		Description:  req.Description,
@@ -38,7 +113,10 @@ func (s *SeckillActivityService) CreateActivity(ctx context.Context, req *Create
 		EndTime:      req.EndTime,
 		Discount:     req.Discount,
 		LimitPerUser: req.LimitPerUser,
-		Status:       0, // 默认未开始
+		Status:       seckill_activity.StatusScheduled, // 默认未开始
+	}
+	if req.Draft {
+		activity.Status = seckill_activity.StatusDraft
 	}
 
 	if err := s.activityRepo.Create(ctx, activity); err != nil {
@@ -66,22 +144,41 @@ func (s *SeckillActivityService) CreateActivity(ctx context.Context, req *Create
 			// 划拨失败跳过该商品，避免影响其它商品
 			continue
 		}
-		if err := s.activityRepo.AddProduct(ctx, activity.ID, productID, stock); err != nil {
+		if err := s.activityRepo.AddProduct(ctx, &seckill_activity.SeckillActivityProduct{
+			ActivityID:   activity.ID,
+			ProductID:    productID,
+			SeckillStock: stock,
+			SeckillPrice: req.ProductPrices[productID],
+			LimitPerUser: req.ProductLimits[productID],
+		}); err != nil {
 			// 单个商品失败不影响整体
 			continue
 		}
 	}
 
+	s.audit.Record(ctx, "activity.create", AuditEntityActivity, activity.ID, nil, s.snapshot(ctx, activity.ID))
 	return activity, nil
 }
 
-// UpdateActivity 更新活动基础信息（不包含商品列表）
+// UpdateActivity 更新活动基础信息（不包含商品列表），调整时间后与其他活动重叠时返回 ErrActivityOverlap
 func (s *SeckillActivityService) UpdateActivity(ctx context.Context, id int64, req *UpdateActivityRequest) error {
 	activity, err := s.activityRepo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
+	products, err := s.activityRepo.GetProductsByActivity(ctx, id)
+	if err != nil {
+		return err
+	}
+	productIDs := make([]int64, 0, len(products))
+	for _, ap := range products {
+		productIDs = append(productIDs, ap.ProductID)
+	}
+	if err := s.checkOverlap(ctx, id, productIDs, req.StartTime, req.EndTime); err != nil {
+		return err
+	}
 
+	before := *activity
 	activity.Name = req.Name
 	activity.Description = req.Description
 	activity.StartTime = req.StartTime
@@ -91,11 +188,31 @@ func (s *SeckillActivityService) UpdateActivity(ctx context.Context, id int64, r
 		activity.LimitPerUser = req.LimitPerUser
 	}
 
-	return s.activityRepo.Update(ctx, activity)
+	if err := s.activityRepo.Update(ctx, activity); err != nil {
+		return err
+	}
+	s.audit.Record(ctx, "activity.update", AuditEntityActivity, id, &before, activity)
+	s.notifyActivity(ctx, activity)
+	return nil
 }
 
-// UpdateActivityProducts 重新配置某个活动下的商品及其秒杀库存
-func (s *SeckillActivityService) UpdateActivityProducts(ctx context.Context, activityID int64, productIDs []int64, productStocks map[int64]int64) error {
+// UpdateActivityProducts 重新配置某个活动下的商品及其秒杀库存、秒杀价与限购，
+// 新商品与其他活动时间重叠时返回 ErrActivityOverlap 且不做任何修改
+func (s *SeckillActivityService) UpdateActivityProducts(ctx context.Context, activityID int64, req *UpdateActivityProductsRequest) error {
+	productIDs, productStocks := req.ProductIDs, req.ProductStocks
+	activity, err := s.activityRepo.GetByID(ctx, activityID)
+	if err != nil {
+		return err
+	}
+	// 只有未启动的活动可以调整商品：进行中/已暂停的活动库存已写入 Redis 并在售，
+	// 终态活动已结算，此时再划拨或归还库存都会与秒杀扣减、结算对不上
+	if activity.Status != seckill_activity.StatusDraft && activity.Status != seckill_activity.StatusScheduled {
+		return ErrActivityTransition
+	}
+	if err := s.checkOverlap(ctx, activityID, productIDs, activity.StartTime, activity.EndTime); err != nil {
+		return err
+	}
+
 	// 先读取当前关联关系
 	existing, err := s.activityRepo.GetProductsByActivity(ctx, activityID)
 	if err != nil {
@@ -158,11 +275,19 @@ func (s *SeckillActivityService) UpdateActivityProducts(ctx context.Context, act
 		if err := s.productRepo.Update(ctx, p); err != nil {
 			continue
 		}
-		if err := s.activityRepo.AddProduct(ctx, activityID, id, stock); err != nil {
+		if err := s.activityRepo.AddProduct(ctx, &seckill_activity.SeckillActivityProduct{
+			ActivityID:   activityID,
+			ProductID:    id,
+			SeckillStock: stock,
+			SeckillPrice: req.ProductPrices[id],
+			LimitPerUser: req.ProductLimits[id],
+		}); err != nil {
 			return err
 		}
 	}
 
+	after, _ := s.activityRepo.GetProductsByActivity(ctx, activityID)
+	s.audit.Record(ctx, "activity.products", AuditEntityActivity, activityID, existing, after)
 	return nil
 }
 
@@ -189,7 +314,9 @@ func (s *SeckillActivityService) GetActivity(ctx context.Context, id int64) (*Ac
 			ProductName:  p.Name,
 			ProductPrice: p.Price,
 			SeckillStock: ap.SeckillStock,
-			SeckillPrice: int64(float64(p.Price) * activity.Discount),
+			SeckillPrice: SeckillPriceOf(p.Price, activity, ap),
+			FixedPrice:   ap.SeckillPrice > 0,
+			LimitPerUser: SeckillLimitOf(activity, ap),
 		})
 	}
 
@@ -204,8 +331,50 @@ func (s *SeckillActivityService) ListActivities(ctx context.Context) ([]*seckill
 	return s.activityRepo.ListAll(ctx)
 }
 
+// SeckillPriceOf 商品在活动中的秒杀价（分）：设置了固定秒杀价时按固定价，
+// 否则按活动折扣四舍五入；折扣不合法时为原价。ap 可为 nil。
+func SeckillPriceOf(price int64, a *seckill_activity.SeckillActivity, ap *seckill_activity.SeckillActivityProduct) int64 {
+	if ap != nil && ap.SeckillPrice > 0 {
+		return ap.SeckillPrice
+	}
+	if a != nil && a.Discount > 0 && a.Discount <= 1 {
+		return int64(math.Round(float64(price) * a.Discount))
+	}
+	return price
+}
+
+// SeckillLimitOf 商品在活动中的每人限购：商品单独设置时优先，否则使用活动的限购，至少为 1。ap 可为 nil。
+func SeckillLimitOf(a *seckill_activity.SeckillActivity, ap *seckill_activity.SeckillActivityProduct) int64 {
+	if ap != nil && ap.LimitPerUser > 0 {
+		return ap.LimitPerUser
+	}
+	if a != nil && a.LimitPerUser > 0 {
+		return a.LimitPerUser
+	}
+	return 1
+}
+
+// SeckillTerms 查询商品在活动中的秒杀价（分）与每人限购，供前台展示
+func (s *SeckillActivityService) SeckillTerms(ctx context.Context, a *seckill_activity.SeckillActivity, p *product.Product) (price, limit int64) {
+	ap, err := s.activityRepo.GetActivityProduct(ctx, a.ID, p.ID)
+	if err != nil {
+		ap = nil
+	}
+	return SeckillPriceOf(p.Price, a, ap), SeckillLimitOf(a, ap)
+}
+
+// GetActivityProduct 查询商品在活动中的配置（秒杀库存、秒杀价与限购）
+func (s *SeckillActivityService) GetActivityProduct(ctx context.Context, activityID, productID int64) (*seckill_activity.SeckillActivityProduct, error) {
+	return s.activityRepo.GetActivityProduct(ctx, activityID, productID)
+}
+
+// GetActivityByID 根据ID获取活动
+func (s *SeckillActivityService) GetActivityByID(ctx context.Context, id int64) (*seckill_activity.SeckillActivity, error) {
+	return s.activityRepo.GetByID(ctx, id)
+}
+
 // GetActivityByProduct 根据商品ID获取该商品所属的活动信息
-// 若有多个活动，优先返回当前进行中的活动，其次返回最近的一个活动
+// 若有多个活动，优先返回当前进行中的活动，其次是时间窗口内已暂停的活动，最后返回最近的一个活动
 func (s *SeckillActivityService) GetActivityByProduct(ctx context.Context, productID int64) (*seckill_activity.SeckillActivity, error) {
 	activities, err := s.activityRepo.GetActivitiesByProduct(ctx, productID)
 	if err != nil {
@@ -216,139 +385,174 @@ func (s *SeckillActivityService) GetActivityByProduct(ctx context.Context, produ
 	}
 
 	now := time.Now()
+	var paused *seckill_activity.SeckillActivity
 	for _, act := range activities {
-		if act.Status == 1 && now.After(act.StartTime) && now.Before(act.EndTime) {
+		if !now.After(act.StartTime) || !now.Before(act.EndTime) {
+			continue
+		}
+		if act.Status == seckill_activity.StatusRunning {
 			return act, nil
 		}
+		if act.Status == seckill_activity.StatusPaused && paused == nil {
+			paused = act
+		}
+	}
+	if paused != nil {
+		return paused, nil
 	}
 	// 没有进行中的活动时，返回第一个记录（通常是最近的一个）
 	return activities[0], nil
 }
 
-// CheckAndUpdateExpiredActivities 检查并更新已过期的活动（恢复商品状态）
-func (s *SeckillActivityService) CheckAndUpdateExpiredActivities(ctx context.Context) error {
+// SyncActivityStates 按当前时间一次性推进所有活动的状态：
+// 已到开始时间的未开始活动启动，已到结束时间的活动结束并结算库存；
+// 草稿不处理，暂停的活动不会被自动恢复；已结束、已取消的活动中结算失败的商品会被重新结算。
+// 返回下一个最近的开始/结束时间点（没有则为零值），供调度器精确定时。
+func (s *SeckillActivityService) SyncActivityStates(ctx context.Context, seckillSvc *SeckillService) (time.Time, error) {
 	activities, err := s.activityRepo.ListAll(ctx)
 	if err != nil {
-		return err
+		return time.Time{}, err
+	}
+	if err := s.resettleClosedActivities(ctx, seckillSvc); err != nil {
+		log.Printf("resettle closed activities failed: %v", err)
 	}
 
 	now := time.Now()
+	var next time.Time
 	for _, activity := range activities {
-		// 结束时间已过且当前状态不是“已结束”时，更新状态并恢复商品
-		if (now.After(activity.EndTime) || now.Equal(activity.EndTime)) && activity.Status != 2 {
-			oldStatus := activity.Status
-			activity.Status = 2
-			if err := s.activityRepo.Update(ctx, activity); err != nil {
-				// 记录错误但不影响其他活动
-				_ = oldStatus
-				continue
-			}
-
-			products, err := s.activityRepo.GetProductsByActivity(ctx, activity.ID)
-			if err != nil {
-				continue
+		if !canTransition(activity.Status, seckill_activity.StatusEnded) {
+			continue
+		}
+		if !now.Before(activity.EndTime) {
+			if err := s.closeActivity(ctx, activity, seckill_activity.StatusEnded, seckillSvc); err != nil {
+				log.Printf("end activity %d failed: %v", activity.ID, err)
 			}
-			for _, ap := range products {
-				p, err := s.productRepo.GetByID(ctx, ap.ProductID)
-				if err != nil {
-					continue
-				}
-				if p.Status == 2 {
-					p.Status = 1
-					p.SeckillStock = 0
-					if err := s.productRepo.Update(ctx, p); err != nil {
-						continue
-					}
+			continue
+		}
+		boundary := activity.EndTime
+		if activity.Status == seckill_activity.StatusScheduled {
+			if now.After(activity.StartTime) {
+				if err := s.runActivity(ctx, activity, seckillSvc); err != nil {
+					log.Printf("start activity %d failed: %v", activity.ID, err)
 				}
+			} else {
+				boundary = activity.StartTime
 			}
 		}
+		if next.IsZero() || boundary.Before(next) {
+			next = boundary
+		}
 	}
-	return nil
+	return next, nil
 }
 
-// CheckAndActivateStartedActivities 自动启动已到开始时间但尚未标记为进行中的活动
-// 当当前时间位于 [StartTime, EndTime) 且活动状态不是 1 时，会调用 StartActivity
-// 来同步活动状态、商品状态以及 Redis 中的秒杀库存。
-func (s *SeckillActivityService) CheckAndActivateStartedActivities(ctx context.Context, seckillSvc *SeckillService) error {
-	activities, err := s.activityRepo.ListAll(ctx)
+// settleProduct 结算单个活动商品：
+//   - 已成交 = 划拨量 - MySQL 秒杀库存（下单事务中逐单扣减）
+//   - 未售出 = 原子取走的 Redis 剩余库存，且不超过 MySQL 秒杀库存
+//   - 两者之差为已准入但仍在队列中的请求，保留在秒杀库存中让其正常完成
+//
+// 未售出部分在同一事务中归还普通库存并写入结算记录。
+func (s *SeckillActivityService) settleProduct(ctx context.Context, activity *seckill_activity.SeckillActivity, ap *seckill_activity.SeckillActivityProduct, seckillSvc *SeckillService) error {
+	p, err := s.productRepo.GetByID(ctx, ap.ProductID)
 	if err != nil {
 		return err
 	}
 
-	now := time.Now()
-	for _, activity := range activities {
-		if now.After(activity.StartTime) && now.Before(activity.EndTime) && activity.Status != 1 {
-			if err := s.StartActivity(ctx, activity.ID, seckillSvc); err != nil {
-				// 单个活动失败不影响其他活动
-				continue
-			}
+	redisRemaining := int64(-1)
+	var taken int64
+	if seckillSvc != nil {
+		remaining, ok, err := seckillSvc.TakeRemainingStock(ctx, ap.ProductID, activity.ID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			redisRemaining, taken = remaining, remaining
 		}
 	}
-	return nil
-}
 
-// DeleteActivity 删除活动
-func (s *SeckillActivityService) DeleteActivity(ctx context.Context, id int64) error {
-	// 归还所有已划拨的秒杀库存
-	products, _ := s.activityRepo.GetProductsByActivity(ctx, id)
-	for _, ap := range products {
-		if p, err := s.productRepo.GetByID(ctx, ap.ProductID); err == nil {
-			p.Stock += ap.SeckillStock
-			_ = s.productRepo.Update(ctx, p)
+	returned := p.SeckillStock
+	if redisRemaining >= 0 && redisRemaining < returned {
+		returned = redisRemaining
+	}
+	if returned < 0 {
+		returned = 0
+	}
+	sold := ap.SeckillStock - p.SeckillStock
+	if sold < 0 {
+		sold = 0
+	}
+
+	st := &seckill_activity.SeckillSettlement{
+		ActivityID:     activity.ID,
+		ProductID:      ap.ProductID,
+		Allocated:      ap.SeckillStock,
+		Sold:           sold,
+		RedisRemaining: redisRemaining,
+		Returned:       returned,
+		Pending:        p.SeckillStock - returned,
+	}
+	ok, err := s.activityRepo.Settle(ctx, st)
+	if err != nil || !ok {
+		// 结算失败或已被其他实例结算，把取走的 Redis 库存还原
+		if seckillSvc != nil && taken > 0 {
+			_ = seckillSvc.RestoreStock(ctx, ap.ProductID, activity.ID, taken)
 		}
+		return err
 	}
-	return s.activityRepo.Delete(ctx, id)
+	log.Printf("activity %d product %d settled: allocated=%d sold=%d returned=%d pending=%d",
+		activity.ID, ap.ProductID, st.Allocated, st.Sold, st.Returned, st.Pending)
+	return nil
+}
+
+// ListSettlements 查询活动的库存结算记录
+func (s *SeckillActivityService) ListSettlements(ctx context.Context, activityID int64) ([]*seckill_activity.SeckillSettlement, error) {
+	return s.activityRepo.ListSettlements(ctx, activityID)
 }
 
-// StartActivity 启动活动（更新商品状态并同步库存到Redis）
-// 一般由后台“启动”按钮或 CheckAndActivateStartedActivities 调用
-func (s *SeckillActivityService) StartActivity(ctx context.Context, id int64, seckillSvc *SeckillService) error {
+// DeleteActivity 删除活动：
+//   - 草稿/未开始的活动从未启动，划拨的秒杀库存在删除的同一事务中原样归还普通库存
+//   - 已结束/已取消的活动在结算时已归还未售出的库存，全部商品结算完成后才能删除，不再归还
+//   - 进行中/已暂停的活动需先结束或取消
+func (s *SeckillActivityService) DeleteActivity(ctx context.Context, id int64) error {
 	activity, err := s.activityRepo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
-
-	now := time.Now()
-	if now.Before(activity.StartTime) {
-		activity.Status = 0 // 未开始
-	} else if now.After(activity.EndTime) {
-		activity.Status = 2 // 已结束
-	} else {
-		activity.Status = 1 // 进行中
-	}
-
-	if err := s.activityRepo.Update(ctx, activity); err != nil {
+	products, err := s.activityRepo.GetProductsByActivity(ctx, id)
+	if err != nil {
 		return err
 	}
 
-	// 活动处于进行中时，同步商品状态与库存
-	if activity.Status == 1 {
-		products, err := s.activityRepo.GetProductsByActivity(ctx, id)
+	var returnStock bool
+	switch activity.Status {
+	case seckill_activity.StatusDraft, seckill_activity.StatusScheduled:
+		returnStock = true
+	case seckill_activity.StatusEnded, seckill_activity.StatusCancelled:
+		unsettled, err := s.unsettledProducts(ctx, activity.ID, products)
 		if err != nil {
 			return err
 		}
-
-		for _, ap := range products {
-			p, err := s.productRepo.GetByID(ctx, ap.ProductID)
-			if err != nil {
-				continue
-			}
-
-			p.Status = 2
-			p.StartTime = activity.StartTime
-			p.EndTime = activity.EndTime
-			p.SeckillStock = ap.SeckillStock
-			if err := s.productRepo.Update(ctx, p); err != nil {
-				continue
-			}
-
-			// 同步库存到 Redis
-			if err := seckillSvc.InitProductStock(ctx, p); err != nil {
-				continue
-			}
+		if len(unsettled) > 0 {
+			return fmt.Errorf("%w：仍有 %d 个商品未完成库存结算", ErrActivityTransition, len(unsettled))
 		}
+	default:
+		return ErrActivityTransition
 	}
 
+	before := s.snapshot(ctx, id)
+	ok, err := s.activityRepo.Delete(ctx, id, activity.Status, returnStock)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrActivityTransition
+	}
+	ids := make([]int64, 0, len(products))
+	for _, ap := range products {
+		ids = append(ids, ap.ProductID)
+	}
+	s.audit.Record(ctx, "activity.delete", AuditEntityActivity, id, before, nil)
+	s.events.PublishActivity(id, seckill_activity.StatusEnded, ids...)
 	return nil
 }
 
@@ -363,6 +567,16 @@ type CreateActivityRequest struct {
 	LimitPerUser  int64
 	ProductIDs    []int64
 	ProductStocks map[int64]int64 // 商品ID -> 秒杀库存
+	ProductPrices map[int64]int64 // 商品ID -> 固定秒杀价（分），未设置按活动折扣
+	ProductLimits map[int64]int64 // 商品ID -> 每人限购，未设置使用活动的限购
+	Draft         bool            // 以草稿创建，发布后才会被调度器启动
+}
+
+type UpdateActivityProductsRequest struct {
+	ProductIDs    []int64
+	ProductStocks map[int64]int64 // 商品ID -> 秒杀库存
+	ProductPrices map[int64]int64 // 商品ID -> 固定秒杀价（分），未设置按活动折扣
+	ProductLimits map[int64]int64 // 商品ID -> 每人限购，未设置使用活动的限购
 }
 
 type UpdateActivityRequest struct {
@@ -388,5 +602,7 @@ type ProductInActivity struct {
 	ProductName  string
 	ProductPrice int64
 	SeckillStock int64
-	SeckillPrice int64 // 秒杀价 = 原价 * 折扣
+	SeckillPrice int64 // 秒杀价：固定秒杀价，未设置时为 原价 * 折扣
+	FixedPrice   bool  // 是否为单独设置的固定秒杀价
+	LimitPerUser int64 // 每人限购
 }
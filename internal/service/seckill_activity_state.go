@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/example/goseckill/internal/datamodels/seckill_activity"
+)
+
+// ErrActivityTransition 活动当前状态不允许执行该操作（非法状态迁移或状态已被并发修改）
+var ErrActivityTransition = errors.New("活动当前状态不允许该操作")
+
+// ActivityStatusName 活动状态的英文名，供接口与前端展示
+var ActivityStatusName = map[int]string{
+	seckill_activity.StatusDraft:     "draft",
+	seckill_activity.StatusScheduled: "scheduled",
+	seckill_activity.StatusRunning:   "running",
+	seckill_activity.StatusPaused:    "paused",
+	seckill_activity.StatusEnded:     "ended",
+	seckill_activity.StatusCancelled: "cancelled",
+}
+
+// activityTransitions 合法的状态迁移，已结束与已取消为终态
+//
+//	草稿   -> 未开始（发布）/ 已取消
+//	未开始 -> 草稿（撤回）/ 进行中 / 已结束 / 已取消
+//	进行中 -> 已暂停 / 已结束 / 已取消
+//	已暂停 -> 进行中（恢复）/ 已结束 / 已取消
+var activityTransitions = map[int][]int{
+	seckill_activity.StatusDraft: {
+		seckill_activity.StatusScheduled, seckill_activity.StatusCancelled,
+	},
+	seckill_activity.StatusScheduled: {
+		seckill_activity.StatusDraft, seckill_activity.StatusRunning,
+		seckill_activity.StatusEnded, seckill_activity.StatusCancelled,
+	},
+	seckill_activity.StatusRunning: {
+		seckill_activity.StatusPaused, seckill_activity.StatusEnded, seckill_activity.StatusCancelled,
+	},
+	seckill_activity.StatusPaused: {
+		seckill_activity.StatusRunning, seckill_activity.StatusEnded, seckill_activity.StatusCancelled,
+	},
+}
+
+// canTransition 判断 from -> to 是否为合法迁移
+func canTransition(from, to int) bool {
+	for _, s := range activityTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transition 校验并以 CAS 方式迁移活动状态：只有数据库中的状态仍为 a.Status 时才会更新，
+// 避免后台操作与调度器并发时互相覆盖。成功后 a.Status 更新为 to，并写入审计日志。
+func (s *SeckillActivityService) transition(ctx context.Context, a *seckill_activity.SeckillActivity, to int) error {
+	if !canTransition(a.Status, to) {
+		return ErrActivityTransition
+	}
+	ok, err := s.activityRepo.UpdateStatus(ctx, a.ID, a.Status, to)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrActivityTransition
+	}
+	s.transitioned(ctx, a, to)
+	return nil
+}
+
+// transitioned 状态迁移成功后写入审计日志并更新 a.Status
+func (s *SeckillActivityService) transitioned(ctx context.Context, a *seckill_activity.SeckillActivity, to int) {
+	s.audit.Record(ctx, "activity.status", AuditEntityActivity, a.ID,
+		map[string]string{"status": ActivityStatusName[a.Status]}, map[string]string{"status": ActivityStatusName[to]})
+	a.Status = to
+}
+
+// PublishActivity 发布草稿活动，之后到开始时间由调度器自动启动
+func (s *SeckillActivityService) PublishActivity(ctx context.Context, id int64) error {
+	activity, err := s.activityRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if activity.Status != seckill_activity.StatusDraft {
+		return ErrActivityTransition
+	}
+	if err := s.transition(ctx, activity, seckill_activity.StatusScheduled); err != nil {
+		return err
+	}
+	s.notifyActivity(ctx, activity)
+	return nil
+}
+
+// UnpublishActivity 将未开始的活动撤回为草稿
+func (s *SeckillActivityService) UnpublishActivity(ctx context.Context, id int64) error {
+	activity, err := s.activityRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.transition(ctx, activity, seckill_activity.StatusDraft); err != nil {
+		return err
+	}
+	s.notifyActivity(ctx, activity)
+	return nil
+}
+
+// StartActivity 启动活动（更新商品状态并同步库存到Redis）
+// 一般由后台“启动”按钮或调度器调用。草稿或未开始的活动按当前时间进入对应状态：
+// 未到开始时间保持/进入未开始，已过结束时间直接结束，否则进入进行中；
+// 已在进行中时不做任何事，避免重置 Redis 中已扣减的库存；暂停的活动需通过 ResumeActivity 恢复。
+func (s *SeckillActivityService) StartActivity(ctx context.Context, id int64, seckillSvc *SeckillService) error {
+	activity, err := s.activityRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	switch activity.Status {
+	case seckill_activity.StatusRunning:
+		return nil
+	case seckill_activity.StatusDraft, seckill_activity.StatusScheduled:
+	default:
+		return ErrActivityTransition
+	}
+
+	now := time.Now()
+	if now.Before(activity.StartTime) {
+		if activity.Status == seckill_activity.StatusScheduled {
+			return nil
+		}
+		if err := s.transition(ctx, activity, seckill_activity.StatusScheduled); err != nil {
+			return err
+		}
+		s.notifyActivity(ctx, activity)
+		return nil
+	}
+	if !now.Before(activity.EndTime) {
+		if activity.Status == seckill_activity.StatusDraft {
+			// 草稿不能直接结束，先发布
+			if err := s.transition(ctx, activity, seckill_activity.StatusScheduled); err != nil {
+				return err
+			}
+		}
+		return s.closeActivity(ctx, activity, seckill_activity.StatusEnded, seckillSvc)
+	}
+	if activity.Status == seckill_activity.StatusDraft {
+		if err := s.transition(ctx, activity, seckill_activity.StatusScheduled); err != nil {
+			return err
+		}
+	}
+	return s.runActivity(ctx, activity, seckillSvc)
+}
+
+// runActivity 将未开始的活动置为进行中，并同步商品状态与秒杀库存
+func (s *SeckillActivityService) runActivity(ctx context.Context, activity *seckill_activity.SeckillActivity, seckillSvc *SeckillService) error {
+	if err := s.transition(ctx, activity, seckill_activity.StatusRunning); err != nil {
+		return err
+	}
+	defer s.notifyActivity(ctx, activity)
+
+	products, err := s.activityRepo.GetProductsByActivity(ctx, activity.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, ap := range products {
+		p, err := s.productRepo.GetByID(ctx, ap.ProductID)
+		if err != nil {
+			continue
+		}
+
+		p.Status = 2
+		p.StartTime = activity.StartTime
+		p.EndTime = activity.EndTime
+		p.SeckillStock = ap.SeckillStock
+		if err := s.productRepo.Update(ctx, p); err != nil {
+			continue
+		}
+
+		// 同步库存到 Redis
+		if seckillSvc != nil {
+			if err := seckillSvc.InitProductStock(ctx, p, activity.ID); err != nil {
+				continue
+			}
+		}
+	}
+	return nil
+}
+
+// PauseActivity 暂停进行中的活动。暂停后 Seckill 立即拒绝新请求，
+// 已准入的请求照常由 Worker 完成；Redis 中的库存保留，恢复后继续售卖。
+func (s *SeckillActivityService) PauseActivity(ctx context.Context, id int64) error {
+	activity, err := s.activityRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.transition(ctx, activity, seckill_activity.StatusPaused); err != nil {
+		return err
+	}
+	s.notifyActivity(ctx, activity)
+	return nil
+}
+
+// ResumeActivity 恢复暂停的活动；若暂停期间已过结束时间，则直接结束并结算
+func (s *SeckillActivityService) ResumeActivity(ctx context.Context, id int64, seckillSvc *SeckillService) error {
+	activity, err := s.activityRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if activity.Status != seckill_activity.StatusPaused {
+		return ErrActivityTransition
+	}
+	if !time.Now().Before(activity.EndTime) {
+		return s.closeActivity(ctx, activity, seckill_activity.StatusEnded, seckillSvc)
+	}
+	if err := s.transition(ctx, activity, seckill_activity.StatusRunning); err != nil {
+		return err
+	}
+	s.notifyActivity(ctx, activity)
+	return nil
+}
+
+// EndActivity 提前结束活动，并结算未售出的秒杀库存
+func (s *SeckillActivityService) EndActivity(ctx context.Context, id int64, seckillSvc *SeckillService) error {
+	activity, err := s.activityRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.closeActivity(ctx, activity, seckill_activity.StatusEnded, seckillSvc)
+}
+
+// CancelActivity 取消活动：归还未售出的秒杀库存，并清理该活动在 Redis 中的库存、限购与 path 键
+func (s *SeckillActivityService) CancelActivity(ctx context.Context, id int64, seckillSvc *SeckillService) error {
+	activity, err := s.activityRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.closeActivity(ctx, activity, seckill_activity.StatusCancelled, seckillSvc)
+}
+
+// closeActivity 将活动迁移到终态（已结束/已取消），并结算活动商品的剩余秒杀库存。
+// 先迁移状态再结算，保证结算时不会再有新的请求准入；结算失败的商品由调度器的补偿结算重试。
+func (s *SeckillActivityService) closeActivity(ctx context.Context, activity *seckill_activity.SeckillActivity, to int, seckillSvc *SeckillService) error {
+	// 草稿/未开始的活动从未同步过商品与 Redis，划拨量原样归还即可
+	if activity.Status != seckill_activity.StatusRunning && activity.Status != seckill_activity.StatusPaused {
+		return s.closeUnstarted(ctx, activity, to)
+	}
+	if err := s.transition(ctx, activity, to); err != nil {
+		return err
+	}
+	s.notifyActivity(ctx, activity)
+	return s.settleActivity(ctx, activity, seckillSvc)
+}
+
+// closeUnstarted 关闭从未启动的活动：状态迁移与全部商品的结算（划拨量归还普通库存）在同一事务中完成，
+// 因此终态活动中没有结算记录的商品一定来自已启动的活动，可以由补偿结算按已启动的方式处理
+func (s *SeckillActivityService) closeUnstarted(ctx context.Context, activity *seckill_activity.SeckillActivity, to int) error {
+	if !canTransition(activity.Status, to) {
+		return ErrActivityTransition
+	}
+	products, err := s.activityRepo.GetProductsByActivity(ctx, activity.ID)
+	if err != nil {
+		return err
+	}
+	settlements := make([]*seckill_activity.SeckillSettlement, 0, len(products))
+	for _, ap := range products {
+		settlements = append(settlements, &seckill_activity.SeckillSettlement{
+			ActivityID:     activity.ID,
+			ProductID:      ap.ProductID,
+			Allocated:      ap.SeckillStock,
+			RedisRemaining: -1,
+			Returned:       ap.SeckillStock,
+			Unstarted:      true,
+		})
+	}
+	ok, err := s.activityRepo.CloseUnstarted(ctx, activity.ID, activity.Status, to, settlements)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrActivityTransition
+	}
+	s.transitioned(ctx, activity, to)
+	log.Printf("activity %d closed before start: %d products returned", activity.ID, len(settlements))
+	s.notifyActivity(ctx, activity)
+	return nil
+}
+
+// settleActivity 结算已启动并进入终态的活动中尚未结算的商品，已取消的活动同时清理 Redis 中的库存、限购与 path 键。
+// 单个商品失败只记录日志，不影响其他商品。
+func (s *SeckillActivityService) settleActivity(ctx context.Context, activity *seckill_activity.SeckillActivity, seckillSvc *SeckillService) error {
+	products, err := s.activityRepo.GetProductsByActivity(ctx, activity.ID)
+	if err != nil {
+		return err
+	}
+	unsettled, err := s.unsettledProducts(ctx, activity.ID, products)
+	if err != nil {
+		return err
+	}
+	for _, ap := range unsettled {
+		if err := s.settleProduct(ctx, activity, ap, seckillSvc); err != nil {
+			log.Printf("settle activity %d product %d failed: %v", activity.ID, ap.ProductID, err)
+			continue
+		}
+		if activity.Status == seckill_activity.StatusCancelled && seckillSvc != nil {
+			if err := seckillSvc.ClearActivityKeys(ctx, ap.ProductID, activity.ID); err != nil {
+				log.Printf("clear redis keys of activity %d product %d failed: %v", activity.ID, ap.ProductID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// resettleGrace 活动进入终态后等待多久才由补偿结算接手，避免与仍在进行的 closeActivity 并发结算同一商品
+const resettleGrace = time.Minute
+
+// resettleClosedActivities 补偿结算：已结束/已取消的活动中仍有商品没有结算记录（例如结算时 Redis 不可用），
+// 重新结算这些商品，把未售出的库存归还普通库存
+func (s *SeckillActivityService) resettleClosedActivities(ctx context.Context, seckillSvc *SeckillService) error {
+	products, err := s.activityRepo.ListUnsettledProducts(ctx, time.Now().Add(-resettleGrace))
+	if err != nil {
+		return err
+	}
+	seen := make(map[int64]bool)
+	for _, ap := range products {
+		if seen[ap.ActivityID] {
+			continue
+		}
+		seen[ap.ActivityID] = true
+		activity, err := s.activityRepo.GetByID(ctx, ap.ActivityID)
+		if err != nil {
+			log.Printf("resettle activity %d failed: %v", ap.ActivityID, err)
+			continue
+		}
+		log.Printf("resettling activity %d", activity.ID)
+		if err := s.settleActivity(ctx, activity, seckillSvc); err != nil {
+			log.Printf("resettle activity %d failed: %v", activity.ID, err)
+		}
+	}
+	return nil
+}
+
+// unsettledProducts 返回 products 中还没有结算记录的商品
+func (s *SeckillActivityService) unsettledProducts(ctx context.Context, activityID int64, products []*seckill_activity.SeckillActivityProduct) ([]*seckill_activity.SeckillActivityProduct, error) {
+	settled, err := s.activityRepo.ListSettlements(ctx, activityID)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int64]bool, len(settled))
+	for _, st := range settled {
+		done[st.ProductID] = true
+	}
+	var list []*seckill_activity.SeckillActivityProduct
+	for _, ap := range products {
+		if !done[ap.ProductID] {
+			list = append(list, ap)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	radix "github.com/mediocregopher/radix/v3"
+
+	"github.com/example/goseckill/internal/config"
+)
+
+const (
+	redisEventUserChannel    = "seckill:events:user:%d"    // userID（个人秒杀结果）
+	redisEventProductChannel = "seckill:events:product:%d" // productID（库存与活动状态）
+	redisEventPattern        = "seckill:events:*"
+)
+
+// 推送事件类型
+const (
+	EventSeckillResult = "seckill_result" // 秒杀最终结果
+	EventStock         = "stock"          // 秒杀库存变化
+	EventActivity      = "activity"       // 活动状态变化
+)
+
+// SeckillEvent 通过 Redis pub/sub 广播、再经 SSE 推送给客户端的事件
+type SeckillEvent struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// UserEventChannel 用户个人事件频道
+func UserEventChannel(userID int64) string {
+	return fmt.Sprintf(redisEventUserChannel, userID)
+}
+
+// ProductEventChannel 商品事件频道
+func ProductEventChannel(productID int64) string {
+	return fmt.Sprintf(redisEventProductChannel, productID)
+}
+
+// SeckillEventPublisher 将秒杀结果、库存与活动变化发布到 Redis 频道。
+// 所有 web 实例的 SeckillEventHub 都会收到并推送给各自的连接。
+// 发布失败只记录日志，不影响业务流程；nil 接收者上的调用直接忽略。
+type SeckillEventPublisher struct {
+	redis    radix.Client
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[stockRef]bool // 已安排推送、尚未执行的活动商品库存
+}
+
+// stockRef 某个活动中的一个商品
+type stockRef struct {
+	productID  int64
+	activityID int64
+}
+
+// NewSeckillEventPublisher 创建事件发布者
+func NewSeckillEventPublisher(redis radix.Client, cfg *config.PushConfig) *SeckillEventPublisher {
+	interval := time.Duration(cfg.StockIntervalMillis) * time.Millisecond
+	if interval <= 0 {
+		interval = 200 * time.Millisecond
+	}
+	return &SeckillEventPublisher{
+		redis:    redis,
+		interval: interval,
+		pending:  make(map[stockRef]bool),
+	}
+}
+
+// PublishResult 向用户推送秒杀最终结果
+func (p *SeckillEventPublisher) PublishResult(userID, productID int64, ticket string, status int, orderID int64, reason string) {
+	if p == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"ticket":     ticket,
+		"product_id": productID,
+		"status":     SeckillTicketStatusName(status),
+	}
+	if orderID > 0 {
+		data["order_id"] = orderID
+	}
+	if reason != "" {
+		data["reason"] = reason
+	}
+	p.publish(UserEventChannel(userID), EventSeckillResult, data)
+}
+
+// NotifyStock 标记商品在某个活动中的秒杀库存已变化。
+// 同一活动商品在 interval 内的多次变化合并为一次推送，推送时读取 Redis 中的最新库存，
+// 避免秒杀高峰每次准入都广播一次。
+func (p *SeckillEventPublisher) NotifyStock(productID, activityID int64) {
+	if p == nil {
+		return
+	}
+	ref := stockRef{productID: productID, activityID: activityID}
+	p.mu.Lock()
+	if p.pending[ref] {
+		p.mu.Unlock()
+		return
+	}
+	p.pending[ref] = true
+	p.mu.Unlock()
+
+	time.AfterFunc(p.interval, func() {
+		p.mu.Lock()
+		delete(p.pending, ref)
+		p.mu.Unlock()
+
+		var stockStr string
+		if err := p.redis.Do(radix.Cmd(&stockStr, "GET", SeckillStockKey(productID, activityID))); err != nil {
+			GetMonitor().RecordRedisError()
+			return
+		}
+		stock, _ := strconv.ParseInt(stockStr, 10, 64)
+		p.publish(ProductEventChannel(productID), EventStock, map[string]interface{}{
+			"product_id":  productID,
+			"activity_id": activityID,
+			"stock":       stock,
+		})
+	})
+}
+
+// PublishActivity 通知商品所属活动的状态已变化，客户端据此刷新价格与按钮
+func (p *SeckillEventPublisher) PublishActivity(activityID int64, status int, productIDs ...int64) {
+	if p == nil {
+		return
+	}
+	for _, pid := range productIDs {
+		p.publish(ProductEventChannel(pid), EventActivity, map[string]interface{}{
+			"product_id":  pid,
+			"activity_id": activityID,
+			"status":      status,
+		})
+	}
+}
+
+func (p *SeckillEventPublisher) publish(channel, typ string, data interface{}) {
+	body, err := json.Marshal(&SeckillEvent{Type: typ, Data: data})
+	if err != nil {
+		return
+	}
+	if err := p.redis.Do(radix.Cmd(nil, "PUBLISH", channel, string(body))); err != nil {
+		log.Printf("failed to publish %s event to %s: %v", typ, channel, err)
+		GetMonitor().RecordRedisError()
+	}
+}
+
+// SeckillEventHub 每个 web 实例一个：以单个模式订阅接收所有事件，
+// 再按频道分发给本实例上的 SSE 连接。
+type SeckillEventHub struct {
+	ps     radix.PubSubConn
+	buffer int
+
+	mu   sync.RWMutex
+	subs map[string]map[chan []byte]struct{}
+}
+
+// NewSeckillEventHub 创建事件分发中心，需调用 Run 开始接收
+func NewSeckillEventHub(ps radix.PubSubConn, cfg *config.PushConfig) *SeckillEventHub {
+	buffer := cfg.ClientBuffer
+	if buffer <= 0 {
+		buffer = 32
+	}
+	return &SeckillEventHub{
+		ps:     ps,
+		buffer: buffer,
+		subs:   make(map[string]map[chan []byte]struct{}),
+	}
+}
+
+// Run 订阅所有秒杀事件频道并分发，直到 ctx 取消
+func (h *SeckillEventHub) Run(ctx context.Context) error {
+	msgCh := make(chan radix.PubSubMessage, 256)
+	if err := h.ps.PSubscribe(msgCh, redisEventPattern); err != nil {
+		return err
+	}
+	defer func() {
+		_ = h.ps.PUnsubscribe(msgCh, redisEventPattern)
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m := <-msgCh:
+			h.dispatch(m.Channel, m.Message)
+		}
+	}
+}
+
+// dispatch 非阻塞地投递给订阅了该频道的连接，慢连接的缓冲写满时丢弃事件，
+// 不能拖慢 Redis 订阅连接（客户端可通过查询接口补齐）
+func (h *SeckillEventHub) dispatch(channel string, payload []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subs[channel] {
+		select {
+		case ch <- payload:
+		default:
+		}
+	}
+}
+
+// Subscribe 订阅若干频道，返回事件通道与取消函数。连接断开时必须调用取消函数。
+func (h *SeckillEventHub) Subscribe(channels ...string) (<-chan []byte, func()) {
+	ch := make(chan []byte, h.buffer)
+	h.mu.Lock()
+	for _, c := range channels {
+		set, ok := h.subs[c]
+		if !ok {
+			set = make(map[chan []byte]struct{})
+			h.subs[c] = set
+		}
+		set[ch] = struct{}{}
+	}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			for _, c := range channels {
+				delete(h.subs[c], ch)
+				if len(h.subs[c]) == 0 {
+					delete(h.subs, c)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	radix "github.com/mediocregopher/radix/v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/example/goseckill/internal/config"
+	"github.com/example/goseckill/internal/datamodels/order"
+	"github.com/example/goseckill/internal/infra/mq"
+)
+
+// 秒杀订单支付方式
+const (
+	SeckillPaymentImmediate = "immediate" // Worker 下单时直接扣款
+	SeckillPaymentDeferred  = "deferred"  // 先创建待支付订单，超时未支付自动取消
+)
+
+const (
+	// OrderTimeoutQueue 待支付订单超时检查队列，消息在支付时限到期后投递
+	OrderTimeoutQueue = "seckill_order_timeout_queue"
+
+	orderTimeoutRetryDelay = 10 * time.Second // 取消失败后重新检查的延迟
+	orderSweepBatch        = 100
+)
+
+// OrderTimeoutMessage 待支付订单的超时检查消息
+type OrderTimeoutMessage struct {
+	OrderID int64 `json:"order_id"`
+}
+
+// SeckillOrderService 待支付秒杀订单的超时管理：Worker 创建待支付订单后投递一条延迟消息，
+// 到期时订单仍未支付则取消并归还库存；另有定时扫描兜底，防止延迟消息丢失。
+// 取消与支付都锁定订单行并校验状态，同一订单只会有一个结果生效，多实例并发处理也是安全的。
+type SeckillOrderService struct {
+	db        *gorm.DB
+	orderRepo order.Repository
+	redis     radix.Client
+	events    *SeckillEventPublisher
+	publisher mq.Publisher
+	cfg       config.OrderConfig
+}
+
+// NewSeckillOrderService 创建待支付订单服务
+func NewSeckillOrderService(
+	db *gorm.DB,
+	orderRepo order.Repository,
+	redis radix.Client,
+	events *SeckillEventPublisher,
+	publisher mq.Publisher,
+	cfg *config.OrderConfig,
+) *SeckillOrderService {
+	c := *cfg
+	if c.SeckillPayment == "" {
+		c.SeckillPayment = SeckillPaymentImmediate
+	}
+	if c.PaymentWindowSeconds <= 0 {
+		c.PaymentWindowSeconds = 900
+	}
+	if c.SweepIntervalSeconds <= 0 {
+		c.SweepIntervalSeconds = 60
+	}
+	return &SeckillOrderService{
+		db:        db,
+		orderRepo: orderRepo,
+		redis:     redis,
+		events:    events,
+		publisher: publisher,
+		cfg:       c,
+	}
+}
+
+// Deferred 是否为先下单后支付模式；nil 接收者视为立即扣款
+func (s *SeckillOrderService) Deferred() bool {
+	return s != nil && s.cfg.SeckillPayment == SeckillPaymentDeferred
+}
+
+// PaymentWindow 待支付订单的支付时限
+func (s *SeckillOrderService) PaymentWindow() time.Duration {
+	return time.Duration(s.cfg.PaymentWindowSeconds) * time.Second
+}
+
+// ScheduleTimeout 投递订单的超时检查消息。
+// 延迟固定为支付时限（RabbitMQ 按延迟时长建队列，固定时长只需一个延迟队列），
+// 订单创建早于投递，消息到达时订单已过截止时间。投递失败由定时扫描兜底。
+func (s *SeckillOrderService) ScheduleTimeout(ctx context.Context, o *order.Order) error {
+	return s.publishTimeout(ctx, o.ID, s.PaymentWindow())
+}
+
+func (s *SeckillOrderService) publishTimeout(ctx context.Context, orderID int64, delay time.Duration) error {
+	body, err := json.Marshal(&OrderTimeoutMessage{OrderID: orderID})
+	if err != nil {
+		return err
+	}
+	if err := s.publisher.Publish(ctx, OrderTimeoutQueue, &mq.Message{Body: body, Delay: delay}); err != nil {
+		GetMonitor().RecordMQError()
+		return err
+	}
+	return nil
+}
+
+// Run 消费超时检查队列并定时扫描超时订单，直到 ctx 取消或队列关闭
+func (s *SeckillOrderService) Run(ctx context.Context, consumer mq.Consumer) error {
+	msgs, err := consumer.Consume(ctx, OrderTimeoutQueue, 16)
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(time.Duration(s.cfg.SweepIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case d, ok := <-msgs:
+			if !ok {
+				return nil
+			}
+			s.handle(context.Background(), d)
+		case <-ticker.C:
+			s.Sweep(ctx)
+		}
+	}
+}
+
+// handle 处理一条超时检查消息：未到截止时间时按剩余时间（取整到秒）重新投递，
+// 取消失败时稍后重试
+func (s *SeckillOrderService) handle(ctx context.Context, d *mq.Delivery) {
+	var m OrderTimeoutMessage
+	if err := json.Unmarshal(d.Body, &m); err != nil {
+		log.Printf("invalid order timeout message: %v", err)
+		_ = d.Ack()
+		return
+	}
+	retry := time.Duration(0)
+	o, err := s.CancelExpired(ctx, m.OrderID)
+	switch {
+	case err != nil:
+		log.Printf("cancel expired order %d failed: %v", m.OrderID, err)
+		retry = orderTimeoutRetryDelay
+	case o != nil && o.Status == order.StatusCreated && o.PayDeadline != nil:
+		retry = time.Until(*o.PayDeadline).Truncate(time.Second) + time.Second
+	}
+	if retry > 0 {
+		if err := s.publishTimeout(ctx, m.OrderID, retry); err != nil {
+			log.Printf("failed to reschedule order timeout, requeue: order=%d err=%v", m.OrderID, err)
+			_ = d.Nack(true)
+			return
+		}
+	}
+	if err := d.Ack(); err != nil {
+		log.Printf("failed to ack message: %v", err)
+	}
+}
+
+// Sweep 取消所有已过截止时间仍未支付的订单
+func (s *SeckillOrderService) Sweep(ctx context.Context) {
+	for {
+		list, err := s.orderRepo.ListExpiredUnpaid(ctx, time.Now(), orderSweepBatch)
+		if err != nil {
+			log.Printf("list expired unpaid orders failed: %v", err)
+			GetMonitor().RecordDBError()
+			return
+		}
+		cancelled := 0
+		for _, o := range list {
+			got, err := s.CancelExpired(ctx, o.ID)
+			if err != nil {
+				log.Printf("cancel expired order %d failed: %v", o.ID, err)
+				continue
+			}
+			if got != nil && got.Status == order.StatusCancelled {
+				cancelled++
+			}
+		}
+		// 本批全部失败时不再重复查询同一批订单，等下一次扫描
+		if len(list) < orderSweepBatch || cancelled == 0 {
+			return
+		}
+	}
+}
+
+// CancelExpired 订单已过支付截止时间且仍未支付时取消，解冻准入时冻结的资金并归还库存（规则见 restoreOrderStock），
+// 返回订单的最新状态。订单不存在、已支付、已取消或尚未到期时不做任何修改。
+func (s *SeckillOrderService) CancelExpired(ctx context.Context, orderID int64) (*order.Order, error) {
+	snapshot, err := s.orderRepo.GetByID(ctx, orderID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		GetMonitor().RecordDBError()
+		return nil, err
+	}
+
+	var o order.Order
+	cancelled, toSeckill := false, false
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 与 PayOrder 相同，先锁账户再锁订单
+		acc, err := lockAccount(tx, snapshot.UserID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&o, orderID).Error; err != nil {
+			return err
+		}
+		if o.Status != order.StatusCreated || o.PayDeadline == nil || time.Now().Before(*o.PayDeadline) {
+			return nil
+		}
+
+		o.Status = order.StatusCancelled
+		if err := tx.Save(&o).Error; err != nil {
+			return err
+		}
+
+		f, err := lockOrderFreeze(tx, &o)
+		if err != nil {
+			return err
+		}
+		if f != nil {
+			if err := releaseFreeze(tx, acc, f, fmt.Sprintf("订单 #%d 超时未支付，解冻", o.ID)); err != nil {
+				return err
+			}
+		}
+
+		toSeckill, err = restoreOrderStock(tx, &o)
+		if err != nil {
+			return err
+		}
+		cancelled = true
+		return nil
+	})
+	if err != nil {
+		GetMonitor().RecordDBError()
+		return nil, err
+	}
+	if !cancelled {
+		return &o, nil
+	}
+
+	log.Printf("unpaid order cancelled after deadline: order=%d user=%d product=%d activity=%d", o.ID, o.UserID, o.ProductID, o.ActivityID)
+	if toSeckill {
+		restoreSeckillRedis(s.redis, s.events, &o)
+	}
+	return &o, nil
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+
+	radix "github.com/mediocregopher/radix/v3"
+)
+
+// 秒杀准入脚本的返回码，与 seckillAdmitScript 中的 return 值一一对应
+const (
+	admitOK            = 0 // 准入成功：已扣减库存并累加限购计数
+	admitPathInvalid   = 1 // path 不存在或不匹配
+	admitDuplicate     = 2 // 同一个 path 重复提交
+	admitLimitExceeded = 3 // 超过每人限购
+	admitSoldOut       = 4 // 库存不足
+)
+
+// 秒杀准入阶段可能返回的错误，调用方可用 errors.Is 判断
+var (
+	ErrSeckillPathInvalid    = errors.New("秒杀地址无效或已过期")
+	ErrSeckillDuplicate      = errors.New("请勿重复抢购")
+	ErrSeckillLimitExceeded  = errors.New("超过每人限购数量，无法继续秒杀")
+	ErrSeckillSoldOut        = errors.New("秒杀库存不足")
+	ErrSeckillActivityPaused = errors.New("秒杀活动已暂停")
+)
+
+// admitCodeErrors 将脚本返回码映射为服务层错误
+var admitCodeErrors = map[int]error{
+	admitPathInvalid:   ErrSeckillPathInvalid,
+	admitDuplicate:     ErrSeckillDuplicate,
+	admitLimitExceeded: ErrSeckillLimitExceeded,
+	admitSoldOut:       ErrSeckillSoldOut,
+}
+
+// seckillAdmitScript 在 Redis 服务端一次性完成：
+//  1. 校验 path
+//  2. 同一 path 的重复请求拦截
+//  3. 每人限购校验
+//  4. 库存预扣
+//
+// 任一校验失败都不会修改任何计数，避免多次往返之间崩溃/超时导致的计数漂移。
+//
+// KEYS[1] path 键  KEYS[2] 限购计数键  KEYS[3] 库存键  KEYS[4] 请求去重键
+// ARGV[1] path     ARGV[2] 每人限购数  ARGV[3] 限购计数过期秒数  ARGV[4] 去重键过期秒数
+var seckillAdmitScript = radix.NewEvalScript(4, `
+local stored = redis.call('GET', KEYS[1])
+if (not stored) or stored ~= ARGV[1] then
+	return 1
+end
+if redis.call('EXISTS', KEYS[4]) == 1 then
+	return 2
+end
+local used = tonumber(redis.call('GET', KEYS[2]) or '0')
+if used >= tonumber(ARGV[2]) then
+	return 3
+end
+local stock = tonumber(redis.call('GET', KEYS[3]) or '0')
+if stock <= 0 then
+	return 4
+end
+redis.call('DECR', KEYS[3])
+if redis.call('INCR', KEYS[2]) == 1 then
+	redis.call('EXPIRE', KEYS[2], ARGV[3])
+end
+redis.call('SET', KEYS[4], '1', 'EX', ARGV[4])
+return 0
+`)
+
+// seckillReleaseScript 撤销一次已准入的秒杀（例如写 MQ 失败），同样在服务端原子执行：
+// 归还库存、回退限购计数并清除去重标记。
+//
+// KEYS[1] 限购计数键  KEYS[2] 库存键  KEYS[3] 请求去重键
+var seckillReleaseScript = radix.NewEvalScript(3, `
+if redis.call('DEL', KEYS[3]) == 0 then
+	return 0
+end
+redis.call('INCR', KEYS[2])
+local used = tonumber(redis.call('GET', KEYS[1]) or '0')
+if used > 0 then
+	redis.call('DECR', KEYS[1])
+end
+return 1
+`)
+
+// seckillRollbackScript 最终放弃一次秒杀（例如消息进入死信）时归还库存并回退限购计数
+//
+// KEYS[1] 限购计数键  KEYS[2] 库存键
+var seckillRollbackScript = radix.NewEvalScript(2, `
+redis.call('INCR', KEYS[2])
+local used = tonumber(redis.call('GET', KEYS[1]) or '0')
+if used > 0 then
+	redis.call('DECR', KEYS[1])
+end
+return 0
+`)
+
+// seckillReserveScript 为重新投递的死信重新占用库存与限购计数（不做限购校验）
+//
+// KEYS[1] 限购计数键  KEYS[2] 库存键  ARGV[1] 限购计数过期秒数
+var seckillReserveScript = radix.NewEvalScript(2, `
+local stock = tonumber(redis.call('GET', KEYS[2]) or '0')
+if stock <= 0 then
+	return 4
+end
+redis.call('DECR', KEYS[2])
+if redis.call('INCR', KEYS[1]) == 1 then
+	redis.call('EXPIRE', KEYS[1], ARGV[1])
+end
+return 0
+`)
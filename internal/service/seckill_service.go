@@ -2,197 +2,362 @@ package service
 
 import (
 	"context"
-	"crypto/md5"
+	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"strconv"
 	"time"
 
 	radix "github.com/mediocregopher/radix/v3"
-	amqp "github.com/rabbitmq/amqp091-go"
 
-	"github.com/example/goseckill/internal/config"
 	"github.com/example/goseckill/internal/datamodels/product"
 	"github.com/example/goseckill/internal/datamodels/seckill_activity"
+	"github.com/example/goseckill/internal/infra/mq"
 )
 
 const (
-	redisSeckillPathKey    = "seckill:path:%d:%d"           // userID, productID
-	redisSeckillStockKey   = "seckill:stock:%d"             // productID
-	redisSeckillSuccessKey = "seckill:succ:%d:%d"           // userID, productID (成功标记，供结果查询/幂等使用)
-	redisSeckillLimitKey   = "seckill:limit:%d:%d:%d"       // userID, productID, activityID（每个活动单独计数）
+	redisSeckillPathKey      = "seckill:path:%d:%d"     // userID, productID
+	redisSeckillStockKey     = "seckill:stock:%d:%d"    // productID, activityID（每个活动单独的秒杀库存）
+	redisSeckillSuccessKey   = "seckill:succ:%d:%d"     // userID, productID (成功标记，供结果查询/幂等使用)
+	redisSeckillLimitKey     = "seckill:limit:%d:%d:%d" // userID, productID, activityID（每个活动单独计数）
+	redisSeckillRequestKey   = "seckill:req:%d:%d:%s"   // userID, productID, path（同一 path 只允许提交一次）
+	redisSeckillProcessedKey = "seckill:processed:%s"   // requestID（Worker 已处理完成的请求）
 
-	seckillQueue = "seckill_queue"
+	// 按商品/活动批量清理时使用的 SCAN 模式
+	redisSeckillLimitPattern = "seckill:limit:*:%d:%d" // productID, activityID
+	redisSeckillPathPattern  = "seckill:path:*:%d"     // productID
+
+	seckillLimitExpireSeconds     = 86400 // 限购计数保留 24 小时
+	seckillRequestExpireSeconds   = 300   // 与 path 有效期一致
+	seckillProcessedExpireSeconds = 86400 // 已处理请求标记保留 24 小时
+
+	// SeckillQueue 秒杀下单消息队列
+	SeckillQueue = "seckill_queue"
 )
 
 type SeckillMessage struct {
-	UserID    int64 `json:"user_id"`
-	ProductID int64 `json:"product_id"`
+	RequestID  string `json:"request_id"` // 每次准入唯一，Worker 据此保证同一请求只下一单
+	UserID     int64  `json:"user_id"`
+	ProductID  int64  `json:"product_id"`
+	ActivityID int64  `json:"activity_id"`
+	Frozen     int64  `json:"frozen,omitempty"`    // 准入时冻结的金额（分，已扣除优惠），Worker 以此为订单金额
+	CouponID   int64  `json:"coupon_id,omitempty"` // 准入时占用的优惠券，随冻结记录核销或退回
 }
 
 type SeckillService struct {
 	productRepo  product.Repository
 	activityRepo seckill_activity.Repository
 	redis        radix.Client
-	mqConn       *amqp.Connection
-	jwtCfg       *config.JWTConfig
+	publisher    mq.Publisher
+	tickets      *SeckillTicketService
+	events       *SeckillEventPublisher
+	accounts     *AccountService
 }
 
 func NewSeckillService(
 	productRepo product.Repository,
 	activityRepo seckill_activity.Repository,
 	redis radix.Client,
-	mqConn *amqp.Connection,
-	jwtCfg *config.JWTConfig,
+	publisher mq.Publisher,
+	tickets *SeckillTicketService,
+	events *SeckillEventPublisher,
+	accounts *AccountService,
 ) *SeckillService {
 	return &SeckillService{
 		productRepo:  productRepo,
 		activityRepo: activityRepo,
 		redis:        redis,
-		mqConn:       mqConn,
-		jwtCfg:       jwtCfg,
+		publisher:    publisher,
+		tickets:      tickets,
+		events:       events,
+		accounts:     accounts,
 	}
 }
 
-// InitProductStock 将商品秒杀库存同步到 Redis
-func (s *SeckillService) InitProductStock(ctx context.Context, p *product.Product) error {
-	key := fmt.Sprintf(redisSeckillStockKey, p.ID)
-	return s.redis.Do(radix.FlatCmd(nil, "SET", key, p.SeckillStock))
+// SeckillStockKey 商品在某个活动中的 Redis 秒杀库存键。
+// 库存按活动隔离，同一商品在不同时间窗口的活动互不影响。
+func SeckillStockKey(productID, activityID int64) string {
+	return fmt.Sprintf(redisSeckillStockKey, productID, activityID)
+}
+
+// InitProductStock 将商品秒杀库存同步到 Redis 中该活动的库存键
+func (s *SeckillService) InitProductStock(ctx context.Context, p *product.Product, activityID int64) error {
+	if err := s.redis.Do(radix.FlatCmd(nil, "SET", SeckillStockKey(p.ID, activityID), p.SeckillStock)); err != nil {
+		return err
+	}
+	s.events.NotifyStock(p.ID, activityID)
+	return nil
+}
+
+// TakeRemainingStock 活动结束结算时原子地取走 Redis 中剩余的秒杀库存（置为 0），
+// 之后不会再有请求通过准入。ok 为 false 表示 Redis 中没有该商品的库存记录。
+func (s *SeckillService) TakeRemainingStock(ctx context.Context, productID, activityID int64) (remaining int64, ok bool, err error) {
+	var stockStr string
+	mn := radix.MaybeNil{Rcv: &stockStr}
+	if err := s.redis.Do(radix.Cmd(&mn, "GETSET", SeckillStockKey(productID, activityID), "0")); err != nil {
+		GetMonitor().RecordRedisError()
+		return 0, false, err
+	}
+	if mn.Nil {
+		return 0, false, nil
+	}
+	remaining, _ = strconv.ParseInt(stockStr, 10, 64)
+	s.events.NotifyStock(productID, activityID)
+	return remaining, true, nil
 }
 
-// GeneratePath 生成动态秒杀地址
+// RestoreStock 结算失败时把取走的库存加回 Redis
+func (s *SeckillService) RestoreStock(ctx context.Context, productID, activityID, n int64) error {
+	if n <= 0 {
+		return nil
+	}
+	if err := s.redis.Do(radix.FlatCmd(nil, "INCRBY", SeckillStockKey(productID, activityID), n)); err != nil {
+		GetMonitor().RecordRedisError()
+		return err
+	}
+	s.events.NotifyStock(productID, activityID)
+	return nil
+}
+
+// ClearActivityKeys 活动取消后清理该商品在该活动中的 Redis 秒杀库存、限购计数与未使用的秒杀地址，
+// 避免取消后残留的库存被继续抢购
+func (s *SeckillService) ClearActivityKeys(ctx context.Context, productID, activityID int64) error {
+	keys := []string{SeckillStockKey(productID, activityID)}
+	for _, pattern := range []string{
+		fmt.Sprintf(redisSeckillLimitPattern, productID, activityID),
+		fmt.Sprintf(redisSeckillPathPattern, productID),
+	} {
+		scanner := radix.NewScanner(s.redis, radix.ScanOpts{
+			Command: "SCAN",
+			Pattern: pattern,
+			Count:   500,
+		})
+		var key string
+		for scanner.Next(&key) {
+			keys = append(keys, key)
+		}
+		if err := scanner.Close(); err != nil {
+			GetMonitor().RecordRedisError()
+			return err
+		}
+	}
+
+	const batch = 500
+	for i := 0; i < len(keys); i += batch {
+		end := i + batch
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if err := s.redis.Do(radix.Cmd(nil, "DEL", keys[i:end]...)); err != nil {
+			GetMonitor().RecordRedisError()
+			return err
+		}
+	}
+	s.events.NotifyStock(productID, activityID)
+	return nil
+}
+
+// GeneratePath 生成动态秒杀地址：128 位随机数，只在 Redis 中与用户、商品绑定，不依赖任何密钥
 func (s *SeckillService) GeneratePath(ctx context.Context, userID, productID int64) (string, error) {
-	raw := fmt.Sprintf("u%d-p%d-%d-%s", userID, productID, time.Now().UnixNano(), s.jwtCfg.Secret)
-	sum := md5.Sum([]byte(raw))
-	path := hex.EncodeToString(sum[:])
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	path := hex.EncodeToString(b)
 
 	key := fmt.Sprintf(redisSeckillPathKey, userID, productID)
 	err := s.redis.Do(radix.FlatCmd(nil, "SETEX", key, 300, path)) // 5 分钟有效
 	return path, err
 }
 
-// Seckill 发起秒杀：校验 path、预减库存、写 MQ
-func (s *SeckillService) Seckill(ctx context.Context, userID, productID int64, path string) error {
+// Seckill 发起秒杀：校验 path、预减库存、冻结资金、写 MQ，返回凭证ID供客户端查询最终结果。
+// couponID 大于 0 时使用该优惠券（模板需允许用于秒杀），冻结与订单金额为减免后的金额。
+func (s *SeckillService) Seckill(ctx context.Context, userID, productID int64, path string, couponID int64) (string, error) {
 	GetMonitor().RecordSeckillRequest()
 	// 0. 获取商品信息并校验时间和状态
 	p, err := s.productRepo.GetByID(ctx, productID)
 	if err != nil {
-		return fmt.Errorf("product not found: %v", err)
+		return "", fmt.Errorf("product not found: %v", err)
 	}
 	
 	// 校验商品状态（必须是秒杀中）
 	if p.Status != 2 {
-		return fmt.Errorf("商品当前不在秒杀状态")
+		return "", fmt.Errorf("商品当前不在秒杀状态")
 	}
 	
 	// 校验秒杀时间
 	now := time.Now()
 	if now.Before(p.StartTime) {
 		GetMonitor().RecordSeckillError()
-		return fmt.Errorf("秒杀尚未开始")
+		return "", fmt.Errorf("秒杀尚未开始")
 	}
 	if now.After(p.EndTime) {
 		GetMonitor().RecordSeckillError()
-		return fmt.Errorf("秒杀已结束")
+		return "", fmt.Errorf("秒杀已结束")
 	}
 	
-	// 1. 校验 path
-	pathKey := fmt.Sprintf(redisSeckillPathKey, userID, productID)
-	var stored string
-	if err := s.redis.Do(radix.Cmd(&stored, "GET", pathKey)); err != nil {
-		return err
-	}
-	if stored == "" || stored != path {
-		return fmt.Errorf("秒杀地址无效或已过期")
-	}
-
-	// 2. 校验当前用户是否超过“每人限购”次数（基于活动配置，使用 Redis 计数）
+	// 1. 确定当前进行中的活动及其每人限购数量与秒杀价
 	limit := int64(1)
+	price := p.Price
 	var activeActID int64
+	paused := false
 	if s.activityRepo != nil {
 		activities, err := s.activityRepo.GetActivitiesByProduct(ctx, productID)
 		if err == nil && len(activities) > 0 {
 			now := time.Now()
 			for _, act := range activities {
-				if act.Status == 1 && now.After(act.StartTime) && now.Before(act.EndTime) {
+				if !now.After(act.StartTime) || !now.Before(act.EndTime) {
+					continue
+				}
+				if act.Status == seckill_activity.StatusPaused {
+					paused = true
+				}
+				if act.Status == seckill_activity.StatusRunning {
 					activeActID = act.ID
-					if act.LimitPerUser > 0 {
-						limit = act.LimitPerUser
+					ap, err := s.activityRepo.GetActivityProduct(ctx, act.ID, productID)
+					if err != nil {
+						GetMonitor().RecordDBError()
+						return "", fmt.Errorf("get activity product failed: %v", err)
 					}
+					limit = SeckillLimitOf(act, ap)
+					price = SeckillPriceOf(p.Price, act, ap)
 					break
 				}
 			}
 		}
 	}
 
-	// 如果没找到当前正在进行的活动，说明配置有问题或活动已结束
+	// 如果没找到当前正在进行的活动，说明活动已暂停、配置有问题或活动已结束
 	if activeActID == 0 {
-		return fmt.Errorf("当前没有进行中的秒杀活动")
+		GetMonitor().RecordSeckillError()
+		if paused {
+			return "", ErrSeckillActivityPaused
+		}
+		return "", fmt.Errorf("当前没有进行中的秒杀活动")
 	}
 
-	limitKey := fmt.Sprintf(redisSeckillLimitKey, userID, productID, activeActID)
-	var used int
-	// INCR 原子增加已抢购次数
-	if err := s.redis.Do(radix.Cmd(&used, "INCR", limitKey)); err != nil {
-		return err
+	// 2. 在 Redis 中原子完成 path 校验、重复请求拦截、限购校验与库存预扣
+	if err := s.Admit(ctx, userID, productID, activeActID, limit, path); err != nil {
+		return "", err
 	}
-	// 第一次成功时设置过期时间（这里简单设置 24 小时，可按需调整）
-	if used == 1 {
-		_ = s.redis.Do(radix.Cmd(nil, "EXPIRE", limitKey, "86400"))
+
+	m := &SeckillMessage{
+		RequestID:  newRequestID(),
+		UserID:     userID,
+		ProductID:  productID,
+		ActivityID: activeActID,
 	}
-	if int64(used) > limit {
-		// 超出限购，回滚计数并返回错误
-		_ = s.redis.Do(radix.Cmd(nil, "DECR", limitKey))
-		return fmt.Errorf("超过每人限购数量，无法继续秒杀")
+
+	// 3. 把秒杀价（扣除优惠券减免）从可用余额冻结，余额不足或优惠券不可用的请求在这里被拒绝并撤销准入，不再进入队列
+	if s.accounts != nil {
+		frozen, err := s.accounts.FreezeForSeckill(ctx, userID, price, m.RequestID, p, couponID)
+		if err != nil {
+			if !isCouponError(err) && !errors.Is(err, ErrInsufficientBalance) {
+				GetMonitor().RecordDBError()
+			}
+			GetMonitor().RecordSeckillError()
+			s.release(userID, productID, activeActID, path)
+			return "", err
+		}
+		m.Frozen = frozen
+		m.CouponID = couponID
 	}
 
-	// 3. 预减库存
-	stockKey := fmt.Sprintf(redisSeckillStockKey, productID)
-	var left int
-	if err := s.redis.Do(radix.Cmd(&left, "DECR", stockKey)); err != nil {
-		GetMonitor().RecordRedisError()
-		return err
+	// 4. 写入排队中的凭证后写 MQ，任一步失败都撤销本次准入并解冻资金
+	body, err := json.Marshal(m)
+	if err != nil {
+		s.abort(ctx, m, path, err.Error())
+		return "", err
 	}
-	if left < 0 {
-		// 回滚
-		_ = s.redis.Do(radix.Cmd(nil, "INCR", stockKey))
-		return fmt.Errorf("秒杀库存不足")
+
+	if s.tickets != nil {
+		if err := s.tickets.Create(ctx, m); err != nil {
+			GetMonitor().RecordDBError()
+			s.abort(ctx, m, path, err.Error())
+			return "", err
+		}
 	}
 
-	// 4. 写 MQ
-	ch, err := s.mqConn.Channel()
+	if err := s.publisher.Publish(ctx, SeckillQueue, &mq.Message{Body: body}); err != nil {
+		GetMonitor().RecordMQError()
+		s.abort(ctx, m, path, "写入秒杀队列失败: "+err.Error())
+		if s.tickets != nil {
+			_ = s.tickets.Fail(ctx, m, "写入秒杀队列失败: "+err.Error())
+		}
+		return "", err
+	}
+	GetMonitor().RecordSeckillSuccess()
+	return m.RequestID, nil
+}
+
+// Admit 秒杀准入：通过 Lua 脚本在 Redis 服务端一次性完成 path 校验、
+// 同一 path 重复提交拦截、每人限购校验与库存预扣。
+// 校验失败时返回 ErrSeckillPathInvalid / ErrSeckillDuplicate /
+// ErrSeckillLimitExceeded / ErrSeckillSoldOut 之一，且不会修改任何计数。
+func (s *SeckillService) Admit(ctx context.Context, userID, productID, activityID, limit int64, path string) error {
+	if limit <= 0 {
+		limit = 1
+	}
+	var code int
+	err := s.redis.Do(seckillAdmitScript.Cmd(&code,
+		fmt.Sprintf(redisSeckillPathKey, userID, productID),
+		fmt.Sprintf(redisSeckillLimitKey, userID, productID, activityID),
+		SeckillStockKey(productID, activityID),
+		fmt.Sprintf(redisSeckillRequestKey, userID, productID, path),
+		path,
+		strconv.FormatInt(limit, 10),
+		strconv.Itoa(seckillLimitExpireSeconds),
+		strconv.Itoa(seckillRequestExpireSeconds),
+	))
 	if err != nil {
+		GetMonitor().RecordRedisError()
 		return err
 	}
-	defer ch.Close()
+	if code == admitOK {
+		s.events.NotifyStock(productID, activityID)
+		return nil
+	}
+	GetMonitor().RecordSeckillError()
+	if e, ok := admitCodeErrors[code]; ok {
+		return e
+	}
+	return fmt.Errorf("unknown seckill admit result: %d", code)
+}
 
-	if _, err = ch.QueueDeclare(seckillQueue, true, false, false, false, nil); err != nil {
-		return err
+// newRequestID 生成全局唯一的秒杀请求ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// 随机源不可用时退化为时间戳，仍能区分绝大多数请求
+		return fmt.Sprintf("t%d", time.Now().UnixNano())
 	}
+	return hex.EncodeToString(b)
+}
 
-	body, err := json.Marshal(&SeckillMessage{
-		UserID:    userID,
-		ProductID: productID,
-	})
+// release 撤销一次已准入的秒杀（写 MQ 失败时调用）
+func (s *SeckillService) release(userID, productID, activityID int64, path string) {
+	err := s.redis.Do(seckillReleaseScript.Cmd(nil,
+		fmt.Sprintf(redisSeckillLimitKey, userID, productID, activityID),
+		SeckillStockKey(productID, activityID),
+		fmt.Sprintf(redisSeckillRequestKey, userID, productID, path),
+	))
 	if err != nil {
-		return err
+		GetMonitor().RecordRedisError()
+		return
 	}
+	s.events.NotifyStock(productID, activityID)
+}
 
-	err = ch.PublishWithContext(
-		ctx,
-		"",
-		seckillQueue,
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
-		},
-	)
-	if err != nil {
-		GetMonitor().RecordMQError()
-		return err
+// abort 冻结资金之后的步骤失败时调用：撤销准入并解冻资金
+func (s *SeckillService) abort(ctx context.Context, m *SeckillMessage, path, reason string) {
+	s.release(m.UserID, m.ProductID, m.ActivityID, path)
+	if s.accounts != nil && m.Frozen > 0 {
+		if err := s.accounts.ReleaseFreeze(ctx, m.RequestID, reason); err != nil {
+			log.Printf("failed to release frozen funds: request=%s err=%v", m.RequestID, err)
+			GetMonitor().RecordDBError()
+		}
 	}
-	GetMonitor().RecordSeckillSuccess()
-	return nil
 }
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/example/goseckill/internal/datamodels/seckill_ticket"
+)
+
+// ErrSeckillTicketNotFound 凭证不存在或不属于当前用户
+var ErrSeckillTicketNotFound = errors.New("秒杀凭证不存在")
+
+// seckillTicketStatusNames 凭证状态在接口中的展示值
+var seckillTicketStatusNames = map[int]string{
+	seckill_ticket.StatusQueued:    "queued",
+	seckill_ticket.StatusSucceeded: "succeeded",
+	seckill_ticket.StatusFailed:    "failed",
+}
+
+// SeckillTicketService 秒杀凭证：记录每个秒杀请求从排队到成功/失败的最终结果，
+// 客户端凭 Seckill 返回的凭证ID查询精确结果，
+// 写入最终结果的同时向用户推送。
+type SeckillTicketService struct {
+	repo   seckill_ticket.Repository
+	events *SeckillEventPublisher
+}
+
+// NewSeckillTicketService 创建秒杀凭证服务，events 可为 nil（不推送）
+func NewSeckillTicketService(repo seckill_ticket.Repository, events *SeckillEventPublisher) *SeckillTicketService {
+	return &SeckillTicketService{repo: repo, events: events}
+}
+
+// Create 为已准入的秒杀请求写入排队中的凭证
+func (s *SeckillTicketService) Create(ctx context.Context, m *SeckillMessage) error {
+	return s.repo.Create(ctx, &seckill_ticket.SeckillTicket{
+		ID:         m.RequestID,
+		UserID:     m.UserID,
+		ProductID:  m.ProductID,
+		ActivityID: m.ActivityID,
+		Status:     seckill_ticket.StatusQueued,
+	})
+}
+
+// Get 查询用户自己的秒杀凭证
+func (s *SeckillTicketService) Get(ctx context.Context, userID int64, id string) (*seckill_ticket.SeckillTicket, error) {
+	t, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSeckillTicketNotFound
+		}
+		return nil, err
+	}
+	if t.UserID != userID {
+		return nil, ErrSeckillTicketNotFound
+	}
+	return t, nil
+}
+
+// Succeed 记录下单成功并推送给用户；已是最终状态的凭证不会被覆盖
+func (s *SeckillTicketService) Succeed(ctx context.Context, m *SeckillMessage, orderID int64) error {
+	ok, err := s.repo.UpdateResult(ctx, m.RequestID, seckill_ticket.StatusQueued, seckill_ticket.StatusSucceeded, orderID, "")
+	if err != nil {
+		return err
+	}
+	if ok {
+		s.events.PublishResult(m.UserID, m.ProductID, m.RequestID, seckill_ticket.StatusSucceeded, orderID, "")
+	}
+	return nil
+}
+
+// Fail 记录下单失败并推送给用户；已是最终状态的凭证不会被覆盖
+func (s *SeckillTicketService) Fail(ctx context.Context, m *SeckillMessage, reason string) error {
+	reason = truncate(reason, 512)
+	ok, err := s.repo.UpdateResult(ctx, m.RequestID, seckill_ticket.StatusQueued, seckill_ticket.StatusFailed, 0, reason)
+	if err != nil {
+		return err
+	}
+	if ok {
+		s.events.PublishResult(m.UserID, m.ProductID, m.RequestID, seckill_ticket.StatusFailed, 0, reason)
+	}
+	return nil
+}
+
+// Requeue 死信重新投递时把失败的凭证恢复为排队中
+func (s *SeckillTicketService) Requeue(ctx context.Context, id string) error {
+	_, err := s.repo.UpdateResult(ctx, id, seckill_ticket.StatusFailed, seckill_ticket.StatusQueued, 0, "")
+	return err
+}
+
+// SeckillTicketStatusName 返回凭证状态的展示值
+func SeckillTicketStatusName(status int) string {
+	return seckillTicketStatusNames[status]
+}
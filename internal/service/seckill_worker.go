@@ -0,0 +1,418 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	radix "github.com/mediocregopher/radix/v3"
+
+	"github.com/example/goseckill/internal/config"
+	"github.com/example/goseckill/internal/datamodels/order"
+	"github.com/example/goseckill/internal/datamodels/product"
+	"github.com/example/goseckill/internal/datamodels/seckill_activity"
+	"github.com/example/goseckill/internal/infra/mq"
+)
+
+const (
+	successMarkExpireSeconds = 86400 // 成功标记 24 小时有效
+
+	// headerAttempts 消息头中记录的已失败次数
+	headerAttempts = "x-seckill-attempts"
+)
+
+// SeckillWorker 秒杀下单消费者：从 SeckillQueue 读取消息，扣减 MySQL 库存、扣费并创建订单。
+// orders 为 deferred 模式时不扣费，只创建待支付订单并安排超时取消。
+// 既可由 cmd/seckill-worker 独立运行，也可在使用进程内队列时嵌入 web 进程。
+//
+// 处理失败的消息带着失败次数延迟重新投递（指数退避），
+// 达到 MaxAttempts 后写入死信并最终归还 Redis 库存。
+type SeckillWorker struct {
+	productRepo   product.Repository
+	activitySvc   *SeckillActivityService
+	accountSvc    *AccountService
+	orders        *SeckillOrderService
+	deadLetterSvc *DeadLetterService
+	tickets       *SeckillTicketService
+	redis         radix.Client
+	publisher     mq.Publisher
+	cfg           config.WorkerConfig
+
+	state     int32 // workerStateXxx
+	inFlight  int64
+	startedAt time.Time
+	rate      *rateCounter
+}
+
+// Worker 运行状态
+const (
+	workerStateIdle     = 0 // 尚未启动
+	workerStateRunning  = 1 // 正常消费
+	workerStateDraining = 2 // 已停止接收新消息，等待在途消息完成
+	workerStateStopped  = 3 // 已退出
+)
+
+var workerStateNames = map[int32]string{
+	workerStateIdle:     "idle",
+	workerStateRunning:  "running",
+	workerStateDraining: "draining",
+	workerStateStopped:  "stopped",
+}
+
+// NewSeckillWorker 创建秒杀消费者
+func NewSeckillWorker(
+	productRepo product.Repository,
+	activitySvc *SeckillActivityService,
+	accountSvc *AccountService,
+	orders *SeckillOrderService,
+	deadLetterSvc *DeadLetterService,
+	tickets *SeckillTicketService,
+	redis radix.Client,
+	publisher mq.Publisher,
+	cfg *config.WorkerConfig,
+) *SeckillWorker {
+	c := *cfg
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 5
+	}
+	if c.RetryBaseDelayMillis <= 0 {
+		c.RetryBaseDelayMillis = 1000
+	}
+	if c.RetryMaxDelayMillis < c.RetryBaseDelayMillis {
+		c.RetryMaxDelayMillis = c.RetryBaseDelayMillis
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 1
+	}
+	if c.Prefetch <= 0 {
+		c.Prefetch = c.Concurrency
+	}
+	return &SeckillWorker{
+		productRepo:   productRepo,
+		activitySvc:   activitySvc,
+		accountSvc:    accountSvc,
+		orders:        orders,
+		deadLetterSvc: deadLetterSvc,
+		tickets:       tickets,
+		redis:         redis,
+		publisher:     publisher,
+		cfg:           c,
+		rate:          newRateCounter(60),
+	}
+}
+
+// Run 以 Concurrency 个协程并发消费秒杀队列，直到 ctx 取消或队列关闭。
+// ctx 取消后立即停止接收新消息，并在所有在途消息处理完（Ack/Nack）后才返回，
+// 消息处理本身使用独立的 context，不会被中途打断。
+func (w *SeckillWorker) Run(ctx context.Context, consumer mq.Consumer) error {
+	msgs, err := consumer.Consume(ctx, SeckillQueue, w.cfg.Prefetch)
+	if err != nil {
+		return err
+	}
+	w.startedAt = time.Now()
+	atomic.StoreInt32(&w.state, workerStateRunning)
+	go func() {
+		<-ctx.Done()
+		atomic.CompareAndSwapInt32(&w.state, workerStateRunning, workerStateDraining)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range msgs {
+				atomic.AddInt64(&w.inFlight, 1)
+				w.Handle(context.Background(), d)
+				atomic.AddInt64(&w.inFlight, -1)
+				w.rate.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	atomic.StoreInt32(&w.state, workerStateStopped)
+	return nil
+}
+
+// Healthy Worker 是否处于正常消费状态
+func (w *SeckillWorker) Healthy() bool {
+	return atomic.LoadInt32(&w.state) == workerStateRunning
+}
+
+// Status 返回 Worker 运行状态与吞吐统计
+func (w *SeckillWorker) Status() map[string]interface{} {
+	state := atomic.LoadInt32(&w.state)
+	var uptime float64
+	if !w.startedAt.IsZero() {
+		uptime = time.Since(w.startedAt).Seconds()
+	}
+	return map[string]interface{}{
+		"state":          workerStateNames[state],
+		"concurrency":    w.cfg.Concurrency,
+		"prefetch":       w.cfg.Prefetch,
+		"in_flight":      atomic.LoadInt64(&w.inFlight),
+		"uptime_seconds": uptime,
+		"throughput_1m":  w.rate.PerSecond(),
+		"handled_1m":     w.rate.Sum(),
+		"monitor":        GetMonitor().GetStats(),
+	}
+}
+
+// Handle 处理单条秒杀消息并负责 Ack/Nack
+func (w *SeckillWorker) Handle(ctx context.Context, d *mq.Delivery) {
+	var m SeckillMessage
+	if err := json.Unmarshal(d.Body, &m); err != nil {
+		log.Printf("invalid message: %v", err)
+		// 消息格式错误，重试无意义，直接进入死信
+		w.deadLetter(ctx, d, nil, attemptsOf(d)+1, err)
+		return
+	}
+	// 已处理过的请求（重复投递 / 重复发布）直接确认，不产生任何副作用
+	if w.isProcessed(&m) {
+		log.Printf("duplicate seckill message ignored: request=%s user=%d product=%d", m.RequestID, m.UserID, m.ProductID)
+		GetMonitor().RecordWorkerDuplicate()
+		_ = d.Ack()
+		return
+	}
+	o, err := w.process(ctx, &m)
+	if errors.Is(err, ErrDuplicateSeckillRequest) {
+		// 订单已存在（Redis 标记丢失或并发重复消息），补写凭证与标记后确认
+		log.Printf("duplicate seckill message ignored: request=%s order exists", m.RequestID)
+		GetMonitor().RecordWorkerDuplicate()
+		if !w.succeedTicket(ctx, d, &m, o) {
+			return
+		}
+		w.markProcessed(&m)
+		_ = d.Ack()
+		return
+	}
+	if err != nil {
+		log.Printf("process seckill message failed: user=%d product=%d err=%v", m.UserID, m.ProductID, err)
+		GetMonitor().RecordWorkerFailed()
+		w.retryOrDeadLetter(ctx, d, &m, err)
+		return
+	}
+	GetMonitor().RecordWorkerProcessed()
+	if !w.succeedTicket(ctx, d, &m, o) {
+		return
+	}
+	w.markProcessed(&m)
+
+	// 处理成功，确认消息
+	if err := d.Ack(); err != nil {
+		log.Printf("failed to ack message: %v", err)
+	}
+}
+
+// retryOrDeadLetter 未达到最大次数时带退避延迟重新投递，否则写入死信
+func (w *SeckillWorker) retryOrDeadLetter(ctx context.Context, d *mq.Delivery, m *SeckillMessage, cause error) {
+	attempts := attemptsOf(d) + 1
+	if attempts >= w.cfg.MaxAttempts {
+		w.deadLetter(ctx, d, m, attempts, cause)
+		return
+	}
+
+	headers := make(map[string]interface{}, len(d.Headers)+1)
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[headerAttempts] = int64(attempts)
+	delay := w.backoff(attempts)
+	if err := w.publisher.Publish(ctx, SeckillQueue, &mq.Message{Body: d.Body, Headers: headers, Delay: delay}); err != nil {
+		log.Printf("failed to schedule retry, requeue immediately: %v", err)
+		GetMonitor().RecordMQError()
+		_ = d.Nack(true)
+		return
+	}
+	GetMonitor().RecordWorkerRetried()
+	log.Printf("seckill message scheduled for retry: user=%d product=%d attempt=%d delay=%v", m.UserID, m.ProductID, attempts, delay)
+	if err := d.Ack(); err != nil {
+		log.Printf("failed to ack message: %v", err)
+	}
+}
+
+// deadLetter 写入死信（同时归还 Redis 库存）并确认原消息
+func (w *SeckillWorker) deadLetter(ctx context.Context, d *mq.Delivery, m *SeckillMessage, attempts int, cause error) {
+	if err := w.deadLetterSvc.Record(ctx, d.Body, m, attempts, cause.Error()); err != nil {
+		log.Printf("failed to record dead letter, requeue: %v", err)
+		GetMonitor().RecordDBError()
+		_ = d.Nack(true)
+		return
+	}
+	GetMonitor().RecordWorkerDeadLettered()
+	if m != nil {
+		if w.tickets != nil {
+			if err := w.tickets.Fail(ctx, m, cause.Error()); err != nil {
+				log.Printf("failed to mark seckill ticket failed: request=%s err=%v", m.RequestID, err)
+				GetMonitor().RecordDBError()
+			}
+		}
+		// 死信已归还库存，标记为已处理，避免重复投递时再次归还
+		w.markProcessed(m)
+	}
+	log.Printf("seckill message moved to dead letter after %d attempts: %v", attempts, cause)
+	if err := d.Ack(); err != nil {
+		log.Printf("failed to ack message: %v", err)
+	}
+}
+
+// succeedTicket 将凭证标记为成功。写入失败时把消息放回队列并返回 false：
+// 重新投递时订单已存在，会走重复消息分支再次补写凭证，不会重复扣款。
+func (w *SeckillWorker) succeedTicket(ctx context.Context, d *mq.Delivery, m *SeckillMessage, o *order.Order) bool {
+	if w.tickets == nil || m.RequestID == "" || o == nil {
+		return true
+	}
+	if err := w.tickets.Succeed(ctx, m, o.ID); err != nil {
+		log.Printf("failed to mark seckill ticket succeeded, requeue: request=%s err=%v", m.RequestID, err)
+		GetMonitor().RecordDBError()
+		_ = d.Nack(true)
+		return false
+	}
+	return true
+}
+
+// isProcessed 判断请求是否已被处理（成功下单或已进入死信）
+func (w *SeckillWorker) isProcessed(m *SeckillMessage) bool {
+	if m.RequestID == "" {
+		return false
+	}
+	var exists int
+	if err := w.redis.Do(radix.Cmd(&exists, "EXISTS", fmt.Sprintf(redisSeckillProcessedKey, m.RequestID))); err != nil {
+		// Redis 不可用时继续处理，由订单表的 request_id 唯一约束兜底
+		GetMonitor().RecordRedisError()
+		return false
+	}
+	return exists == 1
+}
+
+// markProcessed 记录请求已处理
+func (w *SeckillWorker) markProcessed(m *SeckillMessage) {
+	if m.RequestID == "" {
+		return
+	}
+	key := fmt.Sprintf(redisSeckillProcessedKey, m.RequestID)
+	if err := w.redis.Do(radix.FlatCmd(nil, "SETEX", key, seckillProcessedExpireSeconds, "1")); err != nil {
+		log.Printf("failed to mark seckill request processed: %v", err)
+		GetMonitor().RecordRedisError()
+	}
+}
+
+// backoff 第 attempts 次失败后的重试延迟：base * 2^(attempts-1)，不超过上限
+func (w *SeckillWorker) backoff(attempts int) time.Duration {
+	delay := time.Duration(w.cfg.RetryBaseDelayMillis) * time.Millisecond
+	max := time.Duration(w.cfg.RetryMaxDelayMillis) * time.Millisecond
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// attemptsOf 读取消息头中的已失败次数，兼容 RabbitMQ 往返后的各种整数类型
+func attemptsOf(d *mq.Delivery) int {
+	switch v := d.Headers[headerAttempts].(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
+
+// process 完成一次下单：在同一事务中条件扣减 MySQL 秒杀库存、扣费并创建订单。
+// 失败时事务整体回滚并返回错误；Redis 库存由死信最终归还。
+// 请求已下过单时返回已有订单与 ErrDuplicateSeckillRequest。
+func (w *SeckillWorker) process(ctx context.Context, m *SeckillMessage) (*order.Order, error) {
+	p, err := w.productRepo.GetByID(ctx, m.ProductID)
+	if err != nil {
+		GetMonitor().RecordDBError()
+		return nil, fmt.Errorf("get product failed: %v", err)
+	}
+
+	// 计算本次应扣的秒杀价：商品在准入活动中设置了固定秒杀价时按该价格，否则按活动折扣价，都没有时为原价。
+	// 请求在准入时已确认活动进行中，之后活动被暂停或结束不影响已准入请求的价格；
+	// 没有活动ID的旧消息沿用按商品查找进行中活动的方式。
+	// 准入时已冻结资金的请求，账户服务以冻结金额为订单金额，这里计算的价格只用于没有冻结记录的旧消息。
+	priceToCharge := p.Price
+	activityID := m.ActivityID
+	if w.activitySvc != nil {
+		var act *seckill_activity.SeckillActivity
+		var ap *seckill_activity.SeckillActivityProduct
+		if m.ActivityID > 0 {
+			act, err = w.activitySvc.GetActivityByID(ctx, m.ActivityID)
+			if err == nil {
+				ap, err = w.activitySvc.GetActivityProduct(ctx, m.ActivityID, m.ProductID)
+			}
+			if err != nil {
+				// 查不到活动价格时不能按原价扣款，交由重试/死信处理
+				GetMonitor().RecordDBError()
+				return nil, fmt.Errorf("get activity price failed: %v", err)
+			}
+		} else {
+			act, err = w.activitySvc.GetActivityByProduct(ctx, m.ProductID)
+			if err == nil && act != nil {
+				now := time.Now()
+				if act.Status != seckill_activity.StatusRunning || !now.After(act.StartTime) || !now.Before(act.EndTime) {
+					act = nil
+				}
+			}
+		}
+		if err == nil && act != nil {
+			priceToCharge = SeckillPriceOf(p.Price, act, ap)
+			activityID = act.ID
+		}
+	}
+
+	// 使用账户服务在一个事务内完成扣库存 + 订单创建（+ 立即支付时的扣费与流水记录）
+	var o *order.Order
+	if w.orders.Deferred() {
+		o, err = w.accountSvc.SeckillOrder(ctx, m.UserID, m.ProductID, activityID, priceToCharge, m.RequestID, time.Now().Add(w.orders.PaymentWindow()))
+	} else {
+		o, err = w.accountSvc.SeckillCharge(ctx, m.UserID, m.ProductID, activityID, priceToCharge, m.RequestID)
+	}
+	if err != nil {
+		if errors.Is(err, ErrDuplicateSeckillRequest) {
+			return o, err
+		}
+		return nil, fmt.Errorf("seckill charge failed: %v", err)
+	}
+	if o.Status == order.StatusCreated {
+		if err := w.orders.ScheduleTimeout(ctx, o); err != nil {
+			// 订单已创建，投递失败不影响结果，由定时扫描兜底取消
+			log.Printf("failed to schedule order timeout: order=%d err=%v", o.ID, err)
+		}
+	}
+
+	// 递增用户对该商品的秒杀成功次数（用于每人限购统计）
+	succKey := fmt.Sprintf(redisSeckillSuccessKey, m.UserID, m.ProductID)
+	var newCount int
+	if err := w.redis.Do(radix.Cmd(&newCount, "INCR", succKey)); err != nil {
+		log.Printf("failed to increase seckill success count: %v", err)
+	} else {
+		// 首次成功时设置过期时间，避免长期占用Redis
+		if newCount == 1 {
+			if err := w.redis.Do(radix.Cmd(nil, "EXPIRE", succKey, strconv.Itoa(successMarkExpireSeconds))); err != nil {
+				log.Printf("failed to set expire for success count key: %v", err)
+			}
+		}
+		log.Printf("increase seckill success count: user=%d product=%d count=%d", m.UserID, m.ProductID, newCount)
+	}
+
+	log.Printf("create order success, order_id=%d user=%d product=%d", o.ID, o.UserID, m.ProductID)
+	return o, nil
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/example/goseckill/internal/auth"
+	"github.com/example/goseckill/internal/config"
+	"github.com/example/goseckill/internal/datamodels/refresh_token"
+	"github.com/example/goseckill/internal/datamodels/user"
+)
+
+var (
+	// ErrTokenInvalid 访问令牌无效、已过期或已吊销
+	ErrTokenInvalid = errors.New("invalid token")
+	// ErrRefreshTokenInvalid 刷新令牌不存在、已过期或已吊销
+	ErrRefreshTokenInvalid = errors.New("刷新令牌无效或已过期，请重新登录")
+)
+
+// TokenPair 登录或刷新后返回给客户端的令牌
+type TokenPair struct {
+	AccessToken      string `json:"token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`         // 访问令牌有效期（秒）
+	RefreshExpiresIn int64  `json:"refresh_expires_in"` // 刷新令牌有效期（秒）
+}
+
+// TokenService 前台用户的令牌管理：
+//   - 登录签发短期访问令牌与刷新令牌，刷新令牌只在 MySQL 中保存哈希
+//   - 刷新时轮换刷新令牌，旧令牌被再次使用视为泄露，吊销整个会话
+//   - 退出登录按 jti 吊销访问令牌并吊销会话；退出所有设备吊销用户的全部令牌
+type TokenService struct {
+	repo        refresh_token.Repository
+	users       user.Repository
+	cfg         *config.JWTConfig
+	revocations *auth.RevocationList
+	cache       *auth.TokenCache
+}
+
+// NewTokenService 创建令牌服务，cache 可为 nil（不缓存解析结果）
+func NewTokenService(repo refresh_token.Repository, users user.Repository, cfg *config.JWTConfig, revocations *auth.RevocationList, cache *auth.TokenCache) *TokenService {
+	return &TokenService{repo: repo, users: users, cfg: cfg, revocations: revocations, cache: cache}
+}
+
+func (s *TokenService) refreshTTL() time.Duration {
+	if s.cfg.RefreshTTLSeconds <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(s.cfg.RefreshTTLSeconds) * time.Second
+}
+
+// Issue 为用户开启一个新的登录会话
+func (s *TokenService) Issue(ctx context.Context, u *user.User) (*TokenPair, error) {
+	return s.issue(ctx, u, auth.NewTokenID())
+}
+
+// issue 在会话 familyID 下签发一对新令牌
+func (s *TokenService) issue(ctx context.Context, u *user.User, familyID string) (*TokenPair, error) {
+	access, claims, err := auth.IssueAccessToken(s.cfg, u.ID, u.Username, familyID)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	ttl := s.refreshTTL()
+	if err := s.repo.Create(ctx, &refresh_token.RefreshToken{
+		UserID:    u.ID,
+		FamilyID:  familyID,
+		TokenHash: hashRefreshToken(raw),
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:      access,
+		RefreshToken:     raw,
+		ExpiresIn:        int64(time.Until(claims.ExpiresAt.Time) / time.Second),
+		RefreshExpiresIn: int64(ttl / time.Second),
+	}, nil
+}
+
+// Refresh 用刷新令牌换取新的一对令牌，旧刷新令牌随即失效。
+// 已轮换过的刷新令牌再次出现说明可能被盗用，吊销整个会话，用户需要重新登录。
+func (s *TokenService) Refresh(ctx context.Context, raw string) (*TokenPair, error) {
+	if raw == "" {
+		return nil, ErrRefreshTokenInvalid
+	}
+	t, err := s.repo.GetByHash(ctx, hashRefreshToken(raw))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.RevokedAt != nil {
+		s.revokeReused(ctx, t)
+		return nil, ErrRefreshTokenInvalid
+	}
+	if !time.Now().Before(t.ExpiresAt) {
+		return nil, ErrRefreshTokenInvalid
+	}
+	ok, err := s.repo.Revoke(ctx, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// 并发请求已经轮换了这个令牌
+		s.revokeReused(ctx, t)
+		return nil, ErrRefreshTokenInvalid
+	}
+	u, err := s.users.GetByID(ctx, t.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(ctx, u, t.FamilyID)
+}
+
+// revokeReused 刷新令牌被重复使用时吊销其所在会话
+func (s *TokenService) revokeReused(ctx context.Context, t *refresh_token.RefreshToken) {
+	log.Printf("refresh token reuse detected: user=%d family=%s", t.UserID, t.FamilyID)
+	if err := s.repo.RevokeFamily(ctx, t.FamilyID); err != nil {
+		log.Printf("revoke refresh token family %s failed: %v", t.FamilyID, err)
+	}
+}
+
+// Authenticate 校验访问令牌：先查缓存，未命中时解析签名；已吊销的令牌返回 ErrTokenInvalid
+func (s *TokenService) Authenticate(ctx context.Context, token string) (*auth.Claims, error) {
+	if token == "" {
+		return nil, ErrTokenInvalid
+	}
+	if s.cache != nil {
+		if cached, ok, _ := s.cache.Get(ctx, token); ok && cached != nil {
+			return cached, nil
+		}
+	}
+	claims, err := auth.ParseToken(s.cfg, token)
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+	revoked, err := s.revocations.IsRevoked(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenInvalid
+	}
+	if s.cache != nil {
+		_ = s.cache.Set(ctx, token, claims)
+	}
+	return claims, nil
+}
+
+// Logout 退出当前会话：吊销访问令牌（按 jti，直到其过期）并吊销所属会话的全部刷新令牌
+func (s *TokenService) Logout(ctx context.Context, token string, claims *auth.Claims) error {
+	if claims.ExpiresAt != nil {
+		if err := s.revocations.Revoke(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+			return err
+		}
+	}
+	if s.cache != nil {
+		_ = s.cache.Delete(ctx, token)
+	}
+	if claims.SessionID == "" {
+		return nil
+	}
+	return s.repo.RevokeFamily(ctx, claims.SessionID)
+}
+
+// LogoutRefreshToken 只持有刷新令牌时退出其所在会话（例如访问令牌已过期的网页退出）
+func (s *TokenService) LogoutRefreshToken(ctx context.Context, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	t, err := s.repo.GetByHash(ctx, hashRefreshToken(raw))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.repo.RevokeFamily(ctx, t.FamilyID)
+}
+
+// LogoutAll 退出用户的所有设备：吊销全部刷新令牌，并让此前签发的访问令牌全部失效
+func (s *TokenService) LogoutAll(ctx context.Context, userID int64) error {
+	if err := s.repo.RevokeByUser(ctx, userID); err != nil {
+		return err
+	}
+	return s.revocations.RevokeUser(ctx, userID, time.Now())
+}
+
+// newRefreshToken 生成 256 位随机刷新令牌
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashRefreshToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
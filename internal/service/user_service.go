@@ -2,50 +2,57 @@ package service
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
+	"log"
 
 	"github.com/example/goseckill/internal/auth"
-	"github.com/example/goseckill/internal/config"
 	"github.com/example/goseckill/internal/datamodels/user"
 )
 
 type UserService struct {
-	repo user.Repository
-	jwt  *config.JWTConfig
+	repo   user.Repository
+	hasher auth.PasswordHasher
+	tokens *TokenService
 }
 
-func NewUserService(repo user.Repository, jwt *config.JWTConfig) *UserService {
-	return &UserService{repo: repo, jwt: jwt}
+func NewUserService(repo user.Repository, hasher auth.PasswordHasher, tokens *TokenService) *UserService {
+	return &UserService{repo: repo, hasher: hasher, tokens: tokens}
 }
 
-func hashPassword(raw, salt string) string {
-	h := sha256.Sum256([]byte(raw + salt))
-	return hex.EncodeToString(h[:])
-}
-
-// Register 简单注册（示例用）
+// Register 简单注册（示例用），密码以 bcrypt 哈希保存
 func (s *UserService) Register(ctx context.Context, username, password string) (*user.User, error) {
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return nil, err
+	}
 	u := &user.User{
 		Username: username,
-		Salt:     "goseckill", // 简化实现，真实业务请使用随机盐
+		Password: hash,
 	}
-	u.Password = hashPassword(password, u.Salt)
 	if err := s.repo.Create(ctx, u); err != nil {
 		return nil, err
 	}
 	return u, nil
 }
 
-// Login 登录并返回 JWT
-func (s *UserService) Login(ctx context.Context, username, password string) (string, error) {
+// Login 登录并开启新的会话，返回访问令牌与刷新令牌。
+// 旧版 SHA-256 哈希的用户登录成功后重新计算为 bcrypt 哈希，无需重置密码。
+func (s *UserService) Login(ctx context.Context, username, password string) (*TokenPair, error) {
 	u, err := s.repo.GetByUsername(ctx, username)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	ok, rehash := auth.VerifyPassword(s.hasher, u.Password, u.Salt, password)
+	if !ok {
+		return nil, errors.New("invalid password")
 	}
-	if hashPassword(password, u.Salt) != u.Password {
-		return "", errors.New("invalid password")
+	if rehash {
+		// 迁移失败不影响本次登录，下次登录再试
+		if hash, err := s.hasher.Hash(password); err == nil {
+			if err := s.repo.UpdatePassword(ctx, u.ID, hash, ""); err != nil {
+				log.Printf("rehash password of user %d failed: %v", u.ID, err)
+			}
+		}
 	}
-	return auth.GenerateToken(s.jwt, u.ID, u.Username)
+	return s.tokens.Issue(ctx, u)
 }
@@ -51,15 +51,32 @@ function showToast(message, variant = "success") {
   setTimeout(() => toastEl.classList.add("d-none"), 3000);
 }
 
+// 管理员令牌保存在 localStorage，未登录或令牌失效时跳转登录页
+const ADMIN_TOKEN_KEY = "adminToken";
+
+function redirectToLogin() {
+  localStorage.removeItem(ADMIN_TOKEN_KEY);
+  window.location.href = "/login";
+}
+
 async function callApi(path, options = {}) {
+  const token = localStorage.getItem(ADMIN_TOKEN_KEY);
   const opts = {
+    ...options,
     headers: {
       "Content-Type": "application/json",
+      ...(token ? { Authorization: token } : {}),
       ...(options.headers || {}),
     },
-    ...options,
   };
   const response = await fetch(path, opts);
+  if (response.status === 401) {
+    redirectToLogin();
+    throw new Error("登录已失效，请重新登录");
+  }
+  if (response.status === 403) {
+    throw new Error("当前账号没有权限执行该操作");
+  }
   // 非 2xx 直接抛错
   if (!response.ok) {
     let msg = `请求失败(${response.status})`;
@@ -185,11 +202,24 @@ function renderProducts(list) {
   bindInlineEditEvents();
 }
 
+const orderStatusText = { 0: "待支付", 1: "已支付", 2: "已取消" };
+
+// 订单商品：优先显示下单时的商品快照，旧订单没有明细时显示商品ID
+function orderItemsText(o) {
+  if (!o.Items || !o.Items.length) {
+    return `#${o.ProductID}`;
+  }
+  return o.Items.map((it) => {
+    const tag = it.Source === "seckill" ? "[秒杀] " : "";
+    return `${tag}${it.ProductName ? escapeHtml(it.ProductName) : "#" + it.ProductID} × ${it.Quantity}（¥${centsToYuan(it.Amount).toFixed(2)}）`;
+  }).join("<br>");
+}
+
 function renderOrders(list) {
   state.orders = list;
   if (!list.length) {
     orderTableBody.innerHTML =
-      '<tr><td colspan="6" class="text-center text-muted">暂无订单</td></tr>';
+      '<tr><td colspan="7" class="text-center text-muted">暂无订单</td></tr>';
     return;
   }
   orderTableBody.innerHTML = list
@@ -197,10 +227,15 @@ function renderOrders(list) {
       (o) => `<tr>
         <td>${o.ID}</td>
         <td>${o.UserID}</td>
-        <td>${o.ProductID}</td>
+        <td>${orderItemsText(o)}</td>
         <td>¥${centsToYuan(o.Price).toFixed(2)}</td>
-        <td>${o.Status}</td>
+        <td>${orderStatusText[o.Status] ?? o.Status}</td>
         <td>${formatDateTime(o.CreatedAt)}</td>
+        <td class="text-center">${
+          o.Status === 1
+            ? `<button class="btn btn-sm btn-link text-danger" data-order="${o.ID}" data-action="refund">退款</button>`
+            : ""
+        }</td>
       </tr>`
     )
     .join("");
@@ -240,7 +275,7 @@ function renderUserOrders(list) {
     .map(
       (o) => `<tr>
         <td>${o.ID}</td>
-        <td>${o.ProductID}</td>
+        <td>${orderItemsText(o)}</td>
         <td>¥${centsToYuan(o.Price).toFixed(2)}</td>
         <td>${o.Status}</td>
         <td>${formatDateTime(o.CreatedAt)}</td>
@@ -334,14 +369,14 @@ if (productTableBody) {
 
 async function loadOrders() {
   orderTableBody.innerHTML =
-    '<tr><td colspan="6" class="text-center text-muted">加载中...</td></tr>';
+    '<tr><td colspan="7" class="text-center text-muted">加载中...</td></tr>';
   try {
     const data = await callApi("/api/orders?limit=20");
     renderOrders(Array.isArray(data) ? data : []);
   } catch (err) {
     showToast(err.message, "danger");
     orderTableBody.innerHTML =
-      '<tr><td colspan="6" class="text-center text-danger">加载失败</td></tr>';
+      '<tr><td colspan="7" class="text-center text-danger">加载失败</td></tr>';
   }
 }
 
@@ -635,7 +670,33 @@ if (userTableBody) {
   });
 }
 
+// 订单退款：全额退回用户余额并归还库存
+async function refundOrder(orderID) {
+  const reason = prompt(`确认为订单 #${orderID} 退款？可填写退款原因：`, "");
+  if (reason === null) return;
+  try {
+    await callApi(`/api/orders/${orderID}/refund`, {
+      method: "POST",
+      body: JSON.stringify({ reason }),
+    });
+    showToast(`订单 #${orderID} 已退款`);
+    loadOrders();
+  } catch (err) {
+    showToast(err.message, "danger");
+  }
+}
+
+orderTableBody.addEventListener("click", (event) => {
+  const btn = event.target.closest("button[data-order]");
+  if (!btn || btn.dataset.action !== "refund") return;
+  refundOrder(Number(btn.dataset.order));
+});
+
 (async function bootstrap() {
+  if (!localStorage.getItem(ADMIN_TOKEN_KEY)) {
+    redirectToLogin();
+    return;
+  }
   switchSection("product-section");
   await Promise.all([loadProducts(), loadOrders()]);
   initChat();
@@ -852,7 +913,22 @@ function initChat() {
 
 // ---------- 秒杀活动管理逻辑 ----------
 
-const activityStatusMap = ["未开始", "进行中", "已结束", "已取消"];
+const activityStatusMap = ["未开始", "进行中", "已结束", "已取消", "草稿", "已暂停"];
+
+// 各状态下可执行的操作，与后端状态机的合法迁移一致
+const activityActions = {
+  4: [["publish", "发布"], ["start", "启动"], ["cancel", "取消"]],
+  0: [["unpublish", "撤回"], ["start", "启动"], ["end", "结束"], ["cancel", "取消"]],
+  1: [["pause", "暂停"], ["end", "结束"], ["cancel", "取消"]],
+  5: [["resume", "恢复"], ["end", "结束"], ["cancel", "取消"]],
+};
+
+const activityActionConfirm = {
+  start: "确定要启动这个秒杀活动吗？这将更新商品状态并同步库存到Redis。",
+  pause: "确定要暂停这个秒杀活动吗？暂停期间将拒绝新的秒杀请求。",
+  end: "确定要提前结束这个秒杀活动吗？未售出的秒杀库存将归还普通库存。",
+  cancel: "确定要取消这个秒杀活动吗？未售出库存将归还，活动的 Redis 数据将被清理，且不可恢复。",
+};
 
 const activityTableBody = document.getElementById("activity-table");
 const activityFormCard = document.getElementById("activity-form-card");
@@ -874,16 +950,12 @@ function renderActivities(list) {
   activityTableBody.innerHTML = list
     .map(
       (a) => {
-        const now = new Date();
-        const startTime = new Date(a.StartTime);
-        const endTime = new Date(a.EndTime);
-        let status = a.Status;
-        // 优先使用后端返回的状态，但如果时间已过，强制更新为已结束
-        if (now > endTime) {
-          status = 2; // 已结束
-        } else if (status === 0 && now >= startTime && now <= endTime) {
-          status = 1; // 自动判断为进行中
-        }
+        // 状态由后端调度器按时间推进，这里直接展示
+        const status = a.Status;
+        const actions = (activityActions[status] || [])
+          .map(([action, label]) =>
+            `<button class="btn btn-sm btn-link ${action === "cancel" ? "text-danger" : "text-primary"}" data-activity-action="${action}" data-activity-id="${a.ID}">${label}</button>`)
+          .join("");
         return `<tr>
           <td>${a.ID}</td>
           <td>${a.Name}</td>
@@ -894,7 +966,7 @@ function renderActivities(list) {
           <td>${activityStatusMap[status] || "未知"}</td>
           <td class="text-center">
             <button class="btn btn-sm btn-link" data-view-activity="${a.ID}">查看</button>
-            <button class="btn btn-sm btn-link text-primary" data-start-activity="${a.ID}">启动</button>
+            ${actions}
             <button class="btn btn-sm btn-link text-danger" data-delete-activity="${a.ID}">删除</button>
           </td>
         </tr>`;
@@ -925,6 +997,23 @@ function renderProductSelectList() {
                      max="${p.Stock}"
                      style="width: 80px;">
             </div>
+            <div class="mt-1">
+              <small>秒杀价(¥):</small>
+              <input type="number" class="form-control form-control-sm d-inline-block w-auto ms-1"
+                     data-price-input="${p.ID}"
+                     placeholder="按折扣"
+                     min="0"
+                     step="0.01"
+                     style="width: 90px;">
+            </div>
+            <div class="mt-1">
+              <small>每人限购:</small>
+              <input type="number" class="form-control form-control-sm d-inline-block w-auto ms-1"
+                     data-limit-input="${p.ID}"
+                     placeholder="同活动"
+                     min="0"
+                     style="width: 80px;">
+            </div>
           </label>
         </div>
       </div>`
@@ -1009,6 +1098,7 @@ async function showActivityDetail(activityId) {
               <th>原价</th>
               <th>秒杀价</th>
               <th>秒杀库存</th>
+              <th>每人限购</th>
             </tr>
           </thead>
           <tbody>
@@ -1017,14 +1107,47 @@ async function showActivityDetail(activityId) {
                 <td>${p.ProductID}</td>
                 <td>${p.ProductName}</td>
                 <td>¥${centsToYuan(p.ProductPrice).toFixed(2)}</td>
-                <td class="text-danger fw-bold">¥${centsToYuan(p.SeckillPrice).toFixed(2)}</td>
+                <td class="text-danger fw-bold">¥${centsToYuan(p.SeckillPrice).toFixed(2)}${p.FixedPrice ? ' <span class="badge bg-secondary">固定价</span>' : ""}</td>
                 <td>${p.SeckillStock}</td>
+                <td>${p.LimitPerUser}</td>
               </tr>
             `).join("")}
           </tbody>
         </table>
       `;
     }
+
+    // 已结束/已取消的活动展示库存结算结果
+    if (activity.Status === 2 || activity.Status === 3) {
+      const settlements = (await callApi(`/api/seckill-activities/${activityId}/settlements`)) || [];
+      activityDetailContent.innerHTML += settlements.length ? `
+        <h6 class="mt-3">库存结算</h6>
+        <table class="table table-sm">
+          <thead>
+            <tr>
+              <th>商品ID</th>
+              <th>划拨</th>
+              <th>已成交</th>
+              <th>归还普通库存</th>
+              <th>在途</th>
+              <th>结算时间</th>
+            </tr>
+          </thead>
+          <tbody>
+            ${settlements.map(st => `
+              <tr>
+                <td>${st.ProductID}</td>
+                <td>${st.Allocated}</td>
+                <td>${st.Sold}</td>
+                <td>${st.Returned}</td>
+                <td>${st.Pending}</td>
+                <td>${formatDateTime(st.CreatedAt)}</td>
+              </tr>
+            `).join("")}
+          </tbody>
+        </table>
+      ` : '<p class="text-muted mt-3">尚未结算</p>';
+    }
     
     activityDetailCard.style.display = "block";
     activityFormCard.style.display = "none";
@@ -1073,13 +1196,23 @@ if (activityForm) {
       return;
     }
     
-    // 获取每个商品的秒杀库存
+    // 获取每个商品的秒杀库存，以及可选的固定秒杀价（分）与每人限购
     const productStocks = {};
+    const productPrices = {};
+    const productLimits = {};
     selectedProducts.forEach(productId => {
       const stockInput = productSelectList.querySelector(`input[data-stock-input="${productId}"]`);
       if (stockInput) {
         productStocks[productId] = Number(stockInput.value) || 0;
       }
+      const priceInput = productSelectList.querySelector(`input[data-price-input="${productId}"]`);
+      if (priceInput && priceInput.value !== "") {
+        productPrices[productId] = yuanToCents(priceInput.value);
+      }
+      const limitInput = productSelectList.querySelector(`input[data-limit-input="${productId}"]`);
+      if (limitInput && limitInput.value !== "") {
+        productLimits[productId] = Number(limitInput.value) || 0;
+      }
     });
     
     const payload = {
@@ -1091,6 +1224,9 @@ if (activityForm) {
       limit_per_user: limitPerUser,
       product_ids: selectedProducts,
       product_stocks: productStocks,
+      product_prices: productPrices,
+      product_limits: productLimits,
+      draft: document.getElementById("activity-draft").checked,
     };
     
     try {
@@ -1145,15 +1281,17 @@ if (activityTableBody) {
       return;
     }
     
-    const startBtn = event.target.closest("button[data-start-activity]");
-    if (startBtn) {
-      const id = Number(startBtn.dataset.startActivity);
-      if (!confirm("确定要启动这个秒杀活动吗？这将更新商品状态并同步库存到Redis。")) {
+    const actionBtn = event.target.closest("button[data-activity-action]");
+    if (actionBtn) {
+      const id = Number(actionBtn.dataset.activityId);
+      const action = actionBtn.dataset.activityAction;
+      const tip = activityActionConfirm[action];
+      if (tip && !confirm(tip)) {
         return;
       }
       try {
-        await callApi(`/api/seckill-activities/${id}/start`, { method: "POST" });
-        showToast("活动启动成功");
+        await callApi(`/api/seckill-activities/${id}/${action}`, { method: "POST" });
+        showToast(`活动已${actionBtn.textContent}`);
         await loadActivities();
       } catch (err) {
         showToast(err.message, "danger");
@@ -1178,3 +1316,20 @@ if (activityTableBody) {
     }
   });
 }
+
+// ---------- 当前管理员 ----------
+(function initAdminSession() {
+  if (!localStorage.getItem(ADMIN_TOKEN_KEY)) return;
+  const roleText = { admin: "超级管理员", operator: "运营", finance: "财务", readonly: "只读" };
+  callApi("/api/admin/me")
+    .then((me) => {
+      if (!me) return;
+      document.getElementById("admin-name").textContent = `${me.username}（${roleText[me.role] || me.role}）`;
+      document.getElementById("admin-avatar").textContent = (me.username || "A").charAt(0).toUpperCase();
+    })
+    .catch(() => {});
+  document.getElementById("admin-logout").addEventListener("click", (e) => {
+    e.preventDefault();
+    redirectToLogin();
+  });
+})();
@@ -12,12 +12,13 @@ import (
 
 // UserController 负责前台登录/注册页面与表单处理。
 type UserController struct {
-	userService *service.UserService
+	userService  *service.UserService
+	tokenService *service.TokenService
 }
 
 // NewUserController 构造函数，供路由层复用同一套逻辑。
-func NewUserController(userSvc *service.UserService) *UserController {
-	return &UserController{userService: userSvc}
+func NewUserController(userSvc *service.UserService, tokenSvc *service.TokenService) *UserController {
+	return &UserController{userService: userSvc, tokenService: tokenSvc}
 }
 
 // ShowLogin 渲染登录表单。
@@ -55,7 +56,7 @@ func (c *UserController) PostLogin(ctx iris.Context) {
 		return
 	}
 
-	token, err := c.userService.Login(ctx.Request().Context(), username, password)
+	pair, err := c.userService.Login(ctx.Request().Context(), username, password)
 	if err != nil {
 		ctx.ContentType("text/html; charset=utf-8")
 		_, _ = ctx.WriteString("<h2>登录失败: " + err.Error() + "</h2>")
@@ -67,11 +68,7 @@ func (c *UserController) PostLogin(ctx iris.Context) {
 		Value: username,
 		Path:  "/",
 	})
-	ctx.SetCookie(&http.Cookie{
-		Name:  "token",
-		Value: token,
-		Path:  "/",
-	})
+	SetTokenCookies(ctx, pair)
 
 	ctx.Redirect("/", iris.StatusFound)
 }
@@ -106,9 +103,41 @@ func (c *UserController) PostAdd(ctx iris.Context) {
 	ctx.Redirect("/login", iris.StatusFound)
 }
 
-// Logout 清理 cookie 并回到首页。
+// Logout 吊销当前会话的令牌，清理 cookie 并回到首页。
 func (c *UserController) Logout(ctx iris.Context) {
-	clearCookie := func(name string) {
+	reqCtx := ctx.Request().Context()
+	if token := ctx.GetCookie("token"); token != "" {
+		if claims, err := c.tokenService.Authenticate(reqCtx, token); err == nil {
+			_ = c.tokenService.Logout(reqCtx, token, claims)
+		}
+	}
+	// 访问令牌可能已过期，再按刷新令牌吊销会话
+	_ = c.tokenService.LogoutRefreshToken(reqCtx, ctx.GetCookie("refresh_token"))
+	ClearTokenCookies(ctx)
+	ctx.Redirect("/", iris.StatusFound)
+}
+
+// SetTokenCookies 把登录/刷新得到的令牌写入 cookie：token 供页面脚本读取后放入 Authorization 头，
+// refresh_token 设置为 HttpOnly，只在刷新令牌时由浏览器自动携带。
+func SetTokenCookies(ctx iris.Context, pair *service.TokenPair) {
+	ctx.SetCookie(&http.Cookie{
+		Name:  "token",
+		Value: pair.AccessToken,
+		Path:  "/",
+	})
+	ctx.SetCookie(&http.Cookie{
+		Name:     "refresh_token",
+		Value:    pair.RefreshToken,
+		Path:     "/",
+		MaxAge:   int(pair.RefreshExpiresIn),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearTokenCookies 清理登录相关的 cookie。
+func ClearTokenCookies(ctx iris.Context) {
+	for _, name := range []string{"username", "token", "refresh_token"} {
 		ctx.SetCookie(&http.Cookie{
 			Name:    name,
 			Value:   "",
@@ -117,7 +146,4 @@ func (c *UserController) Logout(ctx iris.Context) {
 			MaxAge:  -1,
 		})
 	}
-	clearCookie("username")
-	clearCookie("token")
-	ctx.Redirect("/", iris.StatusFound)
 }